@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// stateAPI serves the master state as seen by the exporter, after version
+// compatibility handling, normalization and filtering, as JSON.
+type stateAPI struct {
+	collector *collector.MasterStateCollector
+	scrapes   *scrapeHandler
+}
+
+func (a *stateAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		st  *collector.State
+		err error
+	)
+	// Fetch one at a time with scrapes, like push outputs do.
+	a.scrapes.scrape(0, func() { st, err = a.collector.FetchState(r.Context()) })
+	if err != nil {
+		log.Print(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		log.Printf("Error encoding state: %s", err)
+	}
+}
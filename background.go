@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// backgroundCollector serves the metrics of a collector as of its latest
+// refresh, which runs every interval instead of on every scrape, so expensive
+// endpoints are polled less often.
+type backgroundCollector struct {
+	prometheus.Collector
+	interval time.Duration
+
+	mu      sync.Mutex
+	metrics []prometheus.Metric
+}
+
+func (c *backgroundCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+// refresh collects the metrics served until the next refresh.
+func (c *backgroundCollector) refresh() {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collector.Collect(ch)
+		close(ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+}
+
+// run refreshes the metrics right away and then every interval, while
+// active, if given, reports true. Refreshes share the scrape handler's lock
+// and bound upstream fetches by the interval.
+func (c *backgroundCollector) run(h *scrapeHandler, active func() bool) {
+	tick := time.Tick(c.interval)
+	for ; ; <-tick {
+		if active == nil || active() {
+			h.scrape(c.interval, c.refresh)
+		}
+	}
+}
+
+// backgroundRegisterer registers the collectors with an interval configured
+// for their name as backgroundCollectors, and all others as they are.
+type backgroundRegisterer struct {
+	prometheus.Registerer
+	intervals  map[string]time.Duration
+	collectors map[string][]*backgroundCollector
+	// names are the names of all registered collectors.
+	names map[string]bool
+}
+
+func newBackgroundRegisterer(r prometheus.Registerer, intervals map[string]time.Duration) *backgroundRegisterer {
+	return &backgroundRegisterer{Registerer: r, intervals: intervals, collectors: map[string][]*backgroundCollector{}, names: map[string]bool{}}
+}
+
+// with returns a registerer registering with r instead, sharing the intervals,
+// background collectors and names of b, e.g. for the registries of clusters.
+func (b *backgroundRegisterer) with(r prometheus.Registerer) *backgroundRegisterer {
+	return &backgroundRegisterer{Registerer: r, intervals: b.intervals, collectors: b.collectors, names: b.names}
+}
+
+func (b *backgroundRegisterer) Register(c prometheus.Collector) error {
+	name := collector.Name(c)
+	if name != "" {
+		b.names[name] = true
+	}
+	interval, ok := b.intervals[name]
+	if !ok {
+		return b.Registerer.Register(c)
+	}
+	bc := &backgroundCollector{Collector: c, interval: interval}
+	if err := b.Registerer.Register(bc); err != nil {
+		return err
+	}
+	b.collectors[name] = append(b.collectors[name], bc)
+	return nil
+}
+
+func (b *backgroundRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := b.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// registered returns the sorted names of the registered collectors.
+func (b *backgroundRegisterer) registered() []string {
+	var names []string
+	for name := range b.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// refresh refreshes all background collectors once.
+func (b *backgroundRegisterer) refresh(h *scrapeHandler) {
+	for _, cs := range b.collectors {
+		for _, c := range cs {
+			h.scrape(c.interval, c.refresh)
+		}
+	}
+}
+
+// start runs the refreshes of the background collectors, see
+// backgroundCollector.run. Intervals of collectors which weren't registered
+// are fatal, as they are most likely misspelled.
+func (b *backgroundRegisterer) start(h *scrapeHandler, active func() bool) {
+	for name, interval := range b.intervals {
+		cs := b.collectors[name]
+		if len(cs) == 0 {
+			log.Fatalf("No %s collector to refresh every %s", name, interval)
+		}
+		for _, c := range cs {
+			go c.run(h, active)
+		}
+		log.Printf("Refreshing the %s collector every %s", name, interval)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// httpClientConfig configures authentication and TLS of requests to push
+// based outputs.
+type httpClientConfig struct {
+	BasicAuth       *basicAuth `json:"basic_auth"`
+	BearerTokenFile string     `json:"bearer_token_file"`
+	TLSConfig       tlsConfig  `json:"tls_config"`
+}
+
+type basicAuth struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"`
+}
+
+type tlsConfig struct {
+	CAFile             string `json:"ca_file"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+func (c httpClientConfig) validate() error {
+	if c.BasicAuth != nil && c.BearerTokenFile != "" {
+		return fmt.Errorf("at most one of basic_auth and bearer_token_file may be set")
+	}
+	if c.BasicAuth != nil && c.BasicAuth.Password != "" && c.BasicAuth.PasswordFile != "" {
+		return fmt.Errorf("at most one of password and password_file may be set")
+	}
+	return c.TLSConfig.validate()
+}
+
+func (c tlsConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+// newClient returns a client authenticating every request as configured.
+// Password and token files are read on every request, so they can be
+// rotated without restarting the exporter.
+func (c httpClientConfig) newClient(timeout time.Duration) (*http.Client, error) {
+	t, err := c.TLSConfig.transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &authRoundTripper{next: t, cfg: c},
+	}, nil
+}
+
+// transport returns a transport using the TLS configuration.
+func (c tlsConfig) transport() (*http.Transport, error) {
+	tc := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if f := c.CAFile; f != "" {
+		pem, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", f)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tc
+	return t, nil
+}
+
+type authRoundTripper struct {
+	next http.RoundTripper
+	cfg  httpClientConfig
+}
+
+func (rt *authRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	switch {
+	case rt.cfg.BasicAuth != nil:
+		password := rt.cfg.BasicAuth.Password
+		if f := rt.cfg.BasicAuth.PasswordFile; f != "" {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			password = strings.TrimSpace(string(b))
+		}
+		r = r.Clone(r.Context())
+		r.SetBasicAuth(rt.cfg.BasicAuth.Username, password)
+	case rt.cfg.BearerTokenFile != "":
+		b, err := os.ReadFile(rt.cfg.BearerTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(b)))
+	}
+	return rt.next.RoundTrip(r)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// clusterConfig is one of several Mesos clusters scraped by the exporter,
+// whose metrics carry its name as cluster label.
+type clusterConfig struct {
+	Name string `json:"name"`
+	// Master is the URL of the master, e.g. of a load balancer in front of
+	// the masters or of DC/OS Admin Router.
+	Master string `json:"master"`
+	// DCOS, if set, authenticates requests to the cluster. The top-level
+	// dcos section doesn't apply to clusters.
+	DCOS *dcosConfig `json:"dcos"`
+}
+
+func (c clusterConfig) validate() error {
+	if !model.LabelValue(c.Name).IsValid() || c.Name == "" {
+		return fmt.Errorf("invalid name %q", c.Name)
+	}
+	if c.Master == "" {
+		return fmt.Errorf("master is required")
+	}
+	if c.DCOS != nil {
+		if err := c.DCOS.validate(); err != nil {
+			return fmt.Errorf("dcos: %s", err)
+		}
+	}
+	return nil
+}
+
+// register registers the master collectors of the cluster, configured like
+// those of -master, with a registry of its own, which is returned for
+// gathering. Task state changes of clusters aren't published and their
+// counters are persisted to files suffixed with the cluster name.
+func (c clusterConfig) register(b *backgroundRegisterer, stateOpts collector.StateOptions, rules []collector.MappingRule, timeout time.Duration, vault *vaultClient, rec recorder) (prometheus.Gatherer, error) {
+	opts := collector.Options{Timeout: timeout, Internal: stateOpts.Internal}
+	if c.DCOS != nil {
+		var err error
+		if opts.Client, err = c.DCOS.newClient(timeout, vault); err != nil {
+			return nil, fmt.Errorf("error configuring DC/OS authentication: %s", err)
+		}
+	}
+	opts.Client = rec.client(opts.Client, timeout)
+	stateOpts.Options = opts
+	stateOpts.Events = nil
+	if stateOpts.CountersFile != "" {
+		stateOpts.CountersFile += "." + c.Name
+	}
+
+	reg := prometheus.NewRegistry()
+	r := b.with(prometheus.WrapRegistererWith(prometheus.Labels{"cluster": c.Name}, reg))
+	if _, err := collector.RegisterMaster(r, c.Master, stateOpts); err != nil {
+		return nil, err
+	}
+	if len(rules) > 0 {
+		if err := r.Register(collector.NewMappingCollector(collector.RoleMaster, c.Master, opts, rules)); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// config is the optional JSON configuration file given by -config.
+type config struct {
+	// Metrics overrides the name and help of exported metric families,
+	// keyed by the name the exporter would use.
+	Metrics map[string]metricOverride `json:"metrics"`
+	// Buckets override the bucket boundaries of histogram families, keyed
+	// by the name the exporter would use.
+	Buckets map[string][]float64 `json:"buckets"`
+	// ResourceUnits declare the units of custom resources, keyed by
+	// resource name.
+	ResourceUnits map[string]collector.ResourceUnit `json:"resource_units"`
+	// Intervals refresh the collectors of the given names in the
+	// background, e.g. "master_state": "60s", instead of on every scrape.
+	Intervals map[string]string `json:"intervals"`
+	// Mappings export additional metric families from arbitrary fields of
+	// the Mesos endpoints.
+	Mappings []collector.MappingRule `json:"mappings"`
+	// RemoteWrite, if set, pushes all metrics to a remote_write endpoint.
+	RemoteWrite *remoteWriteConfig `json:"remote_write"`
+	// InfluxDB, if set, pushes all metrics to InfluxDB.
+	InfluxDB *influxConfig `json:"influxdb"`
+	// Webhooks are notified about tasks entering terminal states.
+	Webhooks []webhookConfig `json:"webhooks"`
+	// DCOS, if set, authenticates requests to Mesos with a DC/OS cluster.
+	DCOS *dcosConfig `json:"dcos"`
+	// Clusters are further Mesos clusters to scrape, instead of the one
+	// given by flags.
+	Clusters []clusterConfig `json:"clusters"`
+	// Consul, if set, registers the exporter as service in Consul.
+	Consul *consulConfig `json:"consul"`
+	// Election, if set, elects the replica of the exporter which scrapes
+	// Mesos, while the others stand by.
+	Election *electionConfig `json:"election"`
+	// Vault, if set, is where secrets are read from.
+	Vault *vaultConfig `json:"vault"`
+}
+
+type metricOverride struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("decoding %s: %s", path, err)
+	}
+	return cfg, cfg.validate()
+}
+
+func (cfg *config) validate() error {
+	renamed := map[string]string{}
+	for name, o := range cfg.Metrics {
+		if o.Name == "" {
+			continue
+		}
+		if !model.IsValidMetricName(model.LabelValue(o.Name)) {
+			return fmt.Errorf("metrics: invalid name %q for %s", o.Name, name)
+		}
+		if other, ok := renamed[o.Name]; ok {
+			return fmt.Errorf("metrics: both %s and %s renamed to %s", other, name, o.Name)
+		}
+		renamed[o.Name] = name
+	}
+	for name, b := range cfg.Buckets {
+		if _, ok := collector.DefaultBuckets[name]; !ok {
+			return fmt.Errorf("buckets: unknown histogram %s", name)
+		}
+		if len(b) == 0 {
+			return fmt.Errorf("buckets: no buckets for %s", name)
+		}
+		for i := 1; i < len(b); i++ {
+			if b[i] <= b[i-1] {
+				return fmt.Errorf("buckets: buckets of %s not in increasing order", name)
+			}
+		}
+	}
+	for name, u := range cfg.ResourceUnits {
+		if metric := collector.ResourceMetricName(name, u); u.Unit == "" || !model.IsValidMetricName(model.LabelValue(metric)) {
+			return fmt.Errorf("resource_units: invalid unit %q for %s", u.Unit, name)
+		}
+		if u.Scale < 0 {
+			return fmt.Errorf("resource_units: negative scale for %s", name)
+		}
+	}
+	for name, v := range cfg.Intervals {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("intervals: %s: %s", name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("intervals: non-positive interval for %s", name)
+		}
+	}
+	families := builtinFamilies(cfg)
+	for i, r := range cfg.Mappings {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("mappings[%d]: %s", i, err)
+		}
+		if families[r.Name] {
+			return fmt.Errorf("mappings[%d]: name %s already taken", i, r.Name)
+		}
+		families[r.Name] = true
+	}
+	for target, name := range renamed {
+		if families[target] && target != name {
+			return fmt.Errorf("metrics: %s renamed to %s, which is already taken", name, target)
+		}
+	}
+	if cfg.RemoteWrite != nil {
+		if err := cfg.RemoteWrite.validate(); err != nil {
+			return fmt.Errorf("remote_write: %s", err)
+		}
+	}
+	if cfg.InfluxDB != nil {
+		if err := cfg.InfluxDB.validate(); err != nil {
+			return fmt.Errorf("influxdb: %s", err)
+		}
+	}
+	if cfg.DCOS != nil {
+		if err := cfg.DCOS.validate(); err != nil {
+			return fmt.Errorf("dcos: %s", err)
+		}
+		if cfg.DCOS.VaultSecret != "" && cfg.Vault == nil {
+			return fmt.Errorf("dcos: vault_secret requires vault")
+		}
+	}
+	names := map[string]bool{}
+	for i, c := range cfg.Clusters {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("clusters[%d]: %s", i, err)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("clusters[%d]: duplicate name %s", i, c.Name)
+		}
+		names[c.Name] = true
+		if c.DCOS != nil && c.DCOS.VaultSecret != "" && cfg.Vault == nil {
+			return fmt.Errorf("clusters[%d]: dcos: vault_secret requires vault", i)
+		}
+	}
+	if cfg.Vault != nil {
+		if err := cfg.Vault.validate(); err != nil {
+			return fmt.Errorf("vault: %s", err)
+		}
+	}
+	if cfg.Consul != nil {
+		if err := cfg.Consul.validate(); err != nil {
+			return fmt.Errorf("consul: %s", err)
+		}
+	}
+	if cfg.Election != nil {
+		if err := cfg.Election.validate(); err != nil {
+			return fmt.Errorf("election: %s", err)
+		}
+	}
+	for i, w := range cfg.Webhooks {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("webhooks[%d]: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// builtinFamilies returns the names of the families the exporter exports
+// without mapping rules, which the series of mapping rules would collide
+// with.
+func builtinFamilies(cfg *config) map[string]bool {
+	names := map[string]bool{}
+	cs := collector.InternalCollectors()
+	for name, c := range catalogCollectors(cfg, false) {
+		if !strings.HasSuffix(name, "_mappings") {
+			cs = append(cs, c)
+		}
+	}
+	for _, c := range cs {
+		for _, f := range collector.Families(c) {
+			names[f.Name] = true
+		}
+	}
+	return names
+}
+
+// mappings returns the mapping rules applying to role.
+func (cfg *config) mappings(role string, haveMaster bool) []collector.MappingRule {
+	var rules []collector.MappingRule
+	for _, r := range cfg.Mappings {
+		switch {
+		case r.Role == role:
+		case r.Role == "" && (role == collector.RoleMaster || !haveMaster):
+		default:
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// intervals returns the parsed Intervals.
+func (cfg *config) intervals() map[string]time.Duration {
+	intervals := map[string]time.Duration{}
+	for name, v := range cfg.Intervals {
+		// Validated when loading.
+		intervals[name], _ = time.ParseDuration(v)
+	}
+	return intervals
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// configInfoFlags are the flags exported as labels of
+// mesos_exporter_config_info, named with underscores.
+var configInfoFlags = []string{
+	"scrape-mode",
+	"timeout",
+	"scrape-timeout",
+	"leader-only",
+	"include-inactive-frameworks",
+	"completed-task-retention",
+	"max-completed-tasks",
+}
+
+// newConfigInfo returns a gauge of 1 labeled with the effective configuration
+// of the exporter: the enabled collectors, the filters and timeouts, and a
+// hash of all flags and the configuration file, so replicas whose
+// configuration drifted apart stand out.
+func newConfigInfo(fs *flag.FlagSet, cfg *config, collectors []string) (prometheus.Gauge, error) {
+	h := sha256.New()
+	// VisitAll visits the flags in lexicographical order, with their
+	// defaults if unset.
+	fs.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(h, "-%s=%s\n", f.Name, f.Value)
+	})
+	if err := json.NewEncoder(h).Encode(cfg); err != nil {
+		return nil, err
+	}
+
+	labels := prometheus.Labels{
+		"collectors": strings.Join(collectors, ","),
+		"hash":       hex.EncodeToString(h.Sum(nil)[:8]),
+	}
+	for _, name := range configInfoFlags {
+		if f := fs.Lookup(name); f != nil {
+			labels[strings.ReplaceAll(name, "-", "_")] = f.Value.String()
+		}
+	}
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "mesos_exporter_config_info",
+		Help:        "Effective configuration of the exporter, with a hash of all flags and the configuration file.",
+		ConstLabels: labels,
+	})
+	g.Set(1)
+	return g, nil
+}
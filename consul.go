@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// consulConfig configures the registration of the exporter as service in
+// the Consul agent at URL, by default the local one. Requests can be
+// authenticated with an ACL token in bearer_token_file.
+type consulConfig struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+	// Address is the address the service is registered with, by default
+	// the hostname of the exporter.
+	Address string   `json:"address"`
+	Cluster string   `json:"cluster"`
+	Tags    []string `json:"tags"`
+	// CheckInterval is the interval of the HTTP health check.
+	CheckInterval string `json:"check_interval"`
+	httpClientConfig
+}
+
+func (c *consulConfig) validate() error {
+	if c.CheckInterval != "" {
+		if _, err := time.ParseDuration(c.CheckInterval); err != nil {
+			return fmt.Errorf("check_interval: %s", err)
+		}
+	}
+	return c.httpClientConfig.validate()
+}
+
+// consulService is the service definition of the Consul agent API.
+type consulService struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   consulCheck       `json:"Check"`
+}
+
+type consulCheck struct {
+	HTTP                           string `json:"HTTP"`
+	Interval                       string `json:"Interval"`
+	Timeout                        string `json:"Timeout"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// consulRegistration is the registration of the exporter in Consul.
+type consulRegistration struct {
+	url     string
+	client  *http.Client
+	service consulService
+}
+
+// newConsulRegistration returns the registration of the exporter listening
+// on addr, tagged with the scrape mode.
+func newConsulRegistration(cfg consulConfig, addr, mode string, timeout time.Duration) (*consulRegistration, error) {
+	client, err := cfg.newClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port of %s: %s", addr, err)
+	}
+	if cfg.Address != "" {
+		host = cfg.Address
+	} else if host == "" || net.ParseIP(host).IsUnspecified() {
+		if host, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:8500"
+	}
+	if cfg.Name == "" {
+		cfg.Name = "mesos-exporter"
+	}
+	if cfg.CheckInterval == "" {
+		cfg.CheckInterval = "10s"
+	}
+
+	tags := append([]string{"mode=" + mode}, cfg.Tags...)
+	meta := map[string]string{"mode": mode}
+	if cfg.Cluster != "" {
+		tags = append(tags, "cluster="+cfg.Cluster)
+		meta["cluster"] = cfg.Cluster
+	}
+	hostPort := net.JoinHostPort(host, p)
+	return &consulRegistration{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		client: client,
+		service: consulService{
+			ID:      cfg.Name + "-" + hostPort,
+			Name:    cfg.Name,
+			Address: host,
+			Port:    port,
+			Tags:    tags,
+			Meta:    meta,
+			Check: consulCheck{
+				HTTP:                           "http://" + hostPort + "/-/healthy",
+				Interval:                       cfg.CheckInterval,
+				Timeout:                        timeout.String(),
+				DeregisterCriticalServiceAfter: "10m",
+			},
+		},
+	}, nil
+}
+
+func (c *consulRegistration) register() error {
+	body, err := json.Marshal(c.service)
+	if err != nil {
+		return err
+	}
+	return c.put("/v1/agent/service/register", body)
+}
+
+func (c *consulRegistration) deregister() error {
+	return c.put("/v1/agent/service/deregister/"+url.PathEscape(c.service.ID), nil)
+}
+
+func (c *consulRegistration) put(path string, body []byte) error {
+	req, err := http.NewRequest("PUT", c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// deregisterOnSignal deregisters the service and exits once the exporter is
+// asked to terminate.
+func (c *consulRegistration) deregisterOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		s := <-sig
+		if err := c.deregister(); err != nil {
+			log.Printf("Error deregistering %s from Consul: %s", c.service.ID, err)
+		}
+		log.Printf("Exiting on %s", s)
+		os.Exit(0)
+	}()
+}
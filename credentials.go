@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// mesosCredential is a principal and secret Mesos authenticates HTTP
+// requests of with basic authentication.
+type mesosCredential struct {
+	Principal string `json:"principal"`
+	Secret    string `json:"secret"`
+}
+
+// parseMesosCredentials parses credentials in the formats of the
+// --credential and --credentials flags of Mesos: a JSON object with a
+// principal and secret, a JSON object with a list of them as credentials, or
+// lines of a principal and secret separated by whitespace.
+func parseMesosCredentials(data []byte) ([]mesosCredential, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var v struct {
+			mesosCredential
+			Credentials []mesosCredential `json:"credentials"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		creds := v.Credentials
+		if v.Principal != "" {
+			creds = append([]mesosCredential{v.mesosCredential}, creds...)
+		}
+		if len(creds) == 0 {
+			return nil, fmt.Errorf("no credentials found")
+		}
+		return creds, nil
+	}
+
+	var creds []mesosCredential
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 2:
+			creds = append(creds, mesosCredential{Principal: fields[0], Secret: fields[1]})
+		default:
+			return nil, fmt.Errorf("line %d: expected a principal and a secret", i+1)
+		}
+	}
+	if len(creds) == 0 {
+		return nil, fmt.Errorf("no credentials found")
+	}
+	return creds, nil
+}
+
+// mesosCredentialsClient returns a client authenticating requests to Mesos
+// with the first credential in the file at path, which like the default
+// client of the collectors doesn't follow redirects. The file is read on
+// every request, so it can be rotated without restarting the exporter.
+func mesosCredentialsClient(path string, timeout time.Duration) (*http.Client, error) {
+	if _, err := readMesosCredential(path); err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &mesosCredentialsRoundTripper{next: http.DefaultTransport, path: path},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+func readMesosCredential(path string) (mesosCredential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mesosCredential{}, err
+	}
+	creds, err := parseMesosCredentials(data)
+	if err != nil {
+		return mesosCredential{}, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return creds[0], nil
+}
+
+type mesosCredentialsRoundTripper struct {
+	next http.RoundTripper
+	path string
+}
+
+func (rt *mesosCredentialsRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	cred, err := readMesosCredential(rt.path)
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.SetBasicAuth(cred.Principal, cred.Secret)
+	return rt.next.RoundTrip(r)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// dashboardPanel is a graph of the generated dashboard. Its query is a
+// format string taking the names of metrics, which are the names the
+// exporter would use and get renamed as configured.
+type dashboardPanel struct {
+	title   string
+	unit    string
+	legend  string
+	expr    string
+	metrics []string
+}
+
+// dashboardRow is a group of panels shown for an enabled collector.
+type dashboardRow struct {
+	collector string
+	title     string
+	panels    []dashboardPanel
+}
+
+// dashboardRows returns the panels of the dashboard. With taskIdentity, task
+// metrics are broken down by the app_id label.
+func dashboardRows(taskIdentity bool) []dashboardRow {
+	taskMem := dashboardPanel{"Task memory by framework", "bytes", "{{framework}}", "sum by (framework) (%s)", []string{"mesos_task_mem_limit_bytes"}}
+	if taskIdentity {
+		taskMem = dashboardPanel{"Task memory by app", "bytes", "{{app_id}}", `sum by (app_id) (%s{app_id!=""})`, []string{"mesos_task_mem_limit_bytes"}}
+	}
+	return []dashboardRow{
+		{"master", "Cluster", []dashboardPanel{
+			{"CPUs", "short", "{{type}}", "sum by (type) (%s)", []string{"mesos_master_cpus"}},
+			{"Memory", "bytes", "{{type}}", "sum by (type) (%s)", []string{"mesos_master_mem_bytes"}},
+			{"Slaves", "short", "{{connection_state}}", "sum by (connection_state) (%s)", []string{"mesos_master_slaves_state"}},
+			{"Tasks", "short", "{{state}}", "sum by (state) (%s)", []string{"mesos_master_task_states_current"}},
+			{"Finished tasks", "ops", "{{state}} {{reason}}", "sum by (state, reason) (rate(%s[5m]))", []string{"mesos_tasks_finished_total"}},
+			{"Slave CPU usage", "percentunit", "{{hostname}}", "%s / %s * on (slave) group_left (hostname) %s", []string{"mesos_slave_cpus_used", "mesos_slave_cpus", "mesos_slave_info"}},
+			taskMem,
+		}},
+		{"slave", "Slave", []dashboardPanel{
+			{"Tasks", "short", "{{state}}", "sum by (state) (%s)", []string{"mesos_slave_task_states_current"}},
+			{"Container CPU usage", "short", "{{framework_id}}", "sum by (framework_id) (rate(%s[5m]) + rate(%s[5m]))", []string{"mesos_executor_cpu_user_seconds_total", "mesos_executor_cpu_system_seconds_total"}},
+			{"Container memory", "bytes", "{{framework_id}}", "sum by (framework_id) (%s)", []string{"mesos_executor_mem_rss_bytes"}},
+		}},
+		{"marathon", "Marathon", []dashboardPanel{
+			{"App tasks", "short", "{{state}}", "sum by (state) (%s)", []string{"mesos_marathon_app_tasks"}},
+			{"Deployments", "short", "deployments", "sum(%s)", []string{"mesos_marathon_app_deployments"}},
+		}},
+		{"metronome", "Metronome", []dashboardPanel{
+			{"Time since last success", "s", "{{job}}", "time() - %s", []string{"mesos_metronome_job_last_success_timestamp_seconds"}},
+			{"Active runs", "short", "{{job}}", "sum by (job) (%s)", []string{"mesos_metronome_job_active_runs"}},
+		}},
+		{"aurora", "Aurora", []dashboardPanel{
+			{"Tasks", "short", "{{state}}", "%s", []string{"mesos_aurora_tasks"}},
+			{"Failed tasks by job", "ops", "{{role}}/{{environment}}/{{job}}", `sum by (role, environment, job) (rate(%s{state="FAILED"}[5m]))`, []string{"mesos_aurora_job_task_transitions_total"}},
+		}},
+		{"singularity", "Singularity", []dashboardPanel{
+			{"Tasks", "short", "{{state}}", "%s", []string{"mesos_singularity_tasks"}},
+			{"Requests", "short", "{{state}}", "%s", []string{"mesos_singularity_requests"}},
+		}},
+	}
+}
+
+// dashboard prints a Grafana dashboard of the metrics of the given
+// collectors, named as configured by the configuration file.
+func dashboard(args []string) {
+	fs := flag.NewFlagSet("mesos-exporter dashboard", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to the configuration file of the exporter, whose metric renames are applied")
+	collectors := fs.String("collectors", "master,slave", "Comma separated collectors to add panels for: master, slave, marathon, metronome, aurora or singularity")
+	taskIdentity := fs.Bool("task-identity", false, "Break task metrics down by the app_id label, as exported with -task-identity")
+	title := fs.String("title", "Mesos", "Title of the dashboard")
+
+	fs.Parse(args)
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+	d, err := newDashboard(*title, strings.Split(*collectors, ","), *taskIdentity, cfg.Metrics)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newDashboard returns the Grafana dashboard JSON model with a row of panels
+// per collector, taking the Prometheus datasource as variable.
+func newDashboard(title string, collectors []string, taskIdentity bool, overrides map[string]metricOverride) (map[string]interface{}, error) {
+	rows := map[string]dashboardRow{}
+	for _, r := range dashboardRows(taskIdentity) {
+		rows[r.collector] = r
+	}
+
+	datasource := map[string]string{"type": "prometheus", "uid": "${datasource}"}
+	var (
+		panels []interface{}
+		y      int
+	)
+	for _, name := range collectors {
+		r, ok := rows[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		panels = append(panels, map[string]interface{}{
+			"id":        len(panels) + 1,
+			"type":      "row",
+			"title":     r.title,
+			"collapsed": false,
+			"gridPos":   map[string]int{"x": 0, "y": y, "w": 24, "h": 1},
+		})
+		y++
+		for i, p := range r.panels {
+			names := make([]interface{}, len(p.metrics))
+			for j, m := range p.metrics {
+				if o, ok := overrides[m]; ok && o.Name != "" {
+					m = o.Name
+				}
+				names[j] = m
+			}
+			panels = append(panels, map[string]interface{}{
+				"id":         len(panels) + 1,
+				"type":       "timeseries",
+				"title":      p.title,
+				"datasource": datasource,
+				"gridPos":    map[string]int{"x": i % 2 * 12, "y": y + i/2*8, "w": 12, "h": 8},
+				"fieldConfig": map[string]interface{}{
+					"defaults":  map[string]string{"unit": p.unit},
+					"overrides": []interface{}{},
+				},
+				"targets": []interface{}{map[string]interface{}{
+					"datasource":   datasource,
+					"expr":         fmt.Sprintf(p.expr, names...),
+					"legendFormat": p.legend,
+					"refId":        "A",
+				}},
+			})
+		}
+		y += (len(r.panels) + 1) / 2 * 8
+	}
+
+	return map[string]interface{}{
+		"title":         title,
+		"schemaVersion": 39,
+		"editable":      true,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{map[string]interface{}{
+				"name":  "datasource",
+				"label": "Datasource",
+				"type":  "datasource",
+				"query": "prometheus",
+			}},
+		},
+		"panels": panels,
+	}, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dcosConfig configures authentication with a DC/OS cluster, whose Admin
+// Router proxies the leading master at /mesos and agents at /agent/<id>, so
+// the exporter can poll them from outside the cluster network. At most one
+// of the token file, the service account secret and the Vault secret may be
+// given.
+type dcosConfig struct {
+	// TokenFile holds an authentication token, e.g. from
+	// `dcos config show core.dcos_acs_token`. It's read on every request.
+	TokenFile string `json:"token_file"`
+	// ServiceAccountFile holds the secret of a service account, as created
+	// by `dcos security org service-accounts create`, which is used to log
+	// in whenever the cluster rejects the current token.
+	ServiceAccountFile string `json:"service_account_file"`
+	// VaultSecret is the path of a secret in Vault, holding either a token
+	// in its token field or the fields of a service account secret.
+	VaultSecret string    `json:"vault_secret"`
+	TLSConfig   tlsConfig `json:"tls_config"`
+}
+
+// dcosServiceAccount is the secret of a DC/OS service account.
+type dcosServiceAccount struct {
+	UID           string `json:"uid"`
+	PrivateKey    string `json:"private_key"`
+	LoginEndpoint string `json:"login_endpoint"`
+}
+
+func (c *dcosConfig) validate() error {
+	n := 0
+	for _, s := range []string{c.TokenFile, c.ServiceAccountFile, c.VaultSecret} {
+		if s != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("at most one of token_file, service_account_file and vault_secret may be set")
+	}
+	return c.TLSConfig.validate()
+}
+
+// newClient returns a client for polling Mesos through Admin Router, which
+// like the default client of the collectors doesn't follow redirects. vault
+// reads the Vault secret, if any.
+func (c *dcosConfig) newClient(timeout time.Duration, vault *vaultClient) (*http.Client, error) {
+	t, err := c.TLSConfig.transport()
+	if err != nil {
+		return nil, err
+	}
+	rt := &dcosRoundTripper{next: t, tokenFile: c.TokenFile}
+	if f := c.ServiceAccountFile; f != "" {
+		if rt.account, rt.key, err = loadServiceAccount(f); err != nil {
+			return nil, fmt.Errorf("loading %s: %s", f, err)
+		}
+	}
+	if c.VaultSecret != "" {
+		rt.vault = &vaultSecret{vault: vault, path: c.VaultSecret}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: rt,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+func loadServiceAccount(path string) (*dcosServiceAccount, *rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseServiceAccount(data)
+}
+
+func parseServiceAccount(data []byte) (*dcosServiceAccount, *rsa.PrivateKey, error) {
+	var a dcosServiceAccount
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, nil, err
+	}
+	if a.UID == "" || a.LoginEndpoint == "" {
+		return nil, nil, fmt.Errorf("uid and login_endpoint are required")
+	}
+	block, _ := pem.Decode([]byte(a.PrivateKey))
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM encoded private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &a, key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("private key isn't an RSA key")
+	}
+	return &a, rsaKey, nil
+}
+
+// dcosRoundTripper authenticates requests with a DC/OS authentication token.
+// With a service account, it logs in before the first request and again
+// once a request is rejected, which is then retried with the new token.
+type dcosRoundTripper struct {
+	next      http.RoundTripper
+	tokenFile string
+	account   *dcosServiceAccount
+	key       *rsa.PrivateKey
+	vault     *vaultSecret
+
+	mu sync.Mutex
+	// token is the token the service account with uid logged in with.
+	token, uid string
+}
+
+func (rt *dcosRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := rt.currentToken(r, "")
+	if err != nil {
+		return nil, err
+	}
+	res, err := rt.send(r, token)
+	if err != nil || res.StatusCode != http.StatusUnauthorized || (rt.account == nil && rt.vault == nil) || r.Body != nil {
+		return res, err
+	}
+	res.Body.Close()
+	if token, err = rt.currentToken(r, token); err != nil {
+		return nil, err
+	}
+	return rt.send(r, token)
+}
+
+func (rt *dcosRoundTripper) send(r *http.Request, token string) (*http.Response, error) {
+	if token != "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "token="+token)
+	}
+	return rt.next.RoundTrip(r)
+}
+
+// currentToken returns the token to authenticate r with. If rejected is
+// the current token, the service account logs in again.
+func (rt *dcosRoundTripper) currentToken(r *http.Request, rejected string) (string, error) {
+	if rt.tokenFile != "" {
+		b, err := os.ReadFile(rt.tokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	account, key := rt.account, rt.key
+	if rt.vault != nil {
+		data, err := rt.vault.get()
+		if err != nil {
+			return "", err
+		}
+		if token := secretString(data, "token"); token != "" {
+			return token, nil
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		if account, key, err = parseServiceAccount(b); err != nil {
+			return "", fmt.Errorf("Vault secret %s: %s", rt.vault.path, err)
+		}
+	}
+	if account == nil {
+		return "", nil
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.token != "" && rt.token != rejected && rt.uid == account.UID {
+		return rt.token, nil
+	}
+	token, err := rt.login(r, account, key)
+	if err != nil {
+		return "", fmt.Errorf("logging in as %s: %s", account.UID, err)
+	}
+	rt.token, rt.uid = token, account.UID
+	return token, nil
+}
+
+// login exchanges a short-lived login token signed with the private key of
+// the service account for an authentication token.
+func (rt *dcosRoundTripper) login(r *http.Request, account *dcosServiceAccount, key *rsa.PrivateKey) (string, error) {
+	jwt, err := signJWT(key, map[string]interface{}{
+		"uid": account.UID,
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]string{"uid": account.UID, "token": jwt})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(r.Context(), "POST", account.LoginEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	var v struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return "", err
+	}
+	return v.Token, nil
+}
+
+// signJWT returns a JSON web token of claims signed with RS256.
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// dump prints the state of the given master as seen by the exporter, after
+// normalization and filtering.
+func dump(args []string) {
+	fs := flag.NewFlagSet("mesos-exporter dump", flag.ExitOnError)
+	targetURL := fs.String("target", "", "Dump state of master running on this URL")
+	timeout := fs.Duration("timeout", 5*time.Second, "Master polling timeout")
+	statePath := fs.String("state-path", "/state", "Path of the master state endpoint, relative to the master URL")
+	completedRetention := fs.Duration("completed-task-retention", 0, "Only dump completed tasks which finished within this duration, 0 dumps all")
+	includeInactive := fs.Bool("include-inactive-frameworks", false, "Also dump tasks of inactive and disconnected frameworks")
+	maxCompleted := fs.Int("max-completed-tasks", 0, "Only dump the most recent completed tasks up to this number per framework, 0 dumps all")
+	mesosCredentials := fs.String("mesos-credentials", "", "Authenticate requests to the master with the first principal and secret in this file, in the format of the --credentials flag of Mesos")
+
+	fs.Parse(args)
+	if *targetURL == "" {
+		log.Fatal("-target is required")
+	}
+
+	var client *http.Client
+	if *mesosCredentials != "" {
+		var err error
+		if client, err = mesosCredentialsClient(*mesosCredentials, *timeout); err != nil {
+			log.Fatalf("Error loading Mesos credentials: %s", err)
+		}
+	}
+
+	opts := collector.StateOptions{
+		Options: collector.Options{Timeout: *timeout, Client: client},
+		Path:    *statePath,
+		Filter: collector.StateFilter{
+			IncludeInactive:    *includeInactive,
+			CompletedRetention: *completedRetention,
+			MaxCompleted:       *maxCompleted,
+		},
+	}
+	st, err := collector.NewMasterStateCollector(*targetURL, opts).FetchState(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(st); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// electionConfig elects one of several replicas of the exporter, which
+// holds a lock on Key in the Consul KV store at URL, by default that of the
+// local agent. Requests can be authenticated with an ACL token in
+// bearer_token_file.
+type electionConfig struct {
+	URL string `json:"url"`
+	Key string `json:"key"`
+	// TTL is the time after which a replica which stopped renewing its
+	// session loses the lock, 15s if empty.
+	TTL string `json:"ttl"`
+	httpClientConfig
+}
+
+func (c *electionConfig) validate() error {
+	if c.TTL != "" {
+		ttl, err := time.ParseDuration(c.TTL)
+		if err != nil {
+			return fmt.Errorf("ttl: %s", err)
+		}
+		// Consul rejects shorter session TTLs.
+		if ttl < 10*time.Second {
+			return fmt.Errorf("ttl: %s is less than 10s", c.TTL)
+		}
+	}
+	return c.httpClientConfig.validate()
+}
+
+// elector takes part in the election of the replica which scrapes Mesos,
+// holding the lock with a Consul session it renews every half TTL.
+type elector struct {
+	url      string
+	key      string
+	ttl      time.Duration
+	client   *http.Client
+	identity string
+	desc     *prometheus.Desc
+
+	mu      sync.Mutex
+	session string
+	elected bool
+}
+
+func newElector(cfg electionConfig, identity string, timeout time.Duration) (*elector, error) {
+	client, err := cfg.newClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:8500"
+	}
+	if cfg.Key == "" {
+		cfg.Key = "service/mesos-exporter/leader"
+	}
+	ttl := 15 * time.Second
+	if cfg.TTL != "" {
+		// Validated when loading.
+		ttl, _ = time.ParseDuration(cfg.TTL)
+	}
+	return &elector{
+		url:      strings.TrimSuffix(cfg.URL, "/"),
+		key:      strings.TrimPrefix(cfg.Key, "/"),
+		ttl:      ttl,
+		client:   client,
+		identity: identity,
+		desc: prometheus.NewDesc(
+			"mesos_exporter_elected",
+			"Whether this replica of the exporter holds the lock and scrapes Mesos, while the others stand by.",
+			nil, nil,
+		),
+	}, nil
+}
+
+// run takes part in the election every half TTL, after the first campaign.
+func (e *elector) run() {
+	for range time.Tick(e.ttl / 2) {
+		if err := e.campaign(); err != nil {
+			log.Printf("Error taking part in the election: %s", err)
+		}
+	}
+}
+
+// campaign renews the session, or creates a new one if it expired, and
+// tries to acquire the lock with it.
+func (e *elector) campaign() error {
+	e.mu.Lock()
+	session, wasElected := e.session, e.elected
+	e.mu.Unlock()
+
+	if session != "" {
+		if err := e.do("PUT", "/v1/session/renew/"+session, nil, nil); err != nil {
+			log.Printf("Error renewing Consul session %s, creating a new one: %s", session, err)
+			session = ""
+		}
+	}
+	if session == "" {
+		body, _ := json.Marshal(map[string]string{
+			"Name":      "mesos-exporter " + e.identity,
+			"TTL":       e.ttl.String(),
+			"Behavior":  "release",
+			"LockDelay": "1s",
+		})
+		var created struct{ ID string }
+		if err := e.do("PUT", "/v1/session/create", body, &created); err != nil {
+			e.set("", false, wasElected)
+			return err
+		}
+		session = created.ID
+	}
+
+	var acquired bool
+	err := e.do("PUT", "/v1/kv/"+e.key+"?acquire="+url.QueryEscape(session), []byte(e.identity), &acquired)
+	e.set(session, err == nil && acquired, wasElected)
+	return err
+}
+
+func (e *elector) set(session string, elected, wasElected bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.session, e.elected = session, elected
+	switch {
+	case elected && !wasElected:
+		log.Printf("Elected to scrape Mesos, holding %s", e.key)
+	case !elected && wasElected:
+		log.Printf("Standing by, lost %s", e.key)
+	}
+}
+
+func (e *elector) isElected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.elected
+}
+
+func (e *elector) do(method, path string, body []byte, v interface{}) error {
+	req, err := http.NewRequest(method, e.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (e *elector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.desc
+}
+
+func (e *elector) Collect(ch chan<- prometheus.Metric) {
+	v := 0.0
+	if e.isElected() {
+		v = 1
+	}
+	ch <- prometheus.MustNewConstMetric(e.desc, prometheus.GaugeValue, v)
+}
+
+// electionGatherer gathers all metrics while the replica is elected, and
+// only those of standby, e.g. whether it's elected, while it stands by.
+type electionGatherer struct {
+	prometheus.Gatherer
+	standby prometheus.Gatherer
+	elector *elector
+}
+
+func (g electionGatherer) Gather() ([]*dto.MetricFamily, error) {
+	if !g.elector.isElected() {
+		return g.standby.Gather()
+	}
+	return overlayGatherers{g.Gatherer, g.standby}.Gather()
+}
+
+// electionIdentity is what the elected replica writes to the lock key, its
+// hostname and listen address.
+func electionIdentity(addr string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + addr
+}
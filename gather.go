@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// overlayGatherers gathers from all of its members in order. Families
+// gathered from several members are merged if they agree on type, help and
+// label names, like the per collector mesos_collector_up series, and taken
+// from the earliest member otherwise. Unlike prometheus.Gatherers it
+// tolerates members exporting the same family with different labels or help.
+type overlayGatherers []prometheus.Gatherer
+
+func (gs overlayGatherers) Gather() ([]*dto.MetricFamily, error) {
+	var (
+		mfs  []*dto.MetricFamily
+		seen = map[string]*dto.MetricFamily{}
+		errs prometheus.MultiError
+	)
+	for _, g := range gs {
+		gathered, err := g.Gather()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, mf := range gathered {
+			if prev, ok := seen[mf.GetName()]; ok {
+				if mergeable(prev, mf) {
+					prev.Metric = append(prev.Metric, mf.Metric...)
+				}
+				continue
+			}
+			seen[mf.GetName()] = mf
+			mfs = append(mfs, mf)
+		}
+	}
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	return mfs, errs.MaybeUnwrap()
+}
+
+// mergeable reports whether the metrics of b can be added to a without
+// creating inconsistent or duplicate series.
+func mergeable(a, b *dto.MetricFamily) bool {
+	if a.GetType() != b.GetType() || a.GetHelp() != b.GetHelp() {
+		return false
+	}
+	if len(a.Metric) == 0 || len(b.Metric) == 0 {
+		return true
+	}
+	if labelNames(a.Metric[0]) != labelNames(b.Metric[0]) {
+		return false
+	}
+	series := map[string]bool{}
+	for _, m := range a.Metric {
+		series[labelValues(m)] = true
+	}
+	for _, m := range b.Metric {
+		if series[labelValues(m)] {
+			return false
+		}
+	}
+	return true
+}
+
+func labelNames(m *dto.Metric) string {
+	var names []string
+	for _, l := range m.Label {
+		names = append(names, l.GetName())
+	}
+	return strings.Join(names, "\xff")
+}
+
+func labelValues(m *dto.Metric) string {
+	var values []string
+	for _, l := range m.Label {
+		values = append(values, l.GetValue())
+	}
+	return strings.Join(values, "\xff")
+}
+
+// renamingGatherer applies metric overrides to the families gathered from the
+// wrapped Gatherer.
+type renamingGatherer struct {
+	prometheus.Gatherer
+	overrides map[string]metricOverride
+}
+
+func (g renamingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	if len(g.overrides) == 0 {
+		return mfs, err
+	}
+	for _, mf := range mfs {
+		o, ok := g.overrides[mf.GetName()]
+		if !ok {
+			continue
+		}
+		if o.Name != "" {
+			name := o.Name
+			mf.Name = &name
+		}
+		if o.Help != "" {
+			help := o.Help
+			mf.Help = &help
+		}
+	}
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	return mfs, err
+}
+
+// sanitizingGatherer replaces invalid UTF-8 and control characters in the
+// label values of the families gathered from the wrapped Gatherer, which
+// break tools consuming the exposition even if it's escaped correctly.
+// Values longer than maxLength bytes are shortened, keeping them unique by
+// replacing their end with a hash of the full value. Series whose sanitized
+// values collide with those of another series get a hash of their original
+// values appended as well.
+type sanitizingGatherer struct {
+	prometheus.Gatherer
+	maxLength int
+}
+
+func (g sanitizingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	type change struct {
+		label *dto.LabelPair
+		orig  string
+	}
+	for _, mf := range mfs {
+		changes := make([][]change, len(mf.Metric))
+		series := make([]string, len(mf.Metric))
+		seen := map[string]int{}
+		for i, m := range mf.Metric {
+			for _, l := range m.Label {
+				if v := g.sanitize(l.GetValue()); v != l.GetValue() {
+					changes[i] = append(changes[i], change{l, l.GetValue()})
+					l.Value = &v
+				}
+			}
+			series[i] = labelValues(m)
+			seen[series[i]]++
+		}
+		for i := range mf.Metric {
+			if seen[series[i]] < 2 {
+				continue
+			}
+			for _, c := range changes[i] {
+				v := g.hashed(g.clean(c.orig), c.orig)
+				c.label.Value = &v
+			}
+		}
+	}
+	return mfs, err
+}
+
+// hashLength is the length of the hash suffix of shortened label values.
+const hashLength = 1 + 16
+
+func (g sanitizingGatherer) sanitize(v string) string {
+	v = g.clean(v)
+	if g.maxLength <= hashLength || len(v) <= g.maxLength {
+		return v
+	}
+	return g.hashed(v, v)
+}
+
+// clean replaces invalid UTF-8 and control characters in v.
+func (g sanitizingGatherer) clean(v string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return utf8.RuneError
+		}
+		return r
+	}, strings.ToValidUTF8(v, string(utf8.RuneError)))
+}
+
+// hashed appends the hash of key to v, shortening v to keep the result
+// within maxLength.
+func (g sanitizingGatherer) hashed(v, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	prefix := v
+	if g.maxLength > hashLength && len(prefix) > g.maxLength-hashLength {
+		prefix = prefix[:g.maxLength-hashLength]
+	}
+	// Don't cut a multibyte character in half.
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix + "~" + hex.EncodeToString(sum[:8])
+}
+
+// legacyGatherer also exports the families gathered from the wrapped
+// Gatherer under their legacy names, labels and units, for dashboards and
+// alerts which haven't been migrated yet. Like overlayGatherers it drops
+// legacy families whose name is taken by another family, e.g.
+// mesos_slave_cpus of the slave collector in favor of that of the master
+// state.
+type legacyGatherer struct {
+	prometheus.Gatherer
+	names map[string]collector.LegacyName
+}
+
+func (g legacyGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	taken := map[string]bool{}
+	for _, mf := range mfs {
+		taken[mf.GetName()] = true
+	}
+	for _, mf := range mfs {
+		legacy, ok := g.names[mf.GetName()]
+		if !ok || taken[legacy.Name] {
+			continue
+		}
+		lmf := proto.Clone(mf).(*dto.MetricFamily)
+		name := legacy.Name
+		lmf.Name = &name
+		for _, m := range lmf.Metric {
+			for _, l := range m.Label {
+				if n, ok := legacy.Labels[l.GetName()]; ok {
+					l.Name = &n
+					if v, ok := legacy.Values[l.GetValue()]; ok {
+						l.Value = &v
+					}
+				}
+			}
+			sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+			if legacy.Scale != 0 && m.Gauge != nil {
+				v := m.Gauge.GetValue() * legacy.Scale
+				m.Gauge.Value = &v
+			}
+		}
+		taken[name] = true
+		mfs = append(mfs, lmf)
+	}
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	return mfs, err
+}
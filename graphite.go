@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/graphite"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// graphiteOutput returns a function sending metrics to a Graphite server
+// using the plaintext protocol. Labels are appended to the metric path as
+// name and value pairs, or sent as Graphite tags if useTags is set.
+func graphiteOutput(addr, prefix string, useTags bool, timeout time.Duration) func([]*dto.MetricFamily) error {
+	return func(mfs []*dto.MetricFamily) error {
+		b, err := graphite.NewBridge(&graphite.Config{
+			URL:           addr,
+			Prefix:        prefix,
+			UseTags:       useTags,
+			Timeout:       timeout,
+			Gatherer:      prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil }),
+			ErrorHandling: graphite.AbortOnError,
+		})
+		if err != nil {
+			return err
+		}
+		return b.Push()
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// statusService is the full name of the gRPC status service.
+const statusService = "mesos.exporter.v1.Status"
+
+// statusFile describes the messages and the service of the status API, see
+// the README for its .proto form. It's built at runtime and its messages are
+// dynamic, to get by without generated code.
+var statusFile = func() protoreflect.FileDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	repeated := func(name string, number int32, message string) *descriptorpb.FieldDescriptorProto {
+		f := field(name, number, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		f.TypeName = proto.String(".mesos.exporter.v1." + message)
+		return f
+	}
+	message := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+	}
+	method := func(name string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".mesos.exporter.v1." + name + "Request"),
+			OutputType: proto.String(".mesos.exporter.v1." + name + "Response"),
+		}
+	}
+	var (
+		str     = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		boolean = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		double  = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		int64_  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+	)
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("mesos_exporter/v1/status.proto"),
+		Package: proto.String("mesos.exporter.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("Agent",
+				field("id", 1, str),
+				field("hostname", 2, str),
+				field("active", 3, boolean),
+				field("cpus", 4, double),
+				field("cpus_used", 5, double),
+				field("mem_bytes", 6, double),
+				field("mem_used_bytes", 7, double),
+				field("disk_bytes", 8, double),
+				field("disk_used_bytes", 9, double),
+			),
+			message("Framework",
+				field("id", 1, str),
+				field("name", 2, str),
+				field("active", 3, boolean),
+				field("tasks", 4, int64_),
+				field("completed_tasks", 5, int64_),
+				field("cpus_used", 6, double),
+				field("mem_used_bytes", 7, double),
+			),
+			message("Task",
+				field("id", 1, str),
+				field("name", 2, str),
+				field("framework_id", 3, str),
+				field("agent_id", 4, str),
+				field("state", 5, str),
+				field("cpus", 6, double),
+				field("mem_bytes", 7, double),
+				field("disk_bytes", 8, double),
+			),
+			message("GetAgentsRequest"),
+			message("GetAgentsResponse", repeated("agents", 1, "Agent")),
+			message("GetFrameworksRequest"),
+			message("GetFrameworksResponse", repeated("frameworks", 1, "Framework")),
+			message("GetTasksRequest",
+				field("framework_id", 1, str),
+				field("state", 2, str),
+			),
+			message("GetTasksResponse", repeated("tasks", 1, "Task")),
+			message("GetClusterSummaryRequest"),
+			message("GetClusterSummaryResponse",
+				field("leader", 1, boolean),
+				field("agents", 2, int64_),
+				field("active_agents", 3, int64_),
+				field("frameworks", 4, int64_),
+				field("active_frameworks", 5, int64_),
+				field("tasks", 6, int64_),
+				field("cpus", 7, double),
+				field("cpus_used", 8, double),
+				field("mem_bytes", 9, double),
+				field("mem_used_bytes", 10, double),
+				field("disk_bytes", 11, double),
+				field("disk_used_bytes", 12, double),
+			),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Status"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetAgents"),
+				method("GetFrameworks"),
+				method("GetTasks"),
+				method("GetClusterSummary"),
+			},
+		}},
+	}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return fd
+}()
+
+// newMessage returns a message of the status API with the given fields set.
+// Values are strings, bools, float64s, int64s or, for repeated fields, slices
+// of messages.
+func newMessage(name string, fields map[string]interface{}) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(statusFile.Messages().ByName(protoreflect.Name(name)))
+	desc := m.Descriptor().Fields()
+	for k, v := range fields {
+		fd := desc.ByName(protoreflect.Name(k))
+		switch v := v.(type) {
+		case []*dynamicpb.Message:
+			l := m.Mutable(fd).List()
+			for _, e := range v {
+				l.Append(protoreflect.ValueOfMessage(e))
+			}
+		default:
+			m.Set(fd, protoreflect.ValueOf(v))
+		}
+	}
+	return m
+}
+
+// statusServer serves the gRPC status API from the master state. The state
+// is cached for maxAge, so frequently polling clients don't each fetch it
+// from the master.
+type statusServer struct {
+	collector *collector.MasterStateCollector
+	scrapes   *scrapeHandler
+	maxAge    time.Duration
+
+	mu      sync.Mutex
+	last    *collector.State
+	fetched time.Time
+}
+
+// newStatusGRPCServer returns a gRPC server with the status API and server
+// reflection, so it can be explored with e.g. grpcurl.
+func newStatusGRPCServer(s *statusServer) *grpc.Server {
+	srv := grpc.NewServer()
+	srv.RegisterService(s.serviceDesc(), s)
+	reflection.Register(srv)
+	return srv
+}
+
+func init() {
+	if err := protoregistry.GlobalFiles.RegisterFile(statusFile); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *statusServer) serviceDesc() *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: statusService,
+		HandlerType: (*interface{})(nil),
+		Metadata:    statusFile.Path(),
+	}
+	for _, m := range []struct {
+		name  string
+		build func(*collector.State, protoreflect.Message) *dynamicpb.Message
+	}{
+		{"GetAgents", agentsResponse},
+		{"GetFrameworks", frameworksResponse},
+		{"GetTasks", tasksResponse},
+		{"GetClusterSummary", clusterSummaryResponse},
+	} {
+		name, build := m.name, m.build
+		in := statusFile.Messages().ByName(protoreflect.Name(name + "Request"))
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: name,
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := dynamicpb.NewMessage(in)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handle := func(ctx context.Context, req interface{}) (interface{}, error) {
+					st, err := s.state(ctx)
+					if err != nil {
+						return nil, status.Error(codes.Unavailable, err.Error())
+					}
+					return build(st, req.(*dynamicpb.Message)), nil
+				}
+				if interceptor == nil {
+					return handle(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: s, FullMethod: "/" + statusService + "/" + name}
+				return interceptor(ctx, req, info, handle)
+			},
+		})
+	}
+	return desc
+}
+
+// state returns the cached master state, fetching it if it's older than
+// maxAge.
+func (s *statusServer) state(ctx context.Context) (*collector.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last != nil && time.Since(s.fetched) < s.maxAge {
+		return s.last, nil
+	}
+
+	var (
+		st  *collector.State
+		err error
+	)
+	// Fetch one at a time with scrapes, like push outputs do.
+	s.scrapes.scrape(0, func() { st, err = s.collector.FetchState(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	s.last, s.fetched = st, time.Now()
+	return st, nil
+}
+
+func agentsResponse(st *collector.State, _ protoreflect.Message) *dynamicpb.Message {
+	var agents []*dynamicpb.Message
+	for _, s := range st.Slaves {
+		agents = append(agents, newMessage("Agent", map[string]interface{}{
+			"id":              s.ID,
+			"hostname":        s.Hostname,
+			"active":          s.Active,
+			"cpus":            s.Total.CPUs,
+			"cpus_used":       s.Used.CPUs,
+			"mem_bytes":       s.Total.Mem * collector.Megabytes,
+			"mem_used_bytes":  s.Used.Mem * collector.Megabytes,
+			"disk_bytes":      s.Total.Disk * collector.Megabytes,
+			"disk_used_bytes": s.Used.Disk * collector.Megabytes,
+		}))
+	}
+	return newMessage("GetAgentsResponse", map[string]interface{}{"agents": agents})
+}
+
+func frameworksResponse(st *collector.State, _ protoreflect.Message) *dynamicpb.Message {
+	var frameworks []*dynamicpb.Message
+	for _, f := range st.Frameworks {
+		used := sumTaskResources(f.Tasks)
+		frameworks = append(frameworks, newMessage("Framework", map[string]interface{}{
+			"id":              f.ID,
+			"name":            f.Name,
+			"active":          f.Active,
+			"tasks":           int64(len(f.Tasks)),
+			"completed_tasks": int64(len(f.Completed)),
+			"cpus_used":       used.CPUs,
+			"mem_used_bytes":  used.Mem * collector.Megabytes,
+		}))
+	}
+	return newMessage("GetFrameworksResponse", map[string]interface{}{"frameworks": frameworks})
+}
+
+func tasksResponse(st *collector.State, req protoreflect.Message) *dynamicpb.Message {
+	fields := req.Descriptor().Fields()
+	frameworkID := req.Get(fields.ByName("framework_id")).String()
+	taskState := req.Get(fields.ByName("state")).String()
+
+	var tasks []*dynamicpb.Message
+	for _, f := range st.Frameworks {
+		if frameworkID != "" && f.ID != frameworkID {
+			continue
+		}
+		for _, ts := range [][]collector.Task{f.Tasks, f.Completed} {
+			for _, t := range ts {
+				if taskState != "" && t.State != taskState {
+					continue
+				}
+				tasks = append(tasks, newMessage("Task", map[string]interface{}{
+					"id":           t.ID,
+					"name":         t.Name,
+					"framework_id": t.FrameworkID,
+					"agent_id":     t.SlaveID,
+					"state":        t.State,
+					"cpus":         t.Resources.CPUs,
+					"mem_bytes":    t.Resources.Mem * collector.Megabytes,
+					"disk_bytes":   t.Resources.Disk * collector.Megabytes,
+				}))
+			}
+		}
+	}
+	return newMessage("GetTasksResponse", map[string]interface{}{"tasks": tasks})
+}
+
+func clusterSummaryResponse(st *collector.State, _ protoreflect.Message) *dynamicpb.Message {
+	var (
+		activeAgents, activeFrameworks, tasks int64
+		total, used                           collector.Resources
+	)
+	for _, s := range st.Slaves {
+		if s.Active {
+			activeAgents++
+		}
+		total.CPUs, total.Mem, total.Disk = total.CPUs+s.Total.CPUs, total.Mem+s.Total.Mem, total.Disk+s.Total.Disk
+		used.CPUs, used.Mem, used.Disk = used.CPUs+s.Used.CPUs, used.Mem+s.Used.Mem, used.Disk+s.Used.Disk
+	}
+	for _, f := range st.Frameworks {
+		if f.Active {
+			activeFrameworks++
+		}
+		tasks += int64(len(f.Tasks))
+	}
+	return newMessage("GetClusterSummaryResponse", map[string]interface{}{
+		"leader":            st.IsLeader(),
+		"agents":            int64(len(st.Slaves)),
+		"active_agents":     activeAgents,
+		"frameworks":        int64(len(st.Frameworks)),
+		"active_frameworks": activeFrameworks,
+		"tasks":             tasks,
+		"cpus":              total.CPUs,
+		"cpus_used":         used.CPUs,
+		"mem_bytes":         total.Mem * collector.Megabytes,
+		"mem_used_bytes":    used.Mem * collector.Megabytes,
+		"disk_bytes":        total.Disk * collector.Megabytes,
+		"disk_used_bytes":   used.Disk * collector.Megabytes,
+	})
+}
+
+// sumTaskResources adds up the scalar resources of tasks.
+func sumTaskResources(tasks []collector.Task) collector.Resources {
+	var r collector.Resources
+	for _, t := range tasks {
+		r.CPUs += t.Resources.CPUs
+		r.Mem += t.Resources.Mem
+		r.Disk += t.Resources.Disk
+	}
+	return r
+}
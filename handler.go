@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeHandler serves metrics one scrape at a time, cancelling upstream
+// fetches shortly before the scraping Prometheus server gives up on the
+// request.
+type scrapeHandler struct {
+	http.Handler
+	// offset is subtracted from the announced scrape timeout to leave time
+	// for encoding and sending the response.
+	offset time.Duration
+	// maxRequests, if positive, limits the requests served or waiting for
+	// the scrape in progress. Requests beyond it are rejected.
+	maxRequests int32
+	// timeout, if positive, bounds scrapes not announcing a shorter one.
+	timeout time.Duration
+	// internal is the Internal of the collectors scraped, nil for the
+	// default one.
+	internal *collector.Internal
+
+	// slot is held by the scrape in progress.
+	slot     chan struct{}
+	initSlot sync.Once
+	inFlight int32
+}
+
+func (h *scrapeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.maxRequests > 0 {
+		defer atomic.AddInt32(&h.inFlight, -1)
+		if atomic.AddInt32(&h.inFlight, 1) > h.maxRequests {
+			http.Error(w, "Too many concurrent scrapes", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if !h.scrape(h.requestTimeout(r), func() { h.Handler.ServeHTTP(w, r) }) {
+		http.Error(w, "Timed out waiting for the scrape in progress", http.StatusServiceUnavailable)
+	}
+}
+
+// requestTimeout returns the timeout announced by the scraper of r, bounded
+// by the timeout of h, if any.
+func (h *scrapeHandler) requestTimeout(r *http.Request) time.Duration {
+	timeout, ok := scrapeTimeout(r)
+	if h.timeout > 0 && (!ok || h.timeout < timeout) {
+		timeout = h.timeout
+	}
+	return timeout
+}
+
+// scrape runs f, one scrape at a time, cancelling upstream fetches shortly
+// before the timeout if one is given. It's also used by push outputs, so
+// they don't collect concurrently with scrapes. It reports false without
+// running f if the deadline passed while waiting for the scrape in progress.
+func (h *scrapeHandler) scrape(timeout time.Duration, f func()) bool {
+	// The deadline runs from the call, so time spent waiting for the scrape
+	// in progress counts against it.
+	deadline := h.deadline(time.Now(), timeout)
+
+	// Overlapping scrapes would only fetch the same data from Mesos twice.
+	if !h.lock(deadline) {
+		return false
+	}
+	defer func() { <-h.slot }()
+
+	ctx, span := tracer.Start(context.Background(), "scrape")
+	defer span.End()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	h.internal.Scrape(ctx, f)
+	return true
+}
+
+// lock waits for the scrape in progress until the deadline, if any, and
+// reports whether it took over.
+func (h *scrapeHandler) lock(deadline time.Time) bool {
+	h.initSlot.Do(func() { h.slot = make(chan struct{}, 1) })
+	if deadline.IsZero() {
+		h.slot <- struct{}{}
+		return true
+	}
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+	select {
+	case h.slot <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// deadline returns the time upstream fetches of a scrape started at now are
+// cancelled, offset before the timeout, or the zero time without timeout.
+// Timeouts not exceeding the offset are used in full, as there would be no
+// time left to fetch anything.
+func (h *scrapeHandler) deadline(now time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	if timeout > h.offset {
+		timeout -= h.offset
+	}
+	return now.Add(timeout)
+}
+
+func scrapeTimeout(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(scrapeTimeoutHeader)
+	if v == "" {
+		return 0, false
+	}
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil || secs <= 0 {
+		return 0, false
+	}
+	return time.Duration(secs * float64(time.Second)), true
+}
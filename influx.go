@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// influxConfig configures pushing to InfluxDB. Version 1 writes to a
+// database and optional retention policy, version 2 to a bucket of an
+// organization, authenticated with the token in TokenFile.
+type influxConfig struct {
+	URL             string `json:"url"`
+	Version         int    `json:"version"`
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retention_policy"`
+	Org             string `json:"org"`
+	Bucket          string `json:"bucket"`
+	TokenFile       string `json:"token_file"`
+	httpClientConfig
+}
+
+func (c *influxConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	switch c.Version {
+	case 0, 1:
+		if c.Database == "" {
+			return fmt.Errorf("database is required")
+		}
+	case 2:
+		if c.Org == "" || c.Bucket == "" {
+			return fmt.Errorf("org and bucket are required")
+		}
+	default:
+		return fmt.Errorf("unknown version %d", c.Version)
+	}
+	if c.TokenFile != "" && (c.BasicAuth != nil || c.BearerTokenFile != "") {
+		return fmt.Errorf("token_file can't be combined with other authentication")
+	}
+	return c.httpClientConfig.validate()
+}
+
+// writeURL returns the URL of the write endpoint.
+func (c *influxConfig) writeURL() string {
+	q := url.Values{"precision": {"ms"}}
+	path := "/write"
+	if c.Version == 2 {
+		path = "/api/v2/write"
+		q.Set("org", c.Org)
+		q.Set("bucket", c.Bucket)
+	} else {
+		q.Set("db", c.Database)
+		if c.RetentionPolicy != "" {
+			q.Set("rp", c.RetentionPolicy)
+		}
+	}
+	return strings.TrimSuffix(c.URL, "/") + path + "?" + q.Encode()
+}
+
+// influxOutput returns a function writing metrics to InfluxDB in line
+// protocol, with one measurement per series name and labels as tags.
+func influxOutput(cfg *influxConfig, timeout time.Duration) (func([]*dto.MetricFamily) error, error) {
+	client, err := cfg.newClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	u := cfg.writeURL()
+	return func(mfs []*dto.MetricFamily) error {
+		req, err := http.NewRequest("POST", u, bytes.NewReader(encodeLineProtocol(toTimeSeries(mfs, time.Now()))))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if cfg.TokenFile != "" {
+			token, err := os.ReadFile(cfg.TokenFile)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Token "+strings.TrimSpace(string(token)))
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+			return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+		}
+		return nil
+	}, nil
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+func encodeLineProtocol(series []timeSeries) []byte {
+	var buf bytes.Buffer
+	for _, s := range series {
+		// InfluxDB rejects NaN and infinite field values.
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		var (
+			name string
+			tags []string
+		)
+		for _, l := range s.labels {
+			switch {
+			case l.name == "__name__":
+				name = l.value
+			// InfluxDB rejects empty tag values.
+			case l.value != "":
+				tags = append(tags, influxTagEscaper.Replace(l.name)+"="+influxTagEscaper.Replace(l.value))
+			}
+		}
+		buf.WriteString(influxMeasurementEscaper.Replace(name))
+		for _, t := range tags {
+			buf.WriteString("," + t)
+		}
+		fmt.Fprintf(&buf, " value=%s %d\n", strconv.FormatFloat(s.value, 'g', -1, 64), s.timestamp)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// kafkaPublisher publishes task events as JSON to a Kafka topic, keyed by
+// framework and task ID, so the events of a task stay in order.
+type kafkaPublisher struct {
+	w *kafka.Writer
+}
+
+func newKafkaPublisher(brokers []string, topic string) *kafkaPublisher {
+	pushErrorCounter.WithLabelValues("kafka")
+	return &kafkaPublisher{w: &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+		// Publishing mustn't hold up scrapes.
+		Async: true,
+		Completion: func(msgs []kafka.Message, err error) {
+			if err != nil {
+				log.Printf("Error publishing %d task events to Kafka: %s", len(msgs), err)
+				pushErrorCounter.WithLabelValues("kafka").Add(float64(len(msgs)))
+			}
+		},
+	}}
+}
+
+func (p *kafkaPublisher) publish(events []collector.TaskEvent) {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		v, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Error encoding task event: %s", err)
+			pushErrorCounter.WithLabelValues("kafka").Inc()
+			continue
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(e.FrameworkID + "/" + e.TaskID), Value: v})
+	}
+	if err := p.w.WriteMessages(context.Background(), msgs...); err != nil {
+		log.Printf("Error publishing task events to Kafka: %s", err)
+		pushErrorCounter.WithLabelValues("kafka").Add(float64(len(msgs)))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// catalogCollectors returns the collectors of the exporter by name, set up as
+// configured, for describing their metric families. They're never collected.
+func catalogCollectors(cfg *config, taskIdentity bool) map[string]prometheus.Collector {
+	const url = "http://localhost"
+	opts := collector.Options{}
+	stateOpts := collector.StateOptions{Buckets: cfg.Buckets, ResourceUnits: cfg.ResourceUnits}
+	if taskIdentity {
+		stateOpts.Enrichers = collector.WellKnownEnrichers
+	}
+	return map[string]prometheus.Collector{
+		"master":          collector.NewMasterCollector(url, opts),
+		"master_state":    collector.NewMasterStateCollector(url, stateOpts),
+		"master_mappings": collector.NewMappingCollector(collector.RoleMaster, url, opts, cfg.mappings(collector.RoleMaster, true)),
+		"maintenance":     collector.NewMaintenanceCollector(url, opts),
+		"overlay_master":  collector.NewOverlayMasterCollector(url, opts),
+		"slave":           collector.NewSlaveCollector(url, opts),
+		"slave_monitor":   collector.NewSlaveMonitorCollector(url, opts),
+		"slave_gc":        collector.NewSlaveGCCollector(url, opts),
+		"slave_top":       collector.NewSlaveTopCollector(url, opts, 1),
+		"slave_mappings":  collector.NewMappingCollector(collector.RoleSlave, url, opts, cfg.mappings(collector.RoleSlave, false)),
+		"overlay_agent":   collector.NewOverlayAgentCollector(url, opts),
+		"marathon":        collector.NewMarathonCollector(url, opts),
+		"metronome":       collector.NewMetronomeCollector(url, opts),
+		"aurora":          collector.NewAuroraCollector(url, opts),
+		"singularity":     collector.NewSingularityCollector(url, opts),
+	}
+}
+
+// catalogEntry is a metric family with the collectors exporting it.
+type catalogEntry struct {
+	collector.Family
+	collectors []string
+}
+
+// catalog returns the families exported by the named collectors, sorted and
+// named as configured.
+func catalog(cfg *config, collectors []string, taskIdentity bool) ([]catalogEntry, error) {
+	all := catalogCollectors(cfg, taskIdentity)
+	byName := map[string]*catalogEntry{}
+	for _, name := range collectors {
+		c, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %s", name)
+		}
+		for _, f := range collector.Families(c) {
+			if o, ok := cfg.Metrics[f.Name]; ok {
+				if o.Name != "" {
+					f.Name = o.Name
+				}
+				if o.Help != "" {
+					f.Help = o.Help
+				}
+			}
+			e, ok := byName[f.Name]
+			if !ok {
+				e = &catalogEntry{Family: f}
+				byName[f.Name] = e
+			}
+			e.collectors = append(e.collectors, name)
+		}
+	}
+
+	var entries []catalogEntry
+	for _, e := range byName {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// listMetrics prints the metric families the given collectors can export.
+func listMetrics(args []string) {
+	fs := flag.NewFlagSet("mesos-exporter list-metrics", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to the configuration file of the exporter, whose renames, mapping rules, buckets and resource units are applied")
+	collectors := fs.String("collectors", "master,master_state,slave,slave_monitor,slave_gc", "Comma separated collectors to list the metrics of, named as in the collector label of mesos_collector_up")
+	taskIdentity := fs.Bool("task-identity", false, "Add the app_id and job_id labels to task metrics, as exported with -task-identity")
+
+	fs.Parse(args)
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+	entries, err := catalog(cfg, strings.Split(*collectors, ","), *taskIdentity)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tLABELS\tCOLLECTORS\tHELP")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Name, e.Type, strings.Join(e.Labels, ","), strings.Join(e.collectors, ","), e.Help)
+	}
+	w.Flush()
+}
@@ -3,65 +3,434 @@ package main
 import (
 	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-)
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 
-var errorCounter = prometheus.NewCounter(prometheus.CounterOpts{
-	Namespace: "mesos",
-	Subsystem: "collector",
-	Name:      "errors_total",
-	Help:      "Total number of internal mesos-collector errors.",
-})
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
 
-func init() {
-	prometheus.MustRegister(errorCounter)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "dump":
+			dump(os.Args[2:])
+			return
+		case "dashboard":
+			dashboard(os.Args[2:])
+			return
+		case "rules":
+			rules(os.Args[2:])
+			return
+		case "list-metrics":
+			listMetrics(os.Args[2:])
+			return
+		}
+	}
+	if runService(serve) {
+		return
+	}
+	serve()
 }
 
-func main() {
+// serve runs the exporter as configured by the flags, until it fails.
+func serve() {
 	fs := flag.NewFlagSet("mesos-exporter", flag.ExitOnError)
-	addr := fs.String("addr", ":9110", "Address to listen on")
+	addr := fs.String("addr", ":9110", "Address to listen on, empty to only push metrics")
+	auroraURL := fs.String("aurora", "", "Also expose scheduler and job metrics from the Aurora scheduler running on this URL")
+	configFile := fs.String("config", "", "Path to an optional JSON configuration file")
 	masterURL := fs.String("master", "", "Expose metrics from master running on this URL")
-	slaveURL := fs.String("slave", "", "Expose metrics from slave running on t his URL")
+	marathonURL := fs.String("marathon", "", "Also expose app metrics from Marathon running on this URL")
+	mesosCredentials := fs.String("mesos-credentials", "", "Authenticate requests to Mesos with the first principal and secret in this file, in the format of the --credentials flag of Mesos")
+	metronomeURL := fs.String("metronome", "", "Also expose job metrics from Metronome running on this URL")
+	singularityURL := fs.String("singularity", "", "Also expose request and task metrics from the Singularity API below this URL, e.g. http://singularity:7099/singularity")
+	slaveURL := fs.String("slave", "", "Expose metrics from slave running on this URL")
+	syntheticAgents := fs.Int("synthetic-agents", 0, "Expose the metrics of a fabricated master state with this many agents instead of polling Mesos, for scale testing")
+	syntheticTasks := fs.Int("synthetic-tasks", 0, "Number of tasks of the fabricated master state of -synthetic-agents")
+	recordDir := fs.String("record-dir", "", "Save the responses of the polled endpoints to this directory, e.g. for bug reports")
+	replayDir := fs.String("replay-dir", "", "Serve metrics from the responses saved by -record-dir to this directory instead of polling the targets")
+	scrapeMode := fs.String("scrape-mode", "", "Collectors to enable: master, agent or both, detected from -target if empty")
+	targetURL := fs.String("target", "", "Expose metrics from master or slave running on this URL, detecting its role")
 	timeout := fs.Duration("timeout", 5*time.Second, "Master polling timeout")
+	maxRequests := fs.Int("max-requests", 0, "Maximum number of concurrent /metrics requests, including those waiting for the scrape in progress, 0 for no limit")
+	handlerTimeout := fs.Duration("scrape-timeout", 0, "Time after which upstream fetches of a scrape are cancelled, unless Prometheus announces a shorter timeout, 0 for none")
+	timeoutOffset := fs.Duration("timeout-offset", 500*time.Millisecond, "Time subtracted from the scrape timeout announced by Prometheus to bound polling")
+	statePath := fs.String("state-path", "/state", "Path of the master state endpoint, relative to the master URL")
+	completedRetention := fs.Duration("completed-task-retention", 0, "Only expose completed tasks which finished within this duration, 0 exposes all")
+	leaderOnly := fs.Bool("leader-only", false, "Only expose metrics derived from the master state if the master is the leader")
+	countersFile := fs.String("counters-file", "", "Persist counters derived by comparing scrapes to this file, to keep them across restarts")
+	statusTimestamps := fs.Bool("task-status-timestamps", false, "Expose task status times with the status time as sample timestamp")
+	taskIdentity := fs.Bool("task-identity", false, "Add the app_id and job_id labels to task metrics for tasks of well-known frameworks: Marathon, Spark and Jenkins")
+	legacyNames := fs.Bool("legacy-names", false, "Also expose renamed metrics under their names, labels and units of earlier exporter versions, during a migration")
+	legacyUnits := fs.Bool("legacy-units", false, "Expose slave memory and disk in KiB like earlier exporter versions, despite the _bytes suffix")
+	graphiteAddress := fs.String("graphite-address", "", "Also push metrics to the Graphite server listening on this TCP address")
+	graphitePrefix := fs.String("graphite-prefix", "mesos", "Prefix of metric paths pushed to Graphite")
+	graphiteTags := fs.Bool("graphite-tags", false, "Push labels as Graphite tags instead of appending them to the metric path")
+	kafkaBrokers := fs.String("kafka-brokers", "", "Comma separated Kafka brokers to publish task state changes to as JSON")
+	kafkaTopic := fs.String("kafka-topic", "mesos_task_events", "Kafka topic to publish task state changes to")
+	grpcAddr := fs.String("grpc-addr", "", "Address to serve the gRPC status API on, disabled if empty")
+	grpcMaxAge := fs.Duration("grpc-max-age", 10*time.Second, "Time the master state is reused for gRPC status API calls")
+	maintenance := fs.Bool("maintenance", false, "Also expose the maintenance mode of the machines of the master")
+	overlay := fs.Bool("overlay", false, "Also expose the state of the overlay network module of the master or slave")
+	includeInactive := fs.Bool("include-inactive-frameworks", false, "Also expose tasks of inactive and disconnected frameworks")
+	maxCompleted := fs.Int("max-completed-tasks", 0, "Only expose the most recent completed tasks up to this number per framework, 0 exposes all")
+	topExecutors := fs.Int("top-executors", 0, "Also expose the usage of this many executors of the slave using the most CPU and memory, 0 exposes none")
+	maxLabelLength := fs.Int("max-label-length", 0, "Shorten label values longer than this many bytes, keeping them unique with a hash, 0 keeps them as they are")
+	otlpEndpoint := fs.String("otlp-endpoint", "", "Also push metrics to the OTLP receiver at this URL, e.g. http://localhost:4317")
+	otlpProtocol := fs.String("otlp-protocol", otlpGRPC, "OTLP protocol to push metrics and traces with: grpc or http/protobuf")
+	otlpTracesEndpoint := fs.String("otlp-traces-endpoint", "", "Export spans of scrapes to the OTLP receiver at this URL")
+	pushGatewayURL := fs.String("push-gateway", "", "Also push metrics to the Pushgateway running on this URL")
+	pushInterval := fs.Duration("push-interval", time.Minute, "Interval between pushes to push based outputs")
+	pushJob := fs.String("push-job", "mesos_exporter", "Job label of metrics pushed to the Pushgateway")
+	statsdAddress := fs.String("statsd-address", "", "Also push metrics to the StatsD server listening on this UDP address, with labels as DogStatsD tags")
+	statsdPrefix := fs.String("statsd-prefix", "", "Prefix of metric names pushed to StatsD")
+	textfile := fs.String("textfile", "", "Also write metrics to this file for the node_exporter textfile collector, e.g. /var/lib/node_exporter/mesos.prom")
+	failOnError := fs.Bool("startup.fail-on-error", false, "Collect all metrics once at startup and exit if any collector can't poll its target")
+	fs.BoolVar(failOnError, "startup-fail-on-error", false, "Alias of -startup.fail-on-error")
+	strictDecode := fs.Bool("strict-decode", false, "Compare Mesos responses with the expected schema and expose missing, unknown and mistyped fields")
 
 	fs.Parse(os.Args[1:])
-	if *masterURL != "" && *slaveURL != "" {
-		log.Fatal("Only -master or -slave can be given at a time")
-	}
-
-	switch {
-	case *masterURL != "":
-		for _, c := range []prometheus.Collector{
-			newMasterCollector(*masterURL, *timeout),
-			newMasterStateCollector(*masterURL, *timeout),
-		} {
-			if err := prometheus.Register(c); err != nil {
-				log.Fatal(err)
-			}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+
+	opts := collector.Options{Timeout: *timeout, Internal: collector.NewInternal()}
+	prometheus.MustRegister(opts.Internal.Collectors()...)
+	var vault *vaultClient
+	if cfg.Vault != nil {
+		if vault, err = newVaultClient(*cfg.Vault, *timeout); err != nil {
+			log.Fatalf("Error configuring Vault: %s", err)
 		}
-		log.Printf("Exposing master metrics on %s", *addr)
+		go vault.run()
+	}
+	if cfg.DCOS != nil {
+		if opts.Client, err = cfg.DCOS.newClient(*timeout, vault); err != nil {
+			log.Fatalf("Error configuring DC/OS authentication: %s", err)
+		}
+	}
+	if *mesosCredentials != "" {
+		if cfg.DCOS != nil {
+			log.Fatal("-mesos-credentials can't be combined with dcos in the config")
+		}
+		if opts.Client, err = mesosCredentialsClient(*mesosCredentials, *timeout); err != nil {
+			log.Fatalf("Error loading Mesos credentials: %s", err)
+		}
+	}
+
+	if *recordDir != "" && *replayDir != "" {
+		log.Fatal("Only one of -record-dir and -replay-dir can be given")
+	}
+	if *recordDir != "" {
+		if err := os.MkdirAll(*recordDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	rec := recorder{recordDir: *recordDir, replayDir: *replayDir}
+	opts.Client = rec.client(opts.Client, *timeout)
 
-	case *slaveURL != "":
-		for _, c := range []prometheus.Collector{
-			newSlaveCollector(*slaveURL, *timeout),
-			newSlaveMonitorCollector(*slaveURL, *timeout),
-		} {
-			if err := prometheus.Register(c); err != nil {
-				log.Fatal(err)
+	master, slave, err := scrapeTargets(*scrapeMode, *masterURL, *slaveURL, *targetURL, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	synthetic := *syntheticAgents > 0
+	if synthetic {
+		if master != "" || slave != "" || len(cfg.Clusters) > 0 {
+			log.Fatal("-synthetic-agents can't be combined with -master, -slave, -target or clusters in the config")
+		}
+		t, err := newSyntheticTransport(*statePath, *syntheticAgents, *syntheticTasks)
+		if err != nil {
+			log.Fatalf("Error generating synthetic state: %s", err)
+		}
+		master, opts.Client = syntheticURL, &http.Client{Transport: t}
+		log.Printf("Serving a synthetic state of %d agents and %d tasks", *syntheticAgents, *syntheticTasks)
+	}
+	if master == "" && slave == "" && len(cfg.Clusters) == 0 {
+		log.Fatal("Either -master, -slave, -target or clusters in the config are required")
+	}
+	if (master != "" || slave != "") && len(cfg.Clusters) > 0 {
+		log.Fatal("Clusters in the config can't be combined with -master, -slave or -target")
+	}
+
+	stateOpts := collector.StateOptions{
+		Options: opts,
+		Path:    *statePath,
+		Filter: collector.StateFilter{
+			IncludeInactive:    *includeInactive,
+			CompletedRetention: *completedRetention,
+			MaxCompleted:       *maxCompleted,
+		},
+		LeaderOnly:       *leaderOnly,
+		CountersFile:     *countersFile,
+		StatusTimestamps: *statusTimestamps,
+		LegacyUnits:      *legacyUnits,
+		Buckets:          cfg.Buckets,
+		ResourceUnits:    cfg.ResourceUnits,
+	}
+	if *taskIdentity {
+		stateOpts.Enrichers = collector.WellKnownEnrichers
+	}
+	var sinks []func([]collector.TaskEvent)
+	if *kafkaBrokers != "" {
+		sinks = append(sinks, newKafkaPublisher(strings.Split(*kafkaBrokers, ","), *kafkaTopic).publish)
+		log.Printf("Publishing task state changes to Kafka topic %s", *kafkaTopic)
+	}
+	for _, c := range cfg.Webhooks {
+		w, err := newWebhook(c, *timeout)
+		if err != nil {
+			log.Fatalf("Error configuring webhook %s: %s", c.URL, err)
+		}
+		go w.run()
+		sinks = append(sinks, w.notify)
+		log.Printf("Notifying %s about terminated tasks", c.URL)
+	}
+	if len(sinks) > 0 {
+		if master == "" {
+			log.Fatal("Task state changes require the master state")
+		}
+		stateOpts.Events = func(events []collector.TaskEvent) {
+			for _, sink := range sinks {
+				sink(events)
 			}
 		}
+	}
+
+	if *otlpTracesEndpoint != "" {
+		if err := setupTracing(*otlpTracesEndpoint, *otlpProtocol); err != nil {
+			log.Fatalf("Error configuring tracing: %s", err)
+		}
+	}
+
+	if *strictDecode {
+		register(prometheus.DefaultRegisterer, collector.EnableSchemaDiagnostics())
+	}
+
+	var (
+		gatherers      = overlayGatherers{prometheus.DefaultGatherer}
+		registerer     = newBackgroundRegisterer(prometheus.DefaultRegisterer, cfg.intervals())
+		stateCollector *collector.MasterStateCollector
+	)
+	if master != "" {
+		if synthetic {
+			// The synthetic master only serves its state.
+			stateCollector = collector.NewMasterStateCollector(master, stateOpts)
+			register(registerer, stateCollector)
+		} else if stateCollector, err = collector.RegisterMaster(registerer, master, stateOpts); err != nil {
+			log.Fatal(err)
+		}
+		if rules := cfg.mappings(collector.RoleMaster, true); len(rules) > 0 {
+			register(registerer, collector.NewMappingCollector(collector.RoleMaster, master, opts, rules))
+		}
+		if *overlay {
+			register(registerer, collector.NewOverlayMasterCollector(master, opts))
+		}
+		if *maintenance {
+			register(registerer, collector.NewMaintenanceCollector(master, opts))
+		}
+		log.Printf("Exposing master metrics on %s", *addr)
+	}
+	if slave != "" {
+		var r prometheus.Registerer = registerer
+		if master != "" {
+			// Keep the slave collectors in a registry of their own, whose
+			// families colliding with per slave families of the master
+			// state, e.g. of mapping rules, are dropped in favor of the
+			// cluster wide view.
+			reg := prometheus.NewRegistry()
+			r, gatherers = registerer.with(reg), append(gatherers, reg)
+		}
+		if err := collector.RegisterSlave(r, slave, opts); err != nil {
+			log.Fatal(err)
+		}
+		if rules := cfg.mappings(collector.RoleSlave, master != ""); len(rules) > 0 {
+			register(r, collector.NewMappingCollector(collector.RoleSlave, slave, opts, rules))
+		}
+		if *overlay {
+			register(r, collector.NewOverlayAgentCollector(slave, opts))
+		}
+		if *topExecutors > 0 {
+			register(r, collector.NewSlaveTopCollector(slave, opts, *topExecutors))
+		}
 		log.Printf("Exposing slave metrics on %s", *addr)
+	}
 
-	default:
-		log.Fatal("Either -master or -slave is required")
+	for _, c := range cfg.Clusters {
+		g, err := c.register(registerer, stateOpts, cfg.mappings(collector.RoleMaster, true), *timeout, vault, rec)
+		if err != nil {
+			log.Fatalf("Error configuring cluster %s: %s", c.Name, err)
+		}
+		gatherers = append(gatherers, g)
+		log.Printf("Exposing metrics of cluster %s on %s", c.Name, *addr)
 	}
 
-	http.Handle("/metrics", prometheus.Handler())
-	if err := http.ListenAndServe(*addr, nil); err != nil {
+	if *marathonURL != "" {
+		register(registerer, collector.NewMarathonCollector(*marathonURL, opts))
+		log.Printf("Exposing Marathon metrics on %s", *addr)
+	}
+	if *auroraURL != "" {
+		register(registerer, collector.NewAuroraCollector(*auroraURL, opts))
+		log.Printf("Exposing Aurora metrics on %s", *addr)
+	}
+	if *singularityURL != "" {
+		register(registerer, collector.NewSingularityCollector(*singularityURL, opts))
+		log.Printf("Exposing Singularity metrics on %s", *addr)
+	}
+	if *metronomeURL != "" {
+		register(registerer, collector.NewMetronomeCollector(*metronomeURL, opts))
+		log.Printf("Exposing Metronome metrics on %s", *addr)
+	}
+
+	info, err := newConfigInfo(fs, cfg, registerer.registered())
+	if err != nil {
+		log.Fatalf("Error hashing config: %s", err)
+	}
+	var (
+		active   func() bool
+		gathered prometheus.Gatherer = gatherers
+	)
+	if cfg.Election == nil {
+		prometheus.MustRegister(info)
+	} else {
+		e, err := newElector(*cfg.Election, electionIdentity(*addr), *timeout)
+		if err != nil {
+			log.Fatalf("Error configuring election: %s", err)
+		}
+		if err := e.campaign(); err != nil {
+			log.Printf("Error taking part in the election: %s", err)
+		}
+		go e.run()
+		// Standby replicas only expose whether they're elected, their
+		// configuration and the metrics of the exporter process.
+		standby := prometheus.NewRegistry()
+		standby.MustRegister(e, info, prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+		active, gathered = e.isElected, electionGatherer{Gatherer: gatherers, standby: standby, elector: e}
+	}
+	gathered = sanitizingGatherer{Gatherer: gathered, maxLength: *maxLabelLength}
+	if *legacyNames {
+		gathered = legacyGatherer{Gatherer: gathered, names: collector.LegacyNames}
+	}
+	gatherer := renamingGatherer{Gatherer: gathered, overrides: cfg.Metrics}
+	handler := &scrapeHandler{
+		Handler:     promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+		offset:      *timeoutOffset,
+		maxRequests: int32(*maxRequests),
+		timeout:     *handlerTimeout,
+		internal:    opts.Internal,
+	}
+	if *failOnError {
+		registerer.refresh(handler)
+		if err := checkTargets(handler, gatherer); err != nil {
+			log.Fatalf("Error checking targets: %s", err)
+		}
+	}
+	registerer.start(handler, active)
+	var pushing bool
+	push := func(name, dest string, send func([]*dto.MetricFamily) error) {
+		go pushLoop(name, handler, gatherer, *pushInterval, send)
+		log.Printf("Pushing metrics to %s every %s", dest, *pushInterval)
+		pushing = true
+	}
+	if *pushGatewayURL != "" {
+		push("pushgateway", *pushGatewayURL, pushGateway(*pushGatewayURL, *pushJob))
+	}
+	if cfg.RemoteWrite != nil {
+		send, err := remoteWrite(cfg.RemoteWrite, *pushInterval)
+		if err != nil {
+			log.Fatalf("Error configuring remote_write: %s", err)
+		}
+		push("remote_write", cfg.RemoteWrite.URL, send)
+	}
+	if *otlpEndpoint != "" {
+		send, err := otlpExport(*otlpEndpoint, *otlpProtocol, *pushInterval)
+		if err != nil {
+			log.Fatalf("Error configuring OTLP: %s", err)
+		}
+		push("otlp", *otlpEndpoint, send)
+	}
+	if *statsdAddress != "" {
+		o, err := newStatsdOutput(*statsdAddress, *statsdPrefix)
+		if err != nil {
+			log.Fatalf("Error configuring StatsD: %s", err)
+		}
+		push("statsd", *statsdAddress, o.send)
+	}
+	if *graphiteAddress != "" {
+		push("graphite", *graphiteAddress, graphiteOutput(*graphiteAddress, *graphitePrefix, *graphiteTags, *pushInterval))
+	}
+	if cfg.InfluxDB != nil {
+		send, err := influxOutput(cfg.InfluxDB, *pushInterval)
+		if err != nil {
+			log.Fatalf("Error configuring InfluxDB: %s", err)
+		}
+		push("influxdb", cfg.InfluxDB.URL, send)
+	}
+	if *textfile != "" {
+		push("textfile", *textfile, textfileOutput(*textfile))
+	}
+
+	if *grpcAddr != "" {
+		if stateCollector == nil {
+			log.Fatal("-grpc-addr requires the master state")
+		}
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv := newStatusGRPCServer(&statusServer{collector: stateCollector, scrapes: handler, maxAge: *grpcMaxAge})
+		go func() { log.Fatal(srv.Serve(lis)) }()
+		log.Printf("Serving the gRPC status API on %s", *grpcAddr)
+	}
+
+	if *addr == "" {
+		if !pushing && *grpcAddr == "" {
+			log.Fatal("Either -addr, -grpc-addr or an output to push metrics to is required")
+		}
+		select {}
+	}
+	http.Handle("/metrics", handler)
+	if stateCollector != nil {
+		http.Handle("/api/v1/state", &stateAPI{collector: stateCollector, scrapes: handler})
+	}
+	http.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK\n"))
+	})
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Consul != nil {
+		mode := modeBoth
+		switch {
+		case slave == "":
+			mode = modeMaster
+		case master == "":
+			mode = modeAgent
+		}
+		reg, err := newConsulRegistration(*cfg.Consul, lis.Addr().String(), mode, *timeout)
+		if err != nil {
+			log.Fatalf("Error configuring Consul registration: %s", err)
+		}
+		if err := reg.register(); err != nil {
+			log.Fatalf("Error registering %s in Consul: %s", reg.service.ID, err)
+		}
+		reg.deregisterOnSignal()
+		log.Printf("Registered %s in Consul", reg.service.ID)
+	}
+	if err := http.Serve(lis, nil); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func register(r prometheus.Registerer, cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -1,29 +1,1229 @@
 package main
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
 )
 
-func TestPortRange_UnmarshalJSON(t *testing.T) {
+func TestOverlayGatherers(t *testing.T) {
+	master, slave := prometheus.NewRegistry(), prometheus.NewRegistry()
+	opts := collector.Options{Timeout: time.Second}
+	master.MustRegister(collector.NewMasterCollector("http://127.0.0.1:0", opts))
+	slave.MustRegister(collector.NewSlaveMonitorCollector("http://127.0.0.1:0", opts))
+	// Same name with different labels, as the master state and slave
+	// collectors do.
+	gauge := func(help, label string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: "mesos", Subsystem: "slave", Name: "cpus", Help: help}, []string{label})
+	}
+	mg, sg := gauge("Master view", "slave"), gauge("Slave view", "type")
+	mg.WithLabelValues("s1").Set(1)
+	sg.WithLabelValues("total").Set(2)
+	master.MustRegister(mg)
+	slave.MustRegister(sg)
+
+	mfs, err := overlayGatherers{master, slave}.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up": {`{collector="master"} 0`, `{collector="slave_monitor"} 0`},
+		"mesos_slave_cpus":   {`{slave="s1"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestRenamingGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"mesos_master_cpus", "mesos_master_disk", "mesos_master_mem"} {
+		reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "Original."}))
+	}
+	g := renamingGatherer{Gatherer: reg, overrides: map[string]metricOverride{
+		"mesos_master_cpus": {Name: "mesos_cpus", Help: "Cluster CPUs."},
+		"mesos_master_mem":  {Help: "Cluster memory."},
+	}}
+
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range mfs {
+		got = append(got, mf.GetName()+": "+mf.GetHelp())
+	}
+	want := []string{
+		"mesos_cpus: Cluster CPUs.",
+		"mesos_master_disk: Original.",
+		"mesos_master_mem: Cluster memory.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestLegacyGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mem := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "mesos_master_mem_bytes", Help: "Memory."}, []string{"type"})
+	mem.WithLabelValues("used").Set(512 << 20)
+	rtt := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "mesos_executor_network_tcp_rtt_seconds", Help: "RTT."}, []string{"percentile", "source"})
+	rtt.WithLabelValues("99", "db").Set(0.25)
+	cpus := prometheus.NewGauge(prometheus.GaugeOpts{Name: "mesos_slave_cpus", Help: "CPUs of the master state."})
+	allocation := prometheus.NewGauge(prometheus.GaugeOpts{Name: "mesos_slave_allocation_cpus", Help: "CPUs of the slave."})
+	reg.MustRegister(mem, rtt, cpus, allocation)
+
+	mfs, err := legacyGatherer{Gatherer: reg, names: collector.LegacyNames}.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got = append(got, mf.GetName()+metricString(m))
+		}
+	}
+	want := []string{
+		`mesos_executor_network_tcp_rtt_seconds{percentile="99",source="db"} 0.25`,
+		`mesos_master_mem{type="used"} 512`,
+		`mesos_master_mem_bytes{type="used"} 5.36870912e+08`,
+		"mesos_slave_allocation_cpus{} 0",
+		"mesos_slave_cpus{} 0",
+		`network_tcp_rtt_seconds{quantile="0.99",source="db"} 0.25`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestSanitizingGatherer_Sanitize(t *testing.T) {
 	for i, tt := range []struct {
+		v    string
+		max  int
+		want string
+	}{
+		{"web-1", 0, "web-1"},
+		{"line\nbreak\t", 0, "line\uFFFDbreak\uFFFD"},
+		{"bad\xffbyte", 0, "bad\uFFFDbyte"},
+		{"äöü", 0, "äöü"},
+		{"short", 20, "short"},
+		{"a-very-long-task-name-indeed", 20, "a-v~05bc54fb4a98cf72"},
+		// ä is two bytes, so cutting after 4 bytes would split it.
+		{"abcäääääääääääääääää", 21, "abc~9c847ce918334e9d"},
+	} {
+		if got := (sanitizingGatherer{maxLength: tt.max}).sanitize(tt.v); got != tt.want {
+			t.Errorf("test #%d: got: %q, want: %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizingGatherer_Collisions(t *testing.T) {
+	label := func(v string) *dto.Metric {
+		return &dto.Metric{
+			Label: []*dto.LabelPair{{Name: proto.String("task"), Value: proto.String(v)}},
+			Gauge: &dto.Gauge{Value: proto.Float64(1)},
+		}
+	}
+	g := sanitizingGatherer{Gatherer: prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{
+			Name:   proto.String("g"),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{label("a\nb"), label("a\tb"), label("a\uFFFDb"), label("c\n")},
+		}}, nil
+	})}
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range mfs[0].Metric {
+		got = append(got, m.Label[0].GetValue())
+	}
+	// Only the sanitized values colliding with others are disambiguated.
+	want := []string{"a\uFFFDb~" + sha256Prefix("a\nb"), "a\uFFFDb~" + sha256Prefix("a\tb"), "a\uFFFDb", "c\uFFFD"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func sha256Prefix(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+func metricString(m *dto.Metric) string {
+	var labels []string
+	for _, lp := range m.GetLabel() {
+		labels = append(labels, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+	}
+	var v float64
+	switch {
+	case m.Gauge != nil:
+		v = m.GetGauge().GetValue()
+	case m.Counter != nil:
+		v = m.GetCounter().GetValue()
+	case m.Untyped != nil:
+		v = m.GetUntyped().GetValue()
+	}
+	return fmt.Sprintf("{%s} %g", strings.Join(labels, ","), v)
+}
+
+func TestToTimeSeries(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "g", Help: "g"}, []string{"b", "a"})
+	g.WithLabelValues("2", "1").Set(3)
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "h", Help: "h", Buckets: []float64{1}})
+	h.Observe(0.5)
+	h.Observe(2)
+	reg.MustRegister(g, h)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range toTimeSeries(mfs, time.Unix(10, 0)) {
+		var labels []string
+		for _, l := range s.labels {
+			labels = append(labels, l.name+"="+l.value)
+		}
+		got = append(got, fmt.Sprintf("{%s} %g %d", strings.Join(labels, ","), s.value, s.timestamp))
+	}
+	want := []string{
+		"{__name__=g,a=1,b=2} 3 10000",
+		"{__name__=h_bucket,le=1} 1 10000",
+		"{__name__=h_bucket,le=+Inf} 2 10000",
+		"{__name__=h_sum} 2.5 10000",
+		"{__name__=h_count} 2 10000",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestStatsdOutput(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	o, err := newStatsdOutput(conn.LocalAddr().String(), "mesos.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "g", Help: "g"}, []string{"name"})
+	g.WithLabelValues("a,b").Set(1.5)
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "c_total", Help: "c"})
+	c.Add(3)
+	reg.MustRegister(g, c)
+
+	var got []string
+	for _, inc := range []float64{0, 2} {
+		c.Add(inc)
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.send(mfs); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, statsdPacketSize)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	want := []string{
+		"mesos.g:1.5|g|#name:a_b",
+		"mesos.c_total:2|c\nmesos.g:1.5|g|#name:a_b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestEncodeLineProtocol(t *testing.T) {
+	got := string(encodeLineProtocol([]timeSeries{
+		{labels: []labelPair{{"__name__", "mesos_task_cpus_limit"}, {"name", "my task"}, {"executor", ""}, {"task", "a=b,c"}}, value: 0.5, timestamp: 1000},
+		{labels: []labelPair{{"__name__", "nan"}}, value: math.NaN()},
+	}))
+	if want := "mesos_task_cpus_limit,name=my\\ task,task=a\\=b\\,c value=0.5 1000\n"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestStateAPI(t *testing.T) {
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"1.4.0","agents":[{"id":"s1"}],"frameworks":[{"id":"f1","active":true},{"id":"f2"}]}`))
+	}))
+	defer mesos.Close()
+
+	api := &stateAPI{
+		collector: collector.NewMasterStateCollector(mesos.URL, collector.StateOptions{Options: collector.Options{Timeout: time.Second}}),
+		scrapes:   &scrapeHandler{},
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/state", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var st collector.State
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Slaves) != 1 || len(st.Frameworks) != 1 || st.Frameworks[0].ID != "f1" {
+		t.Errorf("got: %+v, want slave s1 and framework f1", st)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	bodies := make(chan webhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		bodies <- p
+	}))
+	defer srv.Close()
+
+	w, err := newWebhook(webhookConfig{URL: srv.URL, Frameworks: []string{"marathon"}}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.run()
+
+	w.notify([]collector.TaskEvent{
+		{FrameworkID: "f1", FrameworkName: "marathon", TaskID: "a", State: "TASK_FAILED"},
+		{FrameworkID: "f1", FrameworkName: "marathon", TaskID: "b", State: "TASK_FINISHED"},
+		{FrameworkID: "f1", FrameworkName: "marathon", TaskID: "c", State: "TASK_RUNNING"},
+		{FrameworkID: "f2", FrameworkName: "chronos", TaskID: "d", State: "TASK_LOST"},
+	})
+	w.notify([]collector.TaskEvent{{FrameworkID: "f2", FrameworkName: "chronos", TaskID: "e", State: "TASK_FAILED"}})
+	w.notify([]collector.TaskEvent{{FrameworkID: "f1", FrameworkName: "marathon", TaskID: "f", State: "TASK_LOST"}})
+
+	for _, want := range []string{"a", "f"} {
+		select {
+		case p := <-bodies:
+			if len(p.Events) != 1 || p.Events[0].TaskID != want {
+				t.Errorf("got: %+v, want task %s", p.Events, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no request for task %s", want)
+		}
+	}
+}
+
+func TestBackgroundRegisterer(t *testing.T) {
+	var fetches int
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write([]byte(`{"master/elected": 1}`))
+	}))
+	defer mesos.Close()
+
+	reg := prometheus.NewRegistry()
+	b := newBackgroundRegisterer(reg, map[string]time.Duration{"master": time.Minute})
+	opts := collector.Options{Timeout: time.Second}
+	register(b, collector.NewMasterCollector(mesos.URL, opts), collector.NewSlaveGCCollector(mesos.URL, opts))
+	if len(b.collectors["master"]) != 1 || len(b.collectors) != 1 {
+		t.Fatalf("got background collectors %v, want master", b.collectors)
+	}
+
+	b.collectors["master"][0].refresh()
+	for i := 0; i < 2; i++ {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// One refresh of the master collector and a fetch of the slave_gc
+	// collector per gather.
+	if fetches != 3 {
+		t.Errorf("got %d fetches, want 3", fetches)
+	}
+}
+
+func TestConfigInfo(t *testing.T) {
+	labels := func(args ...string) map[string]string {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Duration("timeout", 5*time.Second, "")
+		fs.Bool("leader-only", false, "")
+		fs.String("master", "", "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		g, err := newConfigInfo(fs, &config{}, []string{"master", "master_state"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &dto.Metric{}
+		if err := g.Write(m); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, l := range m.Label {
+			got[l.GetName()] = l.GetValue()
+		}
+		return got
+	}
+
+	got := labels("-timeout", "10s")
+	if got["collectors"] != "master,master_state" || got["timeout"] != "10s" || got["leader_only"] != "false" || len(got["hash"]) != 16 {
+		t.Errorf("got labels %v", got)
+	}
+	if _, ok := got["master"]; ok {
+		t.Errorf("got label master of a flag not exported")
+	}
+	if again := labels("-timeout", "10s"); again["hash"] != got["hash"] {
+		t.Errorf("got hash %s for the same flags, want %s", again["hash"], got["hash"])
+	}
+	if other := labels("-timeout", "10s", "-master", "http://localhost:5050"); other["hash"] == got["hash"] {
+		t.Error("got the same hash for different flags")
+	}
+}
+
+func TestConfig_ValidateNames(t *testing.T) {
+	rule := func(name string) collector.MappingRule {
+		return collector.MappingRule{Endpoint: "/metrics/snapshot", Value: "master/gpus", Name: name}
+	}
+	for _, tt := range []struct {
+		cfg config
+		ok  bool
+	}{
+		{config{Mappings: []collector.MappingRule{rule("mesos_master_gpus")}}, true},
+		{config{Mappings: []collector.MappingRule{rule("mesos_master_gpus"), rule("mesos_master_gpus")}}, false},
+		{config{Mappings: []collector.MappingRule{rule("mesos_master_uptime_seconds")}}, false},
+		{config{Mappings: []collector.MappingRule{rule("mesos_collector_errors_total")}}, false},
+		{config{Metrics: map[string]metricOverride{"mesos_master_cpus": {Name: "mesos_master_cpus_total"}}}, true},
+		{config{Metrics: map[string]metricOverride{"mesos_master_cpus": {Name: "mesos_master_cpus"}}}, true},
+		{config{Metrics: map[string]metricOverride{"mesos_master_cpus": {Name: "mesos_master_uptime_seconds"}}}, false},
+		{config{Metrics: map[string]metricOverride{
+			"mesos_master_cpus":      {Name: "mesos_master_cpus_total"},
+			"mesos_master_mem_bytes": {Name: "mesos_master_cpus_total"},
+		}}, false},
+		{config{
+			Metrics:  map[string]metricOverride{"mesos_master_cpus": {Name: "mesos_master_gpus"}},
+			Mappings: []collector.MappingRule{rule("mesos_master_gpus")},
+		}, false},
+	} {
+		if err := tt.cfg.validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: got error %v, want ok %t", tt.cfg, err, tt.ok)
+		}
+	}
+}
+
+func TestScrapeHandler_Limits(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := &scrapeHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+		}),
+		maxRequests: 1,
+	}
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		close(done)
+	}()
+	<-started
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d beyond the limit, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	close(release)
+	<-done
+
+	// A request queued behind a slow scrape gives up at its deadline
+	// instead of collecting again once it's its turn.
+	started, release = make(chan struct{}), make(chan struct{})
+	var collected int
+	h = &scrapeHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			collected++
+			started <- struct{}{}
+			<-release
+		}),
+		timeout: 100 * time.Millisecond,
+	}
+	done = make(chan struct{})
+	go func() {
+		h.scrape(0, func() { h.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil)) })
+		close(done)
+	}()
+	<-started
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d after timing out behind a slow scrape, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	close(release)
+	<-done
+	if collected != 1 {
+		t.Errorf("got %d collections, want 1", collected)
+	}
+
+	h = &scrapeHandler{timeout: time.Minute}
+	for _, tc := range []struct {
+		announced string
+		want      time.Duration
+	}{
+		{"", time.Minute},
+		{"10", 10 * time.Second},
+		{"120", time.Minute},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if tc.announced != "" {
+			r.Header.Set(scrapeTimeoutHeader, tc.announced)
+		}
+		if got := h.requestTimeout(r); got != tc.want {
+			t.Errorf("announced %q: got timeout %s, want %s", tc.announced, got, tc.want)
+		}
+	}
+}
+
+func TestScrapeHandler_Deadline(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"10", 10 * time.Second, true},
+		{"0.25", 250 * time.Millisecond, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"ten", 0, false},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if tc.header != "" {
+			r.Header.Set(scrapeTimeoutHeader, tc.header)
+		}
+		if got, ok := scrapeTimeout(r); got != tc.want || ok != tc.ok {
+			t.Errorf("header %q: got %s, %t, want %s, %t", tc.header, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	h := &scrapeHandler{offset: 500 * time.Millisecond}
+	now := time.Now()
+	for _, tc := range []struct {
+		timeout time.Duration
+		want    time.Time
+	}{
+		{0, time.Time{}},
+		{10 * time.Second, now.Add(9500 * time.Millisecond)},
+		// Timeouts within the offset aren't shortened to nothing.
+		{500 * time.Millisecond, now.Add(500 * time.Millisecond)},
+		{200 * time.Millisecond, now.Add(200 * time.Millisecond)},
+	} {
+		if got := h.deadline(now, tc.timeout); !got.Equal(tc.want) {
+			t.Errorf("timeout %s: got deadline %s, want %s", tc.timeout, got, tc.want)
+		}
+	}
+}
+
+func TestCheckTargets(t *testing.T) {
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer mesos.Close()
+
+	reg := prometheus.NewRegistry()
+	if err := checkTargets(&scrapeHandler{}, reg); err != nil {
+		t.Errorf("got error %q without collectors", err)
+	}
+	reg.MustRegister(collector.NewSlaveGCCollector(mesos.URL, collector.Options{Timeout: time.Second}))
+	want := `collectors failed: {collector="slave_gc"}`
+	if err := checkTargets(&scrapeHandler{}, reg); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestSyntheticState(t *testing.T) {
+	tr, err := newSyntheticTransport("", 10, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := collector.NewMasterStateCollector(syntheticURL, collector.StateOptions{Options: collector.Options{Client: &http.Client{Transport: tr}}})
+	st, err := c.FetchState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var running, completed int
+	for _, f := range st.Frameworks {
+		running, completed = running+len(f.Tasks), completed+len(f.Completed)
+	}
+	if len(st.Slaves) != 10 || running != 900 || completed != 100 {
+		t.Errorf("got %d slaves, %d running and %d completed tasks, want 10, 900 and 100", len(st.Slaves), running, completed)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics/snapshot" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"master/elected": 1}`))
+	}))
+	dir := t.TempDir()
+	gather := func(rec recorder) []string {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(collector.NewMasterCollector(mesos.URL, collector.Options{Client: rec.client(nil, time.Second)}))
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, mf := range mfs {
+			if n := mf.GetName(); n == "mesos_master_elected" || n == "mesos_collector_up" {
+				got = append(got, fmt.Sprintf("%s %g", n, mf.Metric[0].GetGauge().GetValue()))
+			}
+		}
+		return got
+	}
+
+	recorded := gather(recorder{recordDir: dir})
+	mesos.Close()
+	if replayed := gather(recorder{replayDir: dir}); !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("got replayed: %v, want recorded: %v", replayed, recorded)
+	}
+	want := []string{"mesos_collector_up 1", "mesos_master_elected 1"}
+	if !reflect.DeepEqual(recorded, want) {
+		t.Errorf("got recorded: %v, want: %v", recorded, want)
+	}
+}
+
+func TestClusterConfig(t *testing.T) {
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer mesos.Close()
+
+	b := newBackgroundRegisterer(prometheus.NewRegistry(), nil)
+	var gs overlayGatherers
+	for _, name := range []string{"a", "b"} {
+		c := clusterConfig{Name: name, Master: mesos.URL}
+		g, err := c.register(b, collector.StateOptions{}, nil, time.Second, nil, recorder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs = append(gs, g)
+	}
+	mfs, err := gs.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range mfs {
+		if mf.GetName() != "mesos_collector_up" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			var labels []string
+			for _, l := range m.Label {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			got = append(got, strings.Join(labels, ","))
+		}
+	}
+	want := []string{
+		"cluster=a,collector=master",
+		"cluster=a,collector=master_state",
+		"cluster=b,collector=master",
+		"cluster=b,collector=master_state",
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestStatusServer(t *testing.T) {
+	var fetches int
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write([]byte(`{"version":"1.4.0","pid":"master@m1","leader":"master@m1",
+			"agents":[{"id":"s1","hostname":"host1","active":true,"resources":{"cpus":4,"mem":1024,"disk":2048},"used_resources":{"cpus":1,"mem":512,"disk":0}},{"id":"s2","resources":{"cpus":2,"mem":0,"disk":0}}],
+			"frameworks":[{"id":"f1","name":"marathon","active":true,"tasks":[{"id":"a","framework_id":"f1","slave_id":"s1","state":"TASK_RUNNING","resources":{"cpus":1,"mem":512,"disk":0}}],
+				"completed_tasks":[{"id":"b","framework_id":"f1","slave_id":"s1","state":"TASK_FAILED","resources":{"cpus":1,"mem":0,"disk":0}}]}]}`))
+	}))
+	defer mesos.Close()
+
+	srv := newStatusGRPCServer(&statusServer{
+		collector: collector.NewMasterStateCollector(mesos.URL, collector.StateOptions{Options: collector.Options{Timeout: time.Second}}),
+		scrapes:   &scrapeHandler{},
+		maxAge:    time.Minute,
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i, tt := range []struct {
+		method string
+		req    map[string]interface{}
+		want   string
+	}{
+		{
+			method: "GetClusterSummary",
+			want:   `{"leader":true,"agents":"2","activeAgents":"1","frameworks":"1","activeFrameworks":"1","tasks":"1","cpus":6,"cpusUsed":1,"memBytes":1073741824,"memUsedBytes":536870912,"diskBytes":2147483648}`,
+		},
+		{
+			method: "GetTasks",
+			req:    map[string]interface{}{"state": "TASK_FAILED"},
+			want:   `{"tasks":[{"id":"b","frameworkId":"f1","agentId":"s1","state":"TASK_FAILED","cpus":1}]}`,
+		},
+		{
+			method: "GetFrameworks",
+			want:   `{"frameworks":[{"id":"f1","name":"marathon","active":true,"tasks":"1","completedTasks":"1","cpusUsed":1,"memUsedBytes":536870912}]}`,
+		},
+	} {
+		res := newMessage(tt.method+"Response", nil)
+		if err := conn.Invoke(context.Background(), "/"+statusService+"/"+tt.method, newMessage(tt.method+"Request", tt.req), res); err != nil {
+			t.Fatalf("test #%d: %s", i, err)
+		}
+		got, err := protojson.Marshal(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// protojson randomly adds spaces to discourage comparing its output.
+		var gotV, wantV interface{}
+		json.Unmarshal(got, &gotV)
+		json.Unmarshal([]byte(tt.want), &wantV)
+		if !reflect.DeepEqual(gotV, wantV) {
+			t.Errorf("test #%d: got: %s, want: %s", i, got, tt.want)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("got %d fetches of the master state, want 1", fetches)
+	}
+}
+
+// metricString formats a metric like the text exposition format without
+// the metric name.
+
+func TestMesosCredentials(t *testing.T) {
+	for _, tc := range []struct {
 		data string
-		want ranges
-		err  error
+		want []mesosCredential
 	}{
-		{"[]", nil, nil},
-		{`"[]"`, nil, nil},
-		{`"[0-15]"`, ranges{{0, 15}}, nil},
-		{`"[0-15, 17-20]"`, ranges{{0, 15}, {17, 20}}, nil},
+		{`{"principal": "a", "secret": "b"}`, []mesosCredential{{"a", "b"}}},
+		{`{"credentials": [{"principal": "a", "secret": "b"}, {"principal": "c", "secret": "d"}]}`, []mesosCredential{{"a", "b"}, {"c", "d"}}},
+		{"a b\n\nc\td\n", []mesosCredential{{"a", "b"}, {"c", "d"}}},
+		{"a\n", nil},
+		{"{}", nil},
+		{"", nil},
+	} {
+		got, err := parseMesosCredentials([]byte(tc.data))
+		if (err != nil) != (tc.want == nil) || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, %v, want %v", tc.data, got, err, tc.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte("exporter secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var user, password string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ = r.BasicAuth()
+	}))
+	defer srv.Close()
+	c, err := mesosCredentialsClient(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"secret", "rotated"} {
+		if err := os.WriteFile(path, []byte("exporter "+want+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if user != "exporter" || password != want {
+			t.Errorf("got %s:%s, want exporter:%s", user, password, want)
+		}
+	}
+}
+
+func TestDCOSServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logins int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/acs/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ UID, Token string }
+		json.NewDecoder(r.Body).Decode(&req)
+		parts := strings.Split(req.Token, ".")
+		if len(parts) != 3 {
+			http.Error(w, "malformed token", http.StatusBadRequest)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if req.UID != "exporter" || rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+			http.Error(w, "invalid login token", http.StatusUnauthorized)
+			return
+		}
+		logins++
+		fmt.Fprintf(w, `{"token":"t%d"}`, logins)
+	})
+	mux.HandleFunc("/mesos/version", func(w http.ResponseWriter, r *http.Request) {
+		// The first token expires right away.
+		if r.Header.Get("Authorization") != "token=t2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"version":"1.4.0"}`))
+	})
+	cluster := httptest.NewServer(mux)
+	defer cluster.Close()
+
+	secret, err := json.Marshal(dcosServiceAccount{
+		UID:           "exporter",
+		PrivateKey:    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		LoginEndpoint: cluster.URL + "/acs/api/v1/auth/login",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "secret.json")
+	if err := os.WriteFile(file, secret, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := (&dcosConfig{ServiceAccountFile: file}).newClient(time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(cluster.URL + "/mesos/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("got %s, want 200 OK", res.Status)
+		}
+	}
+	if logins != 2 {
+		t.Errorf("got %d logins, want 2", logins)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	// Every metric of the dashboard must be exported by a collector.
+	described := map[string]bool{}
+	opts := collector.Options{}
+	for _, c := range []prometheus.Collector{
+		collector.NewMasterCollector("", opts),
+		collector.NewMasterStateCollector("", collector.StateOptions{}),
+		collector.NewSlaveCollector("", opts),
+		collector.NewSlaveMonitorCollector("", opts),
+		collector.NewMarathonCollector("", opts),
+		collector.NewMetronomeCollector("", opts),
+		collector.NewAuroraCollector("", opts),
+		collector.NewSingularityCollector("", opts),
+	} {
+		ch := make(chan *prometheus.Desc)
+		go func() {
+			c.Describe(ch)
+			close(ch)
+		}()
+		for d := range ch {
+			described[d.String()] = true
+		}
+	}
+	for _, identity := range []bool{false, true} {
+		for _, r := range dashboardRows(identity) {
+			for _, p := range r.panels {
+				for _, m := range p.metrics {
+					found := false
+					for d := range described {
+						found = found || strings.Contains(d, fmt.Sprintf("fqName: %q", m))
+					}
+					if !found {
+						t.Errorf("%s panel %q: no collector exports %s", r.title, p.title, m)
+					}
+				}
+			}
+		}
+	}
+
+	d, err := newDashboard("Mesos", []string{"master"}, false, map[string]metricOverride{
+		"mesos_master_cpus": {Name: "mesos_master_cpus_total"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	panel := d["panels"].([]interface{})[1].(map[string]interface{})
+	if got, want := panel["targets"].([]interface{})[0].(map[string]interface{})["expr"], "sum by (type) (mesos_master_cpus_total)"; got != want {
+		t.Errorf("got expr %q, want %q", got, want)
+	}
+	if _, err := newDashboard("Mesos", []string{"chronos"}, false, nil); err == nil {
+		t.Error("expected error for unknown collector")
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	cfg := &config{Metrics: map[string]metricOverride{"mesos_master_cpus": {Name: "mesos_master_cpus_total"}}}
+	entries, err := catalog(cfg, []string{"master", "master_state"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		got[e.Name] = fmt.Sprintf("%s %s %s", e.Type, strings.Join(e.Labels, ","), strings.Join(e.collectors, ","))
+	}
+	for name, want := range map[string]string{
+		"mesos_collector_up":                `gauge collector="master" master,master_state`,
+		"mesos_master_cpus_total":           "gauge type master",
+		"mesos_master_slave_removals_total": "counter reason master",
+		"mesos_slave_info":                  "gauge slave,pid,hostname,port,active,region,zone master_state",
+		"mesos_task_duration_seconds":       "histogram framework,state master_state",
+		"mesos_tasks_finished_total":        "counter framework,state,reason,source master_state",
+	} {
+		if got[name] != want {
+			t.Errorf("%s: got %q, want %q", name, got[name], want)
+		}
+	}
+	if _, err := catalog(cfg, []string{"nonexistent"}, false); err == nil {
+		t.Error("got no error for an unknown collector")
+	}
+}
+
+func TestCatalog_Lint(t *testing.T) {
+	var names []string
+	for name := range catalogCollectors(&config{}, true) {
+		names = append(names, name)
+	}
+	entries, err := catalog(&config{}, names, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mfs []*dto.MetricFamily
+	for _, e := range entries {
+		typ := dto.MetricType(dto.MetricType_value[strings.ToUpper(e.Type)])
+		m := &dto.Metric{}
+		for _, l := range e.Labels {
+			name := strings.SplitN(l, "=", 2)[0]
+			m.Label = append(m.Label, &dto.LabelPair{Name: &name})
+		}
+		name, help := e.Name, e.Help
+		mfs = append(mfs, &dto.MetricFamily{Name: &name, Help: &help, Type: &typ, Metric: []*dto.Metric{m}})
+		if e.Name != "mesos_collector_up" && len(e.collectors) > 1 {
+			t.Errorf("%s: exported by several collectors: %v", e.Name, e.collectors)
+		}
+		if e.Type == "untyped" {
+			t.Errorf("%s: no type", e.Name)
+		}
+	}
+	problems, err := promlint.NewWithMetricFamilies(mfs).Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("%s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestRuleBuilder(t *testing.T) {
+	b := ruleBuilder{
+		overrides: map[string]metricOverride{"mesos_master_cpus": {Name: "mesos_master_cpus_total"}},
+		selector:  `job="mesos"`,
+		groupBy:   []string{"cluster"},
+	}
+	for _, tc := range []struct{ got, want string }{
+		{b.m("mesos_master_cpus"), `mesos_master_cpus_total{job="mesos"}`},
+		{b.m(`mesos_master_cpus{type="used"}`), `mesos_master_cpus_total{type="used", job="mesos"}`},
+		{b.m("mesos_master_mem_bytes"), `mesos_master_mem_bytes{job="mesos"}`},
+		{b.by("framework"), " by (cluster, framework)"},
+		{ruleBuilder{}.by(), ""},
+		{ruleBuilder{}.m("mesos_master_mem_bytes"), "mesos_master_mem_bytes"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+
+	// Alerts may only use recording rules of the curated set.
+	records := map[string]bool{}
+	groups := b.groups(map[string]string{"team": "infra"})
+	for _, r := range groups[0].Rules {
+		records[r.Record] = true
+	}
+	for _, r := range groups[1].Rules {
+		for _, f := range strings.Fields(r.Expr) {
+			if strings.HasPrefix(f, "mesos:") && !records[f] {
+				t.Errorf("alert %s uses unknown recording rule %s", r.Alert, f)
+			}
+		}
+		if r.Labels["team"] != "infra" || r.Labels["severity"] == "" {
+			t.Errorf("alert %s has labels %v", r.Alert, r.Labels)
+		}
+	}
+}
+
+func TestElector(t *testing.T) {
+	var (
+		holder   string
+		sessions int
+	)
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/session/create":
+			sessions++
+			fmt.Fprintf(w, `{"ID": "session-%d"}`, sessions)
+		case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+			w.Write([]byte(`[]`))
+		case r.URL.Path == "/v1/kv/service/mesos-exporter/leader":
+			s := r.URL.Query().Get("acquire")
+			if holder == "" {
+				holder = s
+			}
+			fmt.Fprint(w, holder == s)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer consul.Close()
+
+	var electors []*elector
+	for _, id := range []string{"a:9110", "b:9110"} {
+		e, err := newElector(electionConfig{URL: consul.URL}, id, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := e.campaign(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		electors = append(electors, e)
+	}
+	if !electors[0].isElected() || electors[1].isElected() || sessions != 2 {
+		t.Fatalf("got elected %t and %t with %d sessions, want only the first with 2", electors[0].isElected(), electors[1].isElected(), sessions)
+	}
+
+	active := prometheus.NewRegistry()
+	active.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "mesos_master_elected"}))
+	for i, want := range [][]string{
+		{"mesos_exporter_elected", "mesos_master_elected"},
+		{"mesos_exporter_elected"},
 	} {
-		var rs ranges
-		if err := json.Unmarshal([]byte(tt.data), &rs); !reflect.DeepEqual(err, tt.err) {
-			t.Errorf("test #%d: got err: %v, want: %v", i, err, tt.want)
+		standby := prometheus.NewRegistry()
+		standby.MustRegister(electors[i])
+		mfs, err := electionGatherer{Gatherer: active, standby: standby, elector: electors[i]}.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, mf := range mfs {
+			got = append(got, mf.GetName())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("replica %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestConsulRegistration(t *testing.T) {
+	var (
+		registered consulService
+		requests   []string
+	)
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/v1/agent/service/register" {
+			json.NewDecoder(r.Body).Decode(&registered)
 		}
+	}))
+	defer consul.Close()
 
-		if got := rs; !reflect.DeepEqual(got, tt.want) {
-			t.Errorf("test #%d: got: %v, want: %v", i, got, tt.want)
+	reg, err := newConsulRegistration(consulConfig{URL: consul.URL, Address: "exporter1", Cluster: "prod"}, "[::]:9110", modeMaster, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.deregister(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := consulService{
+		ID:      "mesos-exporter-exporter1:9110",
+		Name:    "mesos-exporter",
+		Address: "exporter1",
+		Port:    9110,
+		Tags:    []string{"mode=master", "cluster=prod"},
+		Meta:    map[string]string{"mode": "master", "cluster": "prod"},
+		Check: consulCheck{
+			HTTP:                           "http://exporter1:9110/-/healthy",
+			Interval:                       "10s",
+			Timeout:                        "1s",
+			DeregisterCriticalServiceAfter: "10m",
+		},
+	}
+	if !reflect.DeepEqual(registered, want) {
+		t.Errorf("got service %+v, want %+v", registered, want)
+	}
+	if want := []string{"PUT /v1/agent/service/register", "PUT /v1/agent/service/deregister/mesos-exporter-exporter1:9110"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+}
+
+func TestVaultSecret(t *testing.T) {
+	var logins, renewals int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["role_id"] != "exporter" || req["secret_id"] != "s3cret" {
+			http.Error(w, `{"errors":["invalid role or secret ID"]}`, http.StatusBadRequest)
+			return
+		}
+		logins++
+		w.Write([]byte(`{"auth":{"client_token":"v1","lease_duration":3600,"renewable":true}}`))
+	})
+	mux.HandleFunc("/v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		renewals++
+		w.Write([]byte(`{"auth":{"client_token":"v1","lease_duration":3600,"renewable":true}}`))
+	})
+	mux.HandleFunc("/v1/secret/data/mesos-exporter", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "v1" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"token":"acs"},"metadata":{"version":1}},"lease_duration":0}`))
+	})
+	vaultServer := httptest.NewServer(mux)
+	defer vaultServer.Close()
+
+	secretID := filepath.Join(t.TempDir(), "secret-id")
+	if err := os.WriteFile(secretID, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vault, err := newVaultClient(vaultConfig{URL: vaultServer.URL, AppRole: &vaultAppRole{RoleID: "exporter", SecretIDFile: secretID}}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth, err := vault.renew(); err != nil || auth.LeaseDuration != 3600 {
+		t.Fatalf("got lease %+v, error %v", auth, err)
+	}
+
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token=acs" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer mesos.Close()
+	client, err := (&dcosConfig{VaultSecret: "secret/data/mesos-exporter"}).newClient(time.Second, vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Get(mesos.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got %s, want 200 OK", res.Status)
+	}
+	if logins != 1 || renewals != 1 {
+		t.Errorf("got %d logins and %d renewals, want 1 each", logins, renewals)
+	}
+}
+
+func TestVaultSecret_Lease(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("v1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		lease int
+		want  time.Duration
+	}{
+		{0, defaultSecretTTL},
+		{60, time.Minute},
+		// KV version 1 without a refresh interval.
+		{2764800, defaultSecretTTL},
+	} {
+		vaultServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"data":{"token":"acs"},"lease_duration":%d}`, tt.lease)
+		}))
+		vault, err := newVaultClient(vaultConfig{URL: vaultServer.URL, TokenFile: tokenFile}, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &vaultSecret{vault: vault, path: "secret/mesos-exporter"}
+		start := time.Now()
+		if _, err := s.get(); err != nil {
+			t.Fatal(err)
+		}
+		vaultServer.Close()
+		if got := s.expires.Sub(start); got < tt.want || got > tt.want+time.Second {
+			t.Errorf("test #%d: got expiry after %s, want %s", i, got, tt.want)
 		}
 	}
 }
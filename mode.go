@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// Scrape modes selecting the collector sets to register.
+const (
+	modeMaster = "master"
+	modeAgent  = "agent"
+	modeBoth   = "both"
+)
+
+// scrapeTargets resolves the master and slave URLs to collect from. Without
+// a mode, exactly one of master, slave or target is expected and the role of
+// target is detected. With a mode, the given URLs are checked to actually
+// point to nodes of the expected role.
+func scrapeTargets(mode, master, slave, target string, opts collector.Options) (string, string, error) {
+	switch mode {
+	case "":
+		if master != "" && slave != "" {
+			return "", "", fmt.Errorf("only -master or -slave can be given at a time, use -scrape-mode=%s for both", modeBoth)
+		}
+		if target == "" {
+			return master, slave, nil
+		}
+		if master != "" || slave != "" {
+			return "", "", fmt.Errorf("-target can't be combined with -master or -slave")
+		}
+		role, err := collector.DetectRole(target, opts)
+		if err != nil {
+			return "", "", fmt.Errorf("couldn't detect role of %s: %s", target, err)
+		}
+		if role == collector.RoleMaster {
+			return target, "", nil
+		}
+		return "", target, nil
+
+	case modeMaster:
+		if master == "" {
+			master = target
+		}
+		if master == "" || slave != "" {
+			return "", "", fmt.Errorf("-scrape-mode=%s requires only -master or -target", mode)
+		}
+		return master, "", validateRole(master, collector.RoleMaster, opts)
+
+	case modeAgent:
+		if slave == "" {
+			slave = target
+		}
+		if slave == "" || master != "" {
+			return "", "", fmt.Errorf("-scrape-mode=%s requires only -slave or -target", mode)
+		}
+		return "", slave, validateRole(slave, collector.RoleSlave, opts)
+
+	case modeBoth:
+		if master == "" || slave == "" || target != "" {
+			return "", "", fmt.Errorf("-scrape-mode=%s requires -master and -slave", mode)
+		}
+		if err := validateRole(master, collector.RoleMaster, opts); err != nil {
+			return "", "", err
+		}
+		return master, slave, validateRole(slave, collector.RoleSlave, opts)
+	}
+	return "", "", fmt.Errorf("unknown -scrape-mode %q, want %s, %s or %s", mode, modeMaster, modeAgent, modeBoth)
+}
+
+// validateRole fails if url points to a node with a role other than want. An
+// unreachable node isn't considered an error, as it may just not be up yet.
+func validateRole(url, want string, opts collector.Options) error {
+	role, err := collector.DetectRole(url, opts)
+	if err != nil {
+		log.Printf("Couldn't validate role of %s: %s", url, err)
+		return nil
+	}
+	if role != want {
+		return fmt.Errorf("%s runs a %s, not a %s", url, role, want)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+)
+
+// OTLP protocols supported by -otlp-protocol.
+const (
+	otlpGRPC = "grpc"
+	otlpHTTP = "http/protobuf"
+)
+
+// otlpExport returns a function sending metrics to an OTLP receiver such as
+// the OpenTelemetry Collector. Endpoints with http scheme are used without
+// TLS. The exporters also honor the usual OTEL_EXPORTER_OTLP_* environment
+// variables, e.g. for headers and certificates.
+func otlpExport(endpoint, protocol string, timeout time.Duration) (func([]*dto.MetricFamily) error, error) {
+	ctx := context.Background()
+
+	var (
+		exp sdkmetric.Exporter
+		err error
+	)
+	switch protocol {
+	case otlpGRPC:
+		exp, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(endpoint), otlpmetricgrpc.WithTimeout(timeout))
+	case otlpHTTP:
+		exp, err = otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint), otlpmetrichttp.WithTimeout(timeout))
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sdkresource.Merge(sdkresource.Default(), sdkresource.NewSchemaless(attribute.String("service.name", "mesos-exporter")))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(mfs []*dto.MetricFamily) error {
+		g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+		sm, err := promBridge.NewMetricProducer(promBridge.WithGatherer(g)).Produce(ctx)
+		if err != nil {
+			return err
+		}
+		return exp.Export(ctx, &metricdata.ResourceMetrics{Resource: res, ScopeMetrics: sm})
+	}, nil
+}
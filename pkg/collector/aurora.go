@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// auroraTaskStates are the states of Aurora tasks, which are part of the
+// names of its task vars, longest first so e.g. SANDBOX_DELETED isn't taken
+// for DELETED.
+var auroraTaskStates = func() []string {
+	s := []string{
+		"INIT", "PENDING", "THROTTLED", "ASSIGNED", "STARTING", "RUNNING",
+		"FINISHED", "PREEMPTING", "RESTARTING", "DRAINING", "FAILED", "KILLED",
+		"KILLING", "LOST", "PARTITIONED", "SANDBOX_DELETED", "DELETED",
+	}
+	sort.Slice(s, func(i, j int) bool { return len(s[i]) > len(s[j]) })
+	return s
+}()
+
+type auroraCollector struct {
+	*http.Client
+	url string
+	up  collectorUp
+
+	uptime      *prometheus.Desc
+	registered  *prometheus.Desc
+	lifecycle   *prometheus.Desc
+	tasks       *prometheus.Desc
+	transitions *prometheus.Desc
+	jobTasks    *prometheus.Desc
+}
+
+// NewAuroraCollector returns a collector of the vars of the Aurora
+// scheduler running on url. Of its vars, those about the scheduler itself
+// and its tasks are exported, the latter with the role, environment and job
+// parsed from the var names.
+func NewAuroraCollector(url string, opts Options) prometheus.Collector {
+	return &auroraCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("aurora", opts),
+
+		uptime: gaugeDesc(
+			"mesos_aurora_uptime_seconds",
+			"Time the Aurora scheduler is running",
+			nil, nil,
+		),
+		registered: gaugeDesc(
+			"mesos_aurora_framework_registered",
+			"Whether the Aurora scheduler is registered with the Mesos master",
+			nil, nil,
+		),
+		lifecycle: gaugeDesc(
+			"mesos_aurora_scheduler_lifecycle",
+			"Whether the Aurora scheduler is in a lifecycle state, e.g. ACTIVE",
+			[]string{"state"}, nil,
+		),
+		tasks: gaugeDesc(
+			"mesos_aurora_tasks",
+			"Number of tasks in the store of the Aurora scheduler by state",
+			[]string{"state"}, nil,
+		),
+		transitions: counterDesc(
+			"mesos_aurora_task_transitions_total",
+			"Total number of tasks that entered a state",
+			[]string{"state"}, nil,
+		),
+		jobTasks: counterDesc(
+			"mesos_aurora_job_task_transitions_total",
+			"Total number of tasks of a job that entered a state",
+			[]string{"role", "environment", "job", "state"}, nil,
+		),
+	}
+}
+
+func (c *auroraCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/vars.json"
+	var vars map[string]interface{}
+	if err := getJSON(ctx, c.Client, u, &vars); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for name, v := range vars {
+		// Vars which aren't numbers, e.g. build info, aren't exported.
+		f, ok := v.(float64)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "jvm_uptime_secs":
+			ch <- constMetric(c.uptime, f)
+		case name == "framework_registered":
+			ch <- constMetric(c.registered, f)
+		case strings.HasPrefix(name, "scheduler_lifecycle_"):
+			ch <- constMetric(c.lifecycle, f, strings.TrimPrefix(name, "scheduler_lifecycle_"))
+		case strings.HasPrefix(name, "task_store_"):
+			if state := strings.TrimPrefix(name, "task_store_"); isAuroraTaskState(state) {
+				ch <- constMetric(c.tasks, f, state)
+			}
+		case strings.HasPrefix(name, "tasks_"):
+			c.collectTransitions(ch, strings.TrimPrefix(name, "tasks_"), f)
+		}
+	}
+}
+
+// collectTransitions exports the var tasks_<name>, where name is a task
+// state, optionally followed by the key of a job, <role>/<environment>/<job>.
+func (c *auroraCollector) collectTransitions(ch chan<- prometheus.Metric, name string, v float64) {
+	for _, state := range auroraTaskStates {
+		if name == state {
+			ch <- constMetric(c.transitions, v, state)
+			return
+		}
+		if !strings.HasPrefix(name, state+"_") {
+			continue
+		}
+		key := strings.Split(strings.TrimPrefix(name, state+"_"), "/")
+		if len(key) == 3 {
+			ch <- constMetric(c.jobTasks, v, key[0], key[1], key[2], state)
+		}
+		return
+	}
+}
+
+func isAuroraTaskState(s string) bool {
+	for _, state := range auroraTaskStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *auroraCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.uptime
+	ch <- c.registered
+	ch <- c.lifecycle
+	ch <- c.tasks
+	ch <- c.transitions
+	ch <- c.jobTasks
+}
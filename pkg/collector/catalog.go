@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Family is a metric family a collector can export.
+type Family struct {
+	Name string
+	// Type is counter, gauge, histogram or untyped.
+	Type string
+	Help string
+	// Labels are the variable labels of the family, followed by its
+	// constant label pairs in name="value" form.
+	Labels []string
+}
+
+// descFormat matches the String form of a prometheus.Desc, which has no
+// accessors for its parts.
+var descFormat = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{(.*)\}, variableLabels: \{(.*)\}\}$`)
+
+// Families returns the metric families c can export, sorted by name. Types
+// are taken from the metrics of c and from the types its descs were
+// created with, families of other descs are untyped.
+func Families(c prometheus.Collector) []Family {
+	types := map[*prometheus.Desc]string{}
+	switch c := c.(type) {
+	case *metricCollector:
+		for m := range c.metrics {
+			vectorTypes(types, m)
+		}
+	case *MasterStateCollector:
+		for m := range c.metrics {
+			vectorTypes(types, m)
+		}
+		vectorTypes(types, c.transitions.launch, c.transitions.duration)
+	}
+
+	var families []Family
+	for _, d := range describe(c) {
+		m := descFormat.FindStringSubmatch(d.String())
+		if m == nil {
+			continue
+		}
+		f := Family{Type: types[d]}
+		if t, ok := descTypes.Load(d); ok {
+			f.Type = valueTypeNames[t.(prometheus.ValueType)]
+		}
+		f.Name, _ = strconv.Unquote(m[1])
+		f.Help, _ = strconv.Unquote(m[2])
+		if m[4] != "" {
+			f.Labels = strings.Split(m[4], ",")
+		}
+		if m[3] != "" {
+			f.Labels = append(f.Labels, strings.Split(m[3], ",")...)
+		}
+		if f.Type == "" {
+			f.Type = "untyped"
+		}
+		families = append(families, f)
+	}
+	sort.SliceStable(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+var valueTypeNames = map[prometheus.ValueType]string{
+	prometheus.GaugeValue:   "gauge",
+	prometheus.CounterValue: "counter",
+}
+
+// vectorTypes adds the types of metrics and metric vectors to types.
+func vectorTypes(types map[*prometheus.Desc]string, cs ...prometheus.Collector) {
+	for _, c := range cs {
+		var typ string
+		switch c.(type) {
+		// Gauges have the methods of counters, so they go first.
+		case *prometheus.GaugeVec, prometheus.Gauge:
+			typ = "gauge"
+		case *prometheus.CounterVec, *settableCounterVec, prometheus.Counter:
+			typ = "counter"
+		case *prometheus.HistogramVec, prometheus.Histogram:
+			typ = "histogram"
+		default:
+			continue
+		}
+		for _, d := range describe(c) {
+			types[d] = typ
+		}
+	}
+}
+
+func describe(c prometheus.Collector) []*prometheus.Desc {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	var descs []*prometheus.Desc
+	for d := range ch {
+		descs = append(descs, d)
+	}
+	return descs
+}
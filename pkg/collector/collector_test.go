@@ -0,0 +1,1600 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPortRange_UnmarshalJSON(t *testing.T) {
+	for i, tt := range []struct {
+		data string
+		want Ranges
+		err  error
+	}{
+		{"[]", nil, nil},
+		{`"[]"`, nil, nil},
+		{`"[0-15]"`, Ranges{{0, 15}}, nil},
+		{`"[0-15, 17-20]"`, Ranges{{0, 15}, {17, 20}}, nil},
+	} {
+		var rs Ranges
+		if err := json.Unmarshal([]byte(tt.data), &rs); !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("test #%d: got err: %v, want: %v", i, err, tt.want)
+		}
+
+		if got := rs; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got: %v, want: %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestState_UnmarshalJSON(t *testing.T) {
+	for i, tt := range []struct {
+		data      string
+		slaves    int
+		tasks     int
+		completed int
+	}{
+		{`{"version":"0.28.2","slaves":[{"pid":"a"}],"frameworks":[{"tasks":[{}],"completed_tasks":[{},{}]}]}`, 1, 1, 2},
+		{`{"version":"1.4.0","agents":[{"pid":"a"},{"pid":"b"}]}`, 2, 0, 0},
+		{`{"version":"1.4.0","slaves":[{"pid":"a"}]}`, 1, 0, 0},
+		{`{"version":"1.4.0","slaves":[{"pid":"a"}],"agents":[{"pid":"b"},{"pid":"c"}]}`, 1, 0, 0},
+		{`{"version":"bogus","agents":[{"pid":"a"}]}`, 1, 0, 0},
+		{`{"frameworks":[{"executors":[{"tasks":[{},{}],"completed_tasks":[{}]}],"completed_executors":[{"completed_tasks":[{}]}]}]}`, 0, 2, 2},
+	} {
+		var st State
+		if err := json.Unmarshal([]byte(tt.data), &st); err != nil {
+			t.Fatalf("test #%d: unexpected error: %v", i, err)
+		}
+		if got := len(st.Slaves); got != tt.slaves {
+			t.Errorf("test #%d: got %d slaves, want: %d", i, got, tt.slaves)
+		}
+		var tasks, completed int
+		for _, f := range st.Frameworks {
+			tasks += len(f.Tasks)
+			completed += len(f.Completed)
+		}
+		if tasks != tt.tasks || completed != tt.completed {
+			t.Errorf("test #%d: got %d/%d tasks, want: %d/%d", i, tasks, completed, tt.tasks, tt.completed)
+		}
+	}
+}
+
+func TestSlave_UnmarshalJSON(t *testing.T) {
+	for i, tt := range []struct {
+		data      string
+		used      Resources
+		total     Resources
+		malformed bool
+	}{
+		{
+			data:  `{"used_resources":{"cpus":1,"disk":2,"mem":3},"unreserved_resources":{"cpus":0,"disk":0,"mem":0},"resources":{"cpus":4,"disk":5,"mem":6}}`,
+			used:  Resources{CPUs: 1, Disk: 2, Mem: 3},
+			total: Resources{CPUs: 4, Disk: 5, Mem: 6},
+		},
+		{
+			data:  `{"unreserved_resources":{"cpus":0,"disk":0,"mem":0},"resources":{"cpus":4}}`,
+			used:  Resources{missing: []string{"cpus", "disk", "mem"}},
+			total: Resources{CPUs: 4, missing: []string{"disk", "mem"}},
+		},
+		{
+			data:      `{"used_resources":[],"unreserved_resources":{"cpus":0,"disk":0,"mem":0},"resources":{"cpus":"4","disk":5,"mem":6}}`,
+			malformed: true,
+		},
+	} {
+		var s Slave
+		if err := json.Unmarshal([]byte(tt.data), &s); err != nil {
+			t.Fatalf("test #%d: unexpected error: %v", i, err)
+		}
+		if got := s.malformed != nil; got != tt.malformed {
+			t.Errorf("test #%d: got malformed: %v, want: %v", i, s.malformed, tt.malformed)
+		}
+		if tt.malformed {
+			continue
+		}
+		if !reflect.DeepEqual(s.Used, tt.used) || !reflect.DeepEqual(s.Total, tt.total) {
+			t.Errorf("test #%d: got: %+v/%+v, want: %+v/%+v", i, s.Used, s.Total, tt.used, tt.total)
+		}
+	}
+}
+
+func TestResources_Items(t *testing.T) {
+	data := `[
+		{"name":"cpus","type":"SCALAR","scalar":{"value":2},"role":"*"},
+		{"name":"cpus","type":"SCALAR","scalar":{"value":1.5},"revocable":{}},
+		{"name":"mem","type":"SCALAR","scalar":{"value":512},"role":"web","reservation":{"principal":"ops"}},
+		{"name":"mem","type":"SCALAR","scalar":{"value":256},"reservations":[{"type":"STATIC","role":"web"},{"type":"DYNAMIC","role":"web/api","principal":"api"}]},
+		{"name":"disk","type":"SCALAR","scalar":{"value":100}},
+		{"name":"disk","type":"SCALAR","scalar":{"value":200},"disk":{"source":{"type":"MOUNT","mount":{"root":"/mnt/a"}}}},
+		{"name":"ports","type":"RANGES","ranges":{"range":[{"begin":31000,"end":31009},{"begin":31020,"end":31020}]}}
+	]`
+	var rs Resources
+	if err := json.Unmarshal([]byte(data), &rs); err != nil {
+		t.Fatal(err)
+	}
+	if rs.CPUs != 3.5 || rs.Mem != 768 || rs.Disk != 300 || !reflect.DeepEqual(rs.Ports, Ranges{{31000, 31009}, {31020, 31020}}) {
+		t.Errorf("got totals %+v", rs)
+	}
+	want := map[resourceKey]float64{
+		{"cpus", "*", "", "false", ""}:         2,
+		{"cpus", "*", "", "true", ""}:          1.5,
+		{"mem", "web", "ops", "false", ""}:     512,
+		{"mem", "web/api", "api", "false", ""}: 256,
+		{"disk", "*", "", "false", "root"}:     100,
+		{"disk", "*", "", "false", "mount"}:    200,
+		{"ports", "*", "", "false", ""}:        11,
+	}
+	if got := rs.sums(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+
+	// The items are encoded as an array again, e.g. in state dumps.
+	b, err := json.Marshal(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Resources
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, rs) {
+		t.Errorf("got %s decoded as %+v, want: %+v", b, decoded, rs)
+	}
+}
+
+func TestState_DedupeSlaves(t *testing.T) {
+	st := State{Slaves: []Slave{
+		{ID: "a", PID: "slave(1)@10.0.0.1:5051", Hostname: "h1", Port: 5051, Active: true},
+		{ID: "b", PID: "slave(1)@10.0.0.2:5051", Hostname: "h2", Port: 5051},
+		{ID: "a", PID: "slave(2)@10.0.0.1:5051", Hostname: "h1", Port: 5051},
+		{ID: "c", PID: "slave(1)@10.0.0.3:5051", Hostname: "h3", Port: 5051},
+		{ID: "c", PID: "slave(2)@10.0.0.3:5051", Hostname: "h3", Port: 5051},
+		{ID: "d", PID: "slave(3)@10.0.0.2:5051", Hostname: "h2", Port: 5051, Active: true},
+	}}
+	st.dedupeSlaves()
+
+	var got []string
+	for _, s := range st.Slaves {
+		got = append(got, s.PID)
+	}
+	want := []string{"slave(1)@10.0.0.1:5051", "slave(2)@10.0.0.3:5051", "slave(3)@10.0.0.2:5051"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestState_LargestTasks(t *testing.T) {
+	st := State{Slaves: []Slave{
+		{ID: "a", Active: true, Total: Resources{CPUs: 8, Mem: 4096}, Used: Resources{CPUs: 2, Mem: 3584}},
+		{ID: "b", Active: true, Total: Resources{CPUs: 4, Mem: 8192}, Used: Resources{CPUs: 2, Mem: 1024}},
+		{ID: "c", Total: Resources{CPUs: 32, Mem: 65536}},
+	}}
+	byCPUs, byMem, ok := st.largestTasks()
+	if !ok {
+		t.Fatal("no active slaves")
+	}
+	if want := (Resources{CPUs: 6, Mem: 512}); !reflect.DeepEqual(byCPUs, want) {
+		t.Errorf("by cpus got: %+v, want: %+v", byCPUs, want)
+	}
+	if want := (Resources{CPUs: 2, Mem: 7168}); !reflect.DeepEqual(byMem, want) {
+		t.Errorf("by mem got: %+v, want: %+v", byMem, want)
+	}
+	if _, _, ok := (&State{}).largestTasks(); ok {
+		t.Error("got largest tasks without slaves")
+	}
+}
+
+func TestFramework_Shares(t *testing.T) {
+	st := State{Slaves: []Slave{
+		{Total: Resources{CPUs: 8, Mem: 16384}},
+		{Total: Resources{CPUs: 8, Mem: 16384}},
+	}}
+	f := Framework{Resources: Resources{CPUs: 4, Mem: 12288}}
+	got := f.shares(st.totalResources())
+	want := map[string]float64{"cpus": 0.25, "mem": 0.375}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestLeaderChanges(t *testing.T) {
+	l := newLeaderChanges()
+	for i, tt := range []struct {
+		st          State
+		count       float64
+		changedTime float64
+	}{
+		{State{Leader: "master@10.0.0.1:5050", ElectedTime: 100}, 0, 100},
+		{State{Leader: "master@10.0.0.1:5050", ElectedTime: 100}, 0, 100},
+		// Election in progress
+		{State{}, 0, 100},
+		// Restarted and reelected
+		{State{Leader: "master@10.0.0.1:5050", ElectedTime: 200}, 1, 200},
+		// Scraped through a follower
+		{State{Leader: "master@10.0.0.2:5050"}, 2, -1},
+		{State{Leader: "master@10.0.0.2:5050", ElectedTime: 300}, 2, 300},
+	} {
+		l.observe(&tt.st)
+		if l.count != tt.count {
+			t.Errorf("%d: got %v failovers, want %v", i, l.count, tt.count)
+		}
+		if tt.changedTime == -1 {
+			if time.Since(time.Unix(int64(l.changedTime), 0)) > time.Minute {
+				t.Errorf("%d: got changed time %v, want about now", i, l.changedTime)
+			}
+		} else if l.changedTime != tt.changedTime {
+			t.Errorf("%d: got changed time %v, want %v", i, l.changedTime, tt.changedTime)
+		}
+	}
+}
+
+func TestTask_LabelsMetric(t *testing.T) {
+	for i, tt := range []struct {
+		task Task
+		want string
+	}{
+		{Task{ID: "t1", FrameworkID: "f1"}, `{framework="f1",task="t1"} 1`},
+		{
+			Task{ID: "t2", FrameworkID: "f1", Labels: []Label{{"team", "infra"}, {"DCOS_PACKAGE_NAME", "kafka"}, {"dcos.io/tier", "a"}, {"dcos_io_tier", "b"}}},
+			`{framework="f1",label_DCOS_PACKAGE_NAME="kafka",label_dcos_io_tier="a",label_team="infra",task="t2"} 1`,
+		},
+	} {
+		var pb dto.Metric
+		if err := tt.task.labelsMetric().Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		if got := metricString(&pb); got != tt.want {
+			t.Errorf("%d: got %s, want %s", i, got, tt.want)
+		}
+	}
+}
+
+func TestTask_Ports(t *testing.T) {
+	for i, tt := range []struct {
+		task string
+		want []taskPort
+	}{
+		{`{}`, nil},
+		// Host network
+		{
+			`{"discovery":{"ports":{"ports":[{"number":31000,"name":"http","protocol":"tcp"},{"number":31001,"protocol":"udp"}]}}}`,
+			[]taskPort{{"http", "tcp", "31000", ""}, {"", "udp", "31001", ""}},
+		},
+		// Bridged Docker container announcing its host ports
+		{
+			`{"discovery":{"ports":{"ports":[{"number":31000,"name":"http","protocol":"tcp"}]}},
+			"container":{"docker":{"port_mappings":[{"host_port":31000,"container_port":80,"protocol":"tcp"},{"host_port":31001,"container_port":9090}]}}}`,
+			[]taskPort{{"http", "tcp", "31000", "80"}, {"", "", "31001", "9090"}},
+		},
+		// Container network announcing its container ports
+		{
+			`{"discovery":{"ports":{"ports":[{"number":8080,"name":"api","protocol":"tcp"}]}},
+			"container":{"network_infos":[{"port_mappings":[{"container_port":8080,"protocol":"tcp"}]}]}}`,
+			[]taskPort{{"api", "tcp", "", "8080"}},
+		},
+	} {
+		var task Task
+		if err := json.Unmarshal([]byte(tt.task), &task); err != nil {
+			t.Fatal(err)
+		}
+		if got := task.ports(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: got %+v, want %+v", i, got, tt.want)
+		}
+	}
+}
+
+func TestFramework_Capabilities(t *testing.T) {
+	f := Framework{Capabilities: []string{"PARTITION_AWARE", "MULTI_ROLE", "UNKNOWN_CAPABILITY"}}
+	got := f.capabilities()
+	for c, want := range map[string]bool{
+		"PARTITION_AWARE":    true,
+		"MULTI_ROLE":         true,
+		"UNKNOWN_CAPABILITY": true,
+		"GPU_RESOURCES":      false,
+		"REGION_AWARE":       false,
+	} {
+		if has, ok := got[c]; !ok || has != want {
+			t.Errorf("%s: got %v (exported: %v), want %v", c, has, ok, want)
+		}
+	}
+}
+
+func TestStateFilter_CompletedRetention(t *testing.T) {
+	now := float64(time.Now().Unix())
+	tasks := []Task{
+		{ID: "old", Statuses: []Status{{Timestamp: now - 7200}}},
+		{ID: "restarted", Statuses: []Status{{Timestamp: now - 7200}, {Timestamp: now - 60}}},
+		{ID: "new", Statuses: []Status{{Timestamp: now - 60}}},
+		{ID: "unknown"},
+	}
+	st := State{Frameworks: []Framework{{Active: true, Completed: tasks}}}
+	StateFilter{CompletedRetention: time.Hour}.apply(&st)
+
+	var got []string
+	for _, t := range st.Frameworks[0].Completed {
+		got = append(got, t.ID)
+	}
+	if want := []string{"restarted", "new", "unknown"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestStateFilter_MaxCompleted(t *testing.T) {
+	for i, tt := range []struct {
+		max     int
+		want    []string
+		skipped int
+	}{
+		{max: 0, want: []string{"a", "b", "c"}},
+		{max: 3, want: []string{"a", "b", "c"}},
+		{max: 2, want: []string{"b", "c"}, skipped: 1},
+	} {
+		filtered := State{Frameworks: []Framework{{Active: true, Completed: []Task{{ID: "a"}, {ID: "b"}, {ID: "c"}}}}}
+		StateFilter{MaxCompleted: tt.max}.apply(&filtered)
+
+		// Fetched states are cut short while decoding already.
+		decoded := State{maxCompleted: tt.max}
+		doc := `{"version":"1.4.0","frameworks":[{"id":"f","active":true,"unknown":{"x":[1]},"tasks":null,
+			"completed_tasks":[{"id":"a"},{"id":"b"},{"id":"c"}]}],"slaves":[]}`
+		if err := decoded.decodeStream(json.NewDecoder(strings.NewReader(doc))); err != nil {
+			t.Fatal(err)
+		}
+		StateFilter{MaxCompleted: tt.max}.apply(&decoded)
+
+		for _, st := range []State{filtered, decoded} {
+			var got []string
+			for _, t := range st.Frameworks[0].Completed {
+				got = append(got, t.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("test #%d: got: %v, want: %v", i, got, tt.want)
+			}
+			if got := st.Frameworks[0].skippedCompleted; got != tt.skipped {
+				t.Errorf("test #%d: got skipped: %d, want: %d", i, got, tt.skipped)
+			}
+		}
+		if f := decoded.Frameworks[0]; f.ID != "f" || !f.Active || decoded.Version != "1.4.0" {
+			t.Errorf("test #%d: got %+v", i, decoded)
+		}
+	}
+}
+
+func TestTaskLabeler(t *testing.T) {
+	marathon := Framework{ID: "f1", Name: "marathon"}
+	spark := Framework{ID: "20150101-0000-0042", Name: "etl"}
+	other := Framework{ID: "f2", Name: "other"}
+	for _, tc := range []struct {
+		f    Framework
+		t    Task
+		want TaskIdentity
+	}{
+		{marathon, Task{ID: "prod_web.6f8b1dc2-0d6a-11e6-b2ba-0242ac110002"}, TaskIdentity{AppID: "/prod/web"}},
+		{marathon, Task{ID: "prod_web.instance-6f8b1dc2-0d6a-11e6-b2ba-0242ac110002._app.1"}, TaskIdentity{AppID: "/prod/web"}},
+		{other, Task{ID: "driver-20150101000000-0001"}, TaskIdentity{JobID: "driver-20150101000000-0001"}},
+		{spark, Task{ID: "3", Name: "etl 3"}, TaskIdentity{AppID: "20150101-0000-0042"}},
+		{other, Task{ID: "t1", Name: "jenkins-deploy-api-42"}, TaskIdentity{AppID: "deploy-api", JobID: "jenkins-deploy-api-42"}},
+		{other, Task{ID: "t1", Name: "agent", Labels: []Label{{"BUILD_TAG", "jenkins-build-7"}}}, TaskIdentity{AppID: "build", JobID: "jenkins-build-7"}},
+		{other, Task{ID: "t1", Name: "web"}, TaskIdentity{}},
+	} {
+		if got := taskLabeler(WellKnownEnrichers).identity(&tc.f, &tc.t); got != tc.want {
+			t.Errorf("%s/%s: got %+v, want %+v", tc.f.Name, tc.t.ID, got, tc.want)
+		}
+	}
+
+	task := Task{ID: "prod_web.1", SlaveID: "s1", State: "TASK_RUNNING"}
+	l := taskLabeler(WellKnownEnrichers)
+	if got, want := l.names(), []string{"slave", "task", "executor", "name", "framework", "app_id", "job_id", "state"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got names %v, want %v", got, want)
+	}
+	if got, want := l.values(&marathon, &task), []string{"s1", "prod_web.1", "", "", "", "/prod/web", "", "TASK_RUNNING"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got values %v, want %v", got, want)
+	}
+	if got := taskLabeler(nil).names(); !reflect.DeepEqual(got, taskLabels) {
+		t.Errorf("got names %v without enrichers, want %v", got, taskLabels)
+	}
+}
+
+func TestCompiledRule_Collect(t *testing.T) {
+	var doc interface{}
+	data := `{"slaves":[{"pid":"a","resources":{"gpus":2}},{"pid":"b","resources":{}}],"master/elected":1,
+		"agents":{"y":{"host":"h","cpus":2},"x":{"host":"h","cpus":1}}}`
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range []struct {
+		rule MappingRule
+		want []string
+	}{
+		{
+			MappingRule{Items: "slaves", Value: "resources.gpus", Name: "gpus", Labels: map[string]string{"slave": "pid", "index": "$key"}},
+			[]string{`{index="0",slave="a"} 2`},
+		},
+		{
+			MappingRule{Value: "master/elected", Name: "elected", Type: "counter"},
+			[]string{`{} 1`},
+		},
+		{
+			MappingRule{Value: "slaves.1.pid", Name: "not_a_number"},
+			nil,
+		},
+		{
+			// Colliding items are exported once, the first by key.
+			MappingRule{Items: "agents", Value: "cpus", Name: "cpus", Labels: map[string]string{"host": "host"}, Unique: "host"},
+			[]string{`{host="h"} 1`},
+		},
+	} {
+		ch := make(chan prometheus.Metric, 10)
+		NewMappingCollector(RoleMaster, "", Options{}, []MappingRule{tt.rule}).(*mappingCollector).rules[0].collect(doc, ch)
+		close(ch)
+
+		var got []string
+		for m := range ch {
+			var pb dto.Metric
+			if err := m.Write(&pb); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, metricString(&pb))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got: %v, want: %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestMappingRule_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		rule MappingRule
+		ok   bool
+	}{
+		{MappingRule{Endpoint: "/state", Items: "slaves", Value: "resources.gpus", Name: "gpus", Labels: map[string]string{"index": "$key"}}, true},
+		{MappingRule{Endpoint: "/state", Items: "slaves", Value: "resources.gpus", Name: "gpus", Labels: map[string]string{"slave": "id"}, Unique: "slave"}, true},
+		{MappingRule{Endpoint: "/state", Items: "slaves", Value: "resources.gpus", Name: "gpus", Labels: map[string]string{"slave": "id"}}, false},
+		{MappingRule{Endpoint: "/state", Items: "slaves", Value: "resources.gpus", Name: "gpus", Labels: map[string]string{"slave": "id"}, Unique: "id"}, false},
+		{MappingRule{Endpoint: "/metrics/snapshot", Value: "master/elected", Name: "elected"}, true},
+	} {
+		if err := tt.rule.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: got error %v, want ok %t", tt.rule, err, tt.ok)
+		}
+	}
+}
+
+// metricString formats a metric like the text exposition format without
+// the metric name.
+func metricString(m *dto.Metric) string {
+	var labels []string
+	for _, lp := range m.GetLabel() {
+		labels = append(labels, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+	}
+	var v float64
+	switch {
+	case m.Gauge != nil:
+		v = m.GetGauge().GetValue()
+	case m.Counter != nil:
+		v = m.GetCounter().GetValue()
+	case m.Untyped != nil:
+		v = m.GetUntyped().GetValue()
+	}
+	return fmt.Sprintf("{%s} %g", strings.Join(labels, ","), v)
+}
+
+func TestTaskTransitions(t *testing.T) {
+	tr := newTaskTransitions("", nil)
+	scrape := func(tasks ...Task) {
+		tr.observe(&State{Frameworks: []Framework{{Tasks: tasks}}})
+	}
+
+	scrape(Task{ID: "a", FrameworkID: "f", State: "TASK_FINISHED"}, Task{ID: "b", FrameworkID: "f", State: "TASK_RUNNING"})
+	scrape(Task{ID: "a", FrameworkID: "f", State: "TASK_FINISHED"}, Task{ID: "b", FrameworkID: "f", State: "TASK_FAILED"}, Task{ID: "c", FrameworkID: "f", State: "TASK_FAILED"})
+	scrape(Task{ID: "b", FrameworkID: "f", State: "TASK_FAILED"}, Task{ID: "d", FrameworkID: "f", State: "TASK_KILLED"})
+	scrape(Task{ID: "e", FrameworkID: "f", State: "TASK_FAILED", Statuses: []Status{
+		{State: "TASK_RUNNING"},
+		{State: "TASK_FAILED", Reason: "REASON_CONTAINER_LIMITATION_MEMORY", Source: "SOURCE_SLAVE"},
+	}})
+
+	want := map[transitionKey]float64{
+		{"f", "TASK_FAILED", "", ""}: 2,
+		{"f", "TASK_KILLED", "", ""}: 1,
+		{"f", "TASK_FAILED", "REASON_CONTAINER_LIMITATION_MEMORY", "SOURCE_SLAVE"}: 1,
+	}
+	if !reflect.DeepEqual(tr.counts, want) {
+		t.Errorf("got: %v, want: %v", tr.counts, want)
+	}
+}
+
+func TestMasterStateCollector_Gone(t *testing.T) {
+	state := `{"slaves":[{"id":"s1","resources":{"cpus":4}},{"id":"s2","resources":{"cpus":2}}],
+		"frameworks":[{"id":"f","active":true,"tasks":[{"id":"t","framework_id":"f","slave_id":"s2","state":"TASK_RUNNING","resources":{"cpus":1}}]}]}`
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(state))
+	}))
+	defer master.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMasterStateCollector(master.URL, StateOptions{Options: Options{Timeout: time.Second}}))
+	series := func() map[string][]string {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string][]string{}
+		for _, mf := range mfs {
+			switch mf.GetName() {
+			case "mesos_slave_cpus", "mesos_task_cpus_limit":
+				for _, m := range mf.Metric {
+					got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+				}
+			}
+		}
+		return got
+	}
+	if got := series(); len(got["mesos_slave_cpus"]) != 2 || len(got["mesos_task_cpus_limit"]) != 1 {
+		t.Fatalf("got %v before s2 is gone", got)
+	}
+
+	// s2 is removed along with its task.
+	state = `{"slaves":[{"id":"s1","resources":{"cpus":4}}],"frameworks":[{"id":"f","active":true}]}`
+	want := map[string][]string{"mesos_slave_cpus": {`{slave="s1"} 4`}}
+	if got := series(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestMasterStateCollector_InactiveFramework(t *testing.T) {
+	var active bool
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"frameworks":[{"id":"f","active":%t,"completed_tasks":[{"id":"a","framework_id":"f","state":"TASK_FINISHED"}]}]}`, active)
+	}))
+	defer master.Close()
+
+	c := NewMasterStateCollector(master.URL, StateOptions{Options: Options{Timeout: time.Second}})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	// The framework fails over: it's inactive while its scheduler is down.
+	for _, active = range []bool{true, false, true} {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.transitions.counts) != 0 {
+		t.Errorf("got counts %v for a task finished before the first scrape", c.transitions.counts)
+	}
+}
+
+func TestTaskTransitions_Buckets(t *testing.T) {
+	tr := newTaskTransitions("", map[string][]float64{"mesos_task_duration_seconds": {10, 100}})
+	tr.observe(&State{})
+	tr.observe(&State{Frameworks: []Framework{{Tasks: []Task{{ID: "a", FrameworkID: "f", State: "TASK_FINISHED", Statuses: []Status{
+		{State: "TASK_STARTING", Timestamp: 100},
+		{State: "TASK_RUNNING", Timestamp: 102},
+		{State: "TASK_FINISHED", Timestamp: 150},
+	}}}}}})
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(tr)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, mf := range mfs {
+		if h := mf.Metric[0].GetHistogram(); h != nil {
+			got[mf.GetName()] = len(h.Bucket)
+		}
+	}
+	want := map[string]int{
+		"mesos_task_launch_latency_seconds": len(DefaultBuckets["mesos_task_launch_latency_seconds"]),
+		"mesos_task_duration_seconds":       2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestTaskTransitions_Exemplars(t *testing.T) {
+	tr := newTaskTransitions("", nil)
+	slaves := []Slave{{ID: "s1", Hostname: "host1"}}
+	tr.observe(&State{})
+	tr.observe(&State{Slaves: slaves, Frameworks: []Framework{{Tasks: []Task{{ID: "a", FrameworkID: "f", SlaveID: "s1", State: "TASK_FINISHED", Statuses: []Status{
+		{State: "TASK_STARTING", Timestamp: 100},
+		{State: "TASK_RUNNING", Timestamp: 102},
+		{State: "TASK_FINISHED", Timestamp: 150},
+	}}}}}})
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(tr)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, mf := range mfs {
+		h := mf.Metric[0].GetHistogram()
+		if h == nil {
+			continue
+		}
+		for _, b := range h.Bucket {
+			if e := b.GetExemplar(); e != nil {
+				// Exemplar labels are in random order.
+				sort.Slice(e.Label, func(i, j int) bool { return e.Label[i].GetName() < e.Label[j].GetName() })
+				got[mf.GetName()] = fmt.Sprintf("%s %g", metricString(&dto.Metric{Label: e.Label}), e.GetValue())
+			}
+		}
+	}
+	want := map[string]string{
+		"mesos_task_launch_latency_seconds": `{hostname="host1",task_id="a"} 0 2`,
+		"mesos_task_duration_seconds":       `{hostname="host1",task_id="a"} 0 50`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestMasterStateCollector_SlaveInfo(t *testing.T) {
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"slaves":[
+			{"id":"s1","pid":"slave(1)@10.0.0.1:5051","hostname":"h1","port":5051,"active":true,
+				"domain":{"fault_domain":{"region":{"name":"us-east-1"},"zone":{"name":"us-east-1a"}}}},
+			{"id":"s2","pid":"slave(1)@10.0.0.2:5051","hostname":"h2","port":5051,"active":true}]}`))
+	}))
+	defer master.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMasterStateCollector(master.URL, StateOptions{Options: Options{Timeout: time.Second}}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range mfs {
+		if mf.GetName() == "mesos_slave_info" {
+			for _, m := range mf.Metric {
+				got = append(got, metricString(m))
+			}
+		}
+	}
+	want := []string{
+		`{active="true",hostname="h1",pid="slave(1)@10.0.0.1:5051",port="5051",region="us-east-1",slave="s1",zone="us-east-1a"} 1`,
+		`{active="true",hostname="h2",pid="slave(1)@10.0.0.2:5051",port="5051",region="",slave="s2",zone=""} 1`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestCollectCounter(t *testing.T) {
+	// Failing collections are counted as well.
+	internal := NewInternal()
+	c := NewSlaveGCCollector("http://127.0.0.1:0", Options{Timeout: time.Second, Internal: internal})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	for i := 0; i < 2; i++ {
+		reg.Gather()
+	}
+	var m dto.Metric
+	if err := internal.collects.WithLabelValues("slave_gc").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetCounter().GetValue(); got != 2 {
+		t.Errorf("got %g collections, want 2", got)
+	}
+}
+
+func TestInternal(t *testing.T) {
+	// Errors are counted in the Internal of the failing collector, not in
+	// the default one.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	value := func(c prometheus.Metric) float64 {
+		var m dto.Metric
+		if err := c.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	before := value(defaultInternal.responseErrors.WithLabelValues("server_error"))
+
+	internal := NewInternal()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewSlaveCollector(ts.URL, Options{Timeout: time.Second, Internal: internal}))
+	reg.Gather()
+	for i, tt := range []struct {
+		c    prometheus.Metric
+		want float64
+	}{
+		{internal.errors, 1},
+		{internal.responseErrors.WithLabelValues("server_error"), 1},
+		{defaultInternal.responseErrors.WithLabelValues("server_error"), before},
+	} {
+		if got := value(tt.c); got != tt.want {
+			t.Errorf("%d: got %g, want %g", i, got, tt.want)
+		}
+	}
+}
+
+func TestParsePID(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want pid
+	}{
+		{"slave(1)@10.0.0.1:5051", pid{"slave(1)", "10.0.0.1", 5051}},
+		{"master@[2001:db8::1]:5050", pid{"master", "2001:db8::1", 5050}},
+		{"slave(1)@2001:db8::2:5051", pid{"slave(1)", "2001:db8::2", 5051}},
+		{"slave(1)@agent.example.com:5051", pid{"slave(1)", "agent.example.com", 5051}},
+	} {
+		got, err := parsePID(tc.in)
+		if err != nil {
+			t.Errorf("%s: %s", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"", "10.0.0.1:5051", "slave(1)@10.0.0.1", "slave(1)@host:port"} {
+		if _, err := parsePID(in); err == nil {
+			t.Errorf("%s: got no error", in)
+		}
+	}
+
+	s := Slave{Hostname: "2001:db8::2", PID: "slave(1)@[2001:db8::2]:5051"}
+	if got, want := s.address(), "[2001:db8::2]:5051"; got != want {
+		t.Errorf("got address %s, want %s", got, want)
+	}
+}
+
+func TestMasterStateCollector_ResourceUnits(t *testing.T) {
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"slaves":[{"id":"s1",
+			"used_resources_full":[{"name":"network_bandwidth","type":"SCALAR","scalar":{"value":100}},{"name":"cpus","type":"SCALAR","scalar":{"value":1}}],
+			"unreserved_resources_full":[{"name":"network_bandwidth","type":"SCALAR","scalar":{"value":900}}]}]}`))
+	}))
+	defer master.Close()
+
+	units := map[string]ResourceUnit{"network_bandwidth": {Unit: "bits_per_second", Scale: 1e6}}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMasterStateCollector(master.URL, StateOptions{Options: Options{Timeout: time.Second}, ResourceUnits: units}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range mfs {
+		if mf.GetName() == "mesos_slave_resource_network_bandwidth_bits_per_second" {
+			for _, m := range mf.Metric {
+				got = append(got, metricString(m))
+			}
+		}
+	}
+	want := []string{
+		`{disk_source="",principal="",revocable="false",role="*",slave="s1",type="unreserved"} 9e+08`,
+		`{disk_source="",principal="",revocable="false",role="*",slave="s1",type="used"} 1e+08`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestMasterStateCollector_Frameworks(t *testing.T) {
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"frameworks":[
+			{"id":"f1","name":"marathon","active":true,"checkpoint":true,"failover_timeout":604800,"pid":"scheduler-1@10.0.0.1:15101","tasks":[
+				{"id":"t1","slave_id":"s1","state":"TASK_RUNNING"},
+				{"id":"t2","slave_id":"s1","state":"TASK_RUNNING"},
+				{"id":"t3","slave_id":"s2","state":"TASK_STAGING"}]},
+			{"id":"f2","name":"test","active":true,"checkpoint":false,"failover_timeout":0,"tasks":[
+				{"id":"t4","slave_id":"s1","state":"TASK_RUNNING"},
+				{"id":"t5","slave_id":"s2","state":"TASK_KILLED"}]}],
+			"slaves":[{"id":"s1"},{"id":"s2"},{"id":"s3"}]}`))
+	}))
+	defer master.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMasterStateCollector(master.URL, StateOptions{Options: Options{Timeout: time.Second}}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "mesos_framework_checkpoint", "mesos_framework_failover_timeout_seconds", "mesos_frameworks_api", "mesos_slave_frameworks", "mesos_framework_slaves":
+			for _, m := range mf.Metric {
+				got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+			}
+		}
+	}
+	want := map[string][]string{
+		"mesos_framework_checkpoint":               {`{framework="f1",name="marathon"} 1`, `{framework="f2",name="test"} 0`},
+		"mesos_framework_failover_timeout_seconds": {`{framework="f1",name="marathon"} 604800`, `{framework="f2",name="test"} 0`},
+		"mesos_frameworks_api":                     {`{api="driver"} 1`, `{api="http"} 1`},
+		"mesos_slave_frameworks":                   {`{slave="s1"} 2`, `{slave="s2"} 1`, `{slave="s3"} 0`},
+		"mesos_framework_slaves":                   {`{framework="f1"} 2`, `{framework="f2"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestGetJSON_Status(t *testing.T) {
+	for i, tt := range []struct {
+		code   int
+		reason string
+	}{
+		{http.StatusUnauthorized, "unauthorized"},
+		{http.StatusForbidden, "forbidden"},
+		{http.StatusTemporaryRedirect, "redirect"},
+		{http.StatusNotFound, "client_error"},
+		{http.StatusServiceUnavailable, "server_error"},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/elsewhere")
+			w.WriteHeader(tt.code)
+			w.Write([]byte("<html>not json</html>"))
+		}))
+
+		var v interface{}
+		err := getJSON(context.Background(), NewHTTPClient(time.Second), srv.URL, &v)
+		srv.Close()
+
+		serr, ok := err.(*statusError)
+		if !ok {
+			t.Errorf("test #%d: got err: %v, want statusError", i, err)
+			continue
+		}
+		if got := serr.reason(); got != tt.reason {
+			t.Errorf("test #%d: got reason: %s, want: %s", i, got, tt.reason)
+		}
+	}
+}
+
+func TestTaskTransitions_Persistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "counters.json")
+
+	tr := newTaskTransitions(file, nil)
+	tr.observe(&State{Frameworks: []Framework{{Tasks: []Task{{ID: "a", FrameworkID: "f", State: "TASK_RUNNING"}}}}})
+	tr.observe(&State{Frameworks: []Framework{{Tasks: []Task{{ID: "a", FrameworkID: "f", State: "TASK_FAILED"}}}}})
+
+	// Task b started and finished while the exporter wasn't running.
+	restored := newTaskTransitions(file, nil)
+	restored.observe(&State{Frameworks: []Framework{{Tasks: []Task{
+		{ID: "a", FrameworkID: "f", State: "TASK_FAILED"},
+		{ID: "b", FrameworkID: "f", State: "TASK_FINISHED"},
+	}}}})
+
+	want := map[transitionKey]float64{
+		{"f", "TASK_FAILED", "", ""}:   1,
+		{"f", "TASK_FINISHED", "", ""}: 1,
+	}
+	if !reflect.DeepEqual(restored.counts, want) {
+		t.Errorf("got: %v, want: %v", restored.counts, want)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	for i, tt := range []struct {
+		json string
+		want map[schemaIssue]bool
+	}{
+		{
+			json: `{"id":"s1","pid":"slave(1)@10.0.0.1:5051","hostname":"a","active":true,"used_resources":{"cpus":1,"disk":0,"mem":0},"unreserved_resources":{"cpus":1,"disk":0,"mem":0,"ports":"[31000-32000]"},"resources":{"cpus":1,"disk":0,"mem":0},
+				"used_resources_full":[{"name":"cpus","type":"SCALAR","scalar":{"value":1},"role":"*"}]}`,
+			want: map[schemaIssue]bool{},
+		},
+		{
+			json: `{"id":"s1","pid":"slave(1)@10.0.0.1:5051","active":"true","used_resources":{"cpus":1,"disk":0,"mem":0,"gpus":1},"unreserved_resources":1,"resources":{"cpus":"1","disk":0,"mem":0}}`,
+			want: map[schemaIssue]bool{
+				{"hostname", schemaMissing}:          true,
+				{"active", schemaType}:               true,
+				{"unreserved_resources", schemaType}: true,
+				{"resources.cpus", schemaType}:       true,
+			},
+		},
+		{
+			// Unknown fields are only reported next to missing ones.
+			json: `{"id":"s1","pid":"slave(1)@10.0.0.1:5051","hostname":"a","active":true,"used_resources":{"cpus":1,"disk":0,"memory":0,"gpus":1},"unreserved_resources":{"cpus":1,"disk":0,"mem":0},"resources":{"cpus":1,"disk":0,"mem":0}}`,
+			want: map[schemaIssue]bool{
+				{"used_resources.mem", schemaMissing}:    true,
+				{"used_resources.memory", schemaUnknown}: true,
+				{"used_resources.gpus", schemaUnknown}:   true,
+			},
+		},
+	} {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(tt.json), &doc); err != nil {
+			t.Fatal(err)
+		}
+		got := map[schemaIssue]bool{}
+		checkSchema(doc, reflect.TypeOf(Slave{}), "", got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got: %v, want: %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestTaskTransitions_Events(t *testing.T) {
+	var got []TaskEvent
+	tr := newTaskTransitions("", nil)
+	tr.events = func(events []TaskEvent) { got = append(got, events...) }
+
+	slaves := []Slave{{ID: "s1", Hostname: "host1"}}
+	tr.observe(&State{Slaves: slaves, Frameworks: []Framework{{Tasks: []Task{
+		{ID: "a", Name: "web", FrameworkID: "f", SlaveID: "s1", State: "TASK_RUNNING"},
+	}}}})
+	tr.observe(&State{Slaves: slaves, Frameworks: []Framework{{
+		Tasks: []Task{{ID: "b", Name: "db", FrameworkID: "f", SlaveID: "s1", State: "TASK_STAGING"}},
+		Completed: []Task{{ID: "a", Name: "web", FrameworkID: "f", SlaveID: "s1", State: "TASK_FAILED", Statuses: []Status{
+			{State: "TASK_RUNNING", Timestamp: 100},
+			{State: "TASK_FAILED", Timestamp: 150, Reason: "REASON_COMMAND_EXECUTOR_FAILED", Source: "SOURCE_EXECUTOR"},
+		}}},
+	}}})
+
+	want := []TaskEvent{
+		{FrameworkID: "f", TaskID: "b", TaskName: "db", SlaveID: "s1", Hostname: "host1", State: "TASK_STAGING"},
+		{FrameworkID: "f", TaskID: "a", TaskName: "web", SlaveID: "s1", Hostname: "host1", State: "TASK_FAILED", PreviousState: "TASK_RUNNING",
+			Reason: "REASON_COMMAND_EXECUTOR_FAILED", Source: "SOURCE_EXECUTOR", Timestamp: 150},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+}
+
+func TestMarathonCollector(t *testing.T) {
+	marathon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/apps" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"apps":[{"id":"/web","instances":3,"tasksStaged":1,"tasksRunning":2,"tasksHealthy":1,"tasksUnhealthy":1,
+			"deployments":[{"id":"d1"}],"tasks":[{"id":"web.1","slaveId":"s1","state":"TASK_RUNNING"}]}]}`))
+	}))
+	defer marathon.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMarathonCollector(marathon.URL, Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up":             {`{collector="marathon"} 1`},
+		"mesos_marathon_app_instances":   {`{app="/web"} 3`},
+		"mesos_marathon_app_deployments": {`{app="/web"} 1`},
+		"mesos_marathon_app_tasks": {
+			`{app="/web",state="healthy"} 1`,
+			`{app="/web",state="running"} 2`,
+			`{app="/web",state="staged"} 1`,
+			`{app="/web",state="unhealthy"} 1`,
+		},
+		"mesos_marathon_task_info": {`{app="/web",slave="s1",state="TASK_RUNNING",task="web.1"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestMetronomeCollector(t *testing.T) {
+	metronome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[{"id":"backup","historySummary":{"successCount":4,"failureCount":1,
+			"lastSuccessAt":"2016-07-26T07:42:05.500+0000","lastFailureAt":""},
+			"activeRuns":[{"id":"20160726074500abc","status":"ACTIVE","tasks":[{"id":"backup_20160726074500abc.t1","status":"TASK_RUNNING"}]}]}]`))
+	}))
+	defer metronome.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMetronomeCollector(metronome.URL, Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up":                                 {`{collector="metronome"} 1`},
+		"mesos_metronome_job_runs_total":                     {`{job="backup",result="failure"} 1`, `{job="backup",result="success"} 4`},
+		"mesos_metronome_job_last_success_timestamp_seconds": {`{job="backup"} 1.4695189255e+09`},
+		"mesos_metronome_job_active_runs":                    {`{job="backup",status="ACTIVE"} 1`},
+		"mesos_metronome_task_info":                          {`{job="backup",run="20160726074500abc",state="TASK_RUNNING",task="backup_20160726074500abc.t1"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestAuroraCollector(t *testing.T) {
+	aurora := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vars.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"build_git_revision":"abc","jvm_uptime_secs":120,"framework_registered":1,
+			"scheduler_lifecycle_ACTIVE":1,"task_store_RUNNING":3,"task_store_SANDBOX_DELETED":1,
+			"tasks_FAILED":2,"tasks_FAILED_www-data/prod/hello":2,"tasks_SANDBOX_DELETED_www_data/devel/hello":1,
+			"tasks_lost_rack_r1":0}`))
+	}))
+	defer aurora.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewAuroraCollector(aurora.URL, Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up":                  {`{collector="aurora"} 1`},
+		"mesos_aurora_uptime_seconds":         {`{} 120`},
+		"mesos_aurora_framework_registered":   {`{} 1`},
+		"mesos_aurora_scheduler_lifecycle":    {`{state="ACTIVE"} 1`},
+		"mesos_aurora_tasks":                  {`{state="RUNNING"} 3`, `{state="SANDBOX_DELETED"} 1`},
+		"mesos_aurora_task_transitions_total": {`{state="FAILED"} 2`},
+		"mesos_aurora_job_task_transitions_total": {
+			`{environment="devel",job="hello",role="www_data",state="SANDBOX_DELETED"} 1`,
+			`{environment="prod",job="hello",role="www-data",state="FAILED"} 2`,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestSingularityCollector(t *testing.T) {
+	responses := map[string]string{
+		"/singularity/api/state": `{"activeTasks":2,"lateTasks":1,"pausedRequests":1,"underProvisionedRequests":1,"maxTaskLag":1500}`,
+		"/singularity/api/requests": `[{"request":{"id":"web"},"state":"ACTIVE","pendingDeployState":{"currentDeployState":"WAITING"}},
+			{"request":{"id":"cron"},"state":"PAUSED"}]`,
+		"/singularity/api/tasks/active": `[{"taskId":{"id":"web-d2-1420070400000-1-host1-DEFAULT","requestId":"web","deployId":"d2"}}]`,
+	}
+	singularity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer singularity.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewSingularityCollector(singularity.URL+"/singularity", Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if strings.HasSuffix(metricString(m), " 0") {
+				continue
+			}
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up":                       {`{collector="singularity"} 1`},
+		"mesos_singularity_tasks":                  {`{state="active"} 2`, `{state="late"} 1`},
+		"mesos_singularity_requests":               {`{state="paused"} 1`, `{state="underprovisioned"} 1`},
+		"mesos_singularity_max_task_lag_seconds":   {`{} 1.5`},
+		"mesos_singularity_request_state":          {`{request="cron",state="PAUSED"} 1`, `{request="web",state="ACTIVE"} 1`},
+		"mesos_singularity_request_pending_deploy": {`{request="web",state="WAITING"} 1`},
+		"mesos_singularity_task_info":              {`{deploy="d2",request="web",task="web-d2-1420070400000-1-host1-DEFAULT"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestParseMesosDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"1weeks":  7 * 24 * time.Hour,
+		"2.5hrs":  150 * time.Minute,
+		"15mins":  15 * time.Minute,
+		"100ms":   100 * time.Millisecond,
+		"10secs":  10 * time.Second,
+		"invalid": 0,
+		"10years": 0,
+	} {
+		got, err := parseMesosDuration(s)
+		if (err != nil) != (want == 0) {
+			t.Errorf("%s: unexpected error %v", s, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestSlaveMonitorCollector_ExecutorLabels(t *testing.T) {
+	responses := map[string]string{
+		"/monitor/statistics": `[{"executor_id":"web.1","framework_id":"f1","source":"web","statistics":{"cpus_limit":1,
+				"blkio_statistics":{"throttling":[
+					{"io_service_bytes":[{"op":"TOTAL","value":4096}]},
+					{"device":{"major_number":8,"minor_number":0},"io_service_bytes":[{"op":"TOTAL","value":4096},{"op":"READ","value":1024},{"op":"WRITE","value":3072}]}],
+				"cfq":[{"device":{"major_number":8,"minor_number":0},"io_wait_time":[{"op":"READ","value":5e8}]}]}}},
+			{"executor_id":"db.1","framework_id":"f1","source":"db","statistics":{"cpus_limit":2,"net_rx_bytes":1e6,"net_rx_errors":2,
+				"net_tcp_active_connections":12,"net_tcp_rtt_microsecs_p99":2500,
+				"net_traffic_control_statistics":[{"id":"bw_limit","backlog":3,"drops":7,"overlimits":9}]}},
+			{"executor_id":"pod","framework_id":"f2","source":"pod","statistics":{"cpus_limit":3,
+				"perf":{"timestamp":1500000000,"duration":10,"cycles":2e10,"instructions":3e10,"cache_misses":1000}}},
+			{"executor_id":"spark","framework_id":"f3","source":"spark","statistics":{"cpus_limit":4,"processes":3,"threads":120}},
+			{"executor_id":"gone","framework_id":"f3","source":"gone","statistics":{"cpus_limit":5}}]`,
+		"/state": `{"frameworks":[
+			{"id":"f1","executors":[
+				{"id":"web.1","container":"c1","tasks":[{"id":"web.1","container":{"type":"DOCKER"}}]},
+				{"id":"db.1","container":"c2","tasks":[],"queued_tasks":[{"id":"db.1","container":{"type":"MESOS"}}]}]},
+			{"id":"f2","executors":[{"id":"pod","container":"c3","type":"DEFAULT","tasks":[{"id":"a"},{"id":"b"}]}]},
+			{"id":"f3","executors":[{"id":"spark","container":"c4","type":"CUSTOM","tasks":[{"id":"1"}]}]}]}`,
+	}
+	slave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer slave.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewSlaveMonitorCollector(slave.URL, Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, perf, blkio, network []string
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			switch mf.GetName() {
+			case "mesos_executor_cpus_limit":
+				got = append(got, metricString(m))
+			case "mesos_executor_perf_cycles", "mesos_executor_perf_cache_misses", "mesos_executor_processes", "mesos_executor_threads":
+				perf = append(perf, mf.GetName()+metricString(m))
+			case "mesos_executor_blkio_service_bytes_total", "mesos_executor_blkio_wait_seconds_total":
+				blkio = append(blkio, mf.GetName()+metricString(m))
+			case "mesos_executor_network_receive_errors_total", "mesos_executor_network_tcp_connections", "mesos_executor_network_tcp_rtt_seconds", "mesos_executor_network_traffic_control_dropped_total":
+				if !strings.HasSuffix(metricString(m), " 0") {
+					network = append(network, mf.GetName()+metricString(m))
+				}
+			}
+		}
+	}
+	db := `{container_id="c2",containerizer="mesos",executor_type="command",framework_id="f1",id="db.1",`
+	if want := []string{
+		"mesos_executor_network_receive_errors_total" + db + `source="db"} 2`,
+		"mesos_executor_network_tcp_connections" + db + `source="db",state="active"} 12`,
+		"mesos_executor_network_tcp_rtt_seconds" + db + `percentile="99",source="db"} 0.0025`,
+		"mesos_executor_network_traffic_control_dropped_total" + db + `qdisc="bw_limit",source="db"} 7`,
+	}; !reflect.DeepEqual(network, want) {
+		t.Errorf("network got: %v, want: %v", network, want)
+	}
+	web := `container_id="c1",containerizer="docker",device="8:0",executor_type="command",framework_id="f1",id="web.1",`
+	if want := []string{
+		"mesos_executor_blkio_service_bytes_total{" + web + `op="read",source="web"} 1024`,
+		"mesos_executor_blkio_service_bytes_total{" + web + `op="write",source="web"} 3072`,
+		"mesos_executor_blkio_wait_seconds_total{" + web + `op="read",source="web"} 0.5`,
+	}; !reflect.DeepEqual(blkio, want) {
+		t.Errorf("blkio got: %v, want: %v", blkio, want)
+	}
+	pod := `{container_id="c3",containerizer="mesos",executor_type="default",framework_id="f2",id="pod",source="pod"}`
+	spark := `{container_id="c4",containerizer="mesos",executor_type="custom",framework_id="f3",id="spark",source="spark"}`
+	if want := []string{
+		"mesos_executor_perf_cache_misses" + pod + " 1000", "mesos_executor_perf_cycles" + pod + " 2e+10", "mesos_executor_processes" + spark + " 3", "mesos_executor_threads" + spark + " 120",
+	}; !reflect.DeepEqual(perf, want) {
+		t.Errorf("perf and processes got: %v, want: %v", perf, want)
+	}
+	want := []string{
+		`{container_id="",containerizer="",executor_type="",framework_id="f3",id="gone",source="gone"} 5`,
+		`{container_id="c1",containerizer="docker",executor_type="command",framework_id="f1",id="web.1",source="web"} 1`,
+		`{container_id="c2",containerizer="mesos",executor_type="command",framework_id="f1",id="db.1",source="db"} 2`,
+		`{container_id="c3",containerizer="mesos",executor_type="default",framework_id="f2",id="pod",source="pod"} 3`,
+		`{container_id="c4",containerizer="mesos",executor_type="custom",framework_id="f3",id="spark",source="spark"} 4`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestSlaveMonitorCollector_StateFailure(t *testing.T) {
+	// Without the state, executors are exported without their kind, and the
+	// statistics are fetched once per scrape for the top collector as well.
+	fetches := map[string]int{}
+	var mu sync.Mutex
+	slave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path != "/monitor/statistics" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"executor_id":"web.1","framework_id":"f1","source":"web","statistics":{"cpus_limit":1}}]`))
+	}))
+	defer slave.Close()
+
+	internal := NewInternal()
+	opts := Options{Timeout: time.Second, Internal: internal}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewSlaveMonitorCollector(slave.URL, opts), NewSlaveTopCollector(slave.URL, opts, 1))
+	var mfs []*dto.MetricFamily
+	var err error
+	internal.Scrape(context.Background(), func() { mfs, err = reg.Gather() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			switch mf.GetName() {
+			case "mesos_collector_up", "mesos_executor_cpus_limit":
+				got = append(got, mf.GetName()+metricString(m))
+			}
+		}
+	}
+	want := []string{
+		`mesos_collector_up{collector="slave_monitor"} 1`,
+		`mesos_collector_up{collector="slave_top"} 1`,
+		`mesos_executor_cpus_limit{container_id="",containerizer="",executor_type="",framework_id="f1",id="web.1",source="web"} 1`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if want := map[string]int{"/monitor/statistics": 1, "/state": 1}; !reflect.DeepEqual(fetches, want) {
+		t.Errorf("got fetches %v, want %v", fetches, want)
+	}
+}
+
+func TestRegisterSlave_SharedState(t *testing.T) {
+	fetches := map[string]int{}
+	var mu sync.Mutex
+	slave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/monitor/statistics":
+			w.Write([]byte(`[]`))
+		case "/state":
+			w.Write([]byte(`{"flags":{"gc_delay":"1weeks"},"frameworks":[]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer slave.Close()
+
+	internal := NewInternal()
+	reg := prometheus.NewRegistry()
+	if err := RegisterSlave(reg, slave.URL, Options{Timeout: time.Second, Internal: internal}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		internal.Scrape(context.Background(), func() { reg.Gather() })
+	}
+	if want := map[string]int{"/metrics/snapshot": 2, "/monitor/statistics": 2, "/state": 2}; !reflect.DeepEqual(fetches, want) {
+		t.Errorf("got fetches %v, want %v", fetches, want)
+	}
+}
+
+func TestSlaveTopCollector(t *testing.T) {
+	stats := []string{
+		`[{"executor_id":"a","framework_id":"f","statistics":{"timestamp":100,"cpus_user_time_secs":10,"mem_rss_bytes":100}},
+		{"executor_id":"b","framework_id":"f","statistics":{"timestamp":100,"cpus_user_time_secs":50,"mem_rss_bytes":300}},
+		{"executor_id":"c","framework_id":"f","statistics":{"timestamp":100,"cpus_user_time_secs":0,"mem_rss_bytes":200}}]`,
+		`[{"executor_id":"a","framework_id":"f","statistics":{"timestamp":110,"cpus_user_time_secs":30,"cpus_system_time_secs":10,"mem_rss_bytes":100}},
+		{"executor_id":"b","framework_id":"f","statistics":{"timestamp":110,"cpus_user_time_secs":55,"mem_rss_bytes":300}},
+		{"executor_id":"c","framework_id":"f","statistics":{"timestamp":110,"cpus_user_time_secs":10,"mem_rss_bytes":200}},
+		{"executor_id":"d","framework_id":"f","statistics":{"timestamp":110,"cpus_user_time_secs":900,"mem_rss_bytes":50}}]`,
+	}
+	i := 0
+	slave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stats[i]))
+	}))
+	defer slave.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewSlaveTopCollector(slave.URL, Options{Timeout: time.Second}, 2))
+	gather := func() map[string][]string {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string][]string{}
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+			}
+		}
+		return got
+	}
+
+	want := map[string][]string{
+		"mesos_collector_up": {`{collector="slave_top"} 1`},
+		"mesos_slave_top_executor_mem_rss_bytes": {
+			`{framework_id="f",id="b",source=""} 300`, `{framework_id="f",id="c",source=""} 200`,
+		},
+	}
+	if got := gather(); !reflect.DeepEqual(got, want) {
+		t.Errorf("first collection got: %v, want: %v", got, want)
+	}
+
+	i++
+	want["mesos_slave_top_executor_cpus_used"] = []string{
+		`{framework_id="f",id="a",source=""} 3`, `{framework_id="f",id="c",source=""} 1`,
+	}
+	if got := gather(); !reflect.DeepEqual(got, want) {
+		t.Errorf("second collection got: %v, want: %v", got, want)
+	}
+}
+
+func TestSlaveGCCollector(t *testing.T) {
+	finished := float64(time.Now().Add(-time.Hour).Unix())
+	slave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/state" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"flags":{"gc_delay":"3hrs","gc_disk_headroom":"0.1"},
+			"frameworks":[{"completed_executors":[{"resources":{"disk":64},"completed_tasks":[{"statuses":[{"timestamp":%f}]}]}]}],
+			"completed_frameworks":[{"completed_executors":[{"resources":{"disk":32},"completed_tasks":[]}]}]}`, finished)
+	}))
+	defer slave.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewSlaveGCCollector(slave.URL, Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, mf := range mfs {
+		got[mf.GetName()] = mf.Metric[0].GetGauge().GetValue()
+	}
+	for name, want := range map[string]float64{
+		"mesos_slave_gc_delay_seconds":                     3 * 3600,
+		"mesos_slave_gc_disk_headroom_ratio":               0.1,
+		"mesos_slave_completed_sandboxes":                  2,
+		"mesos_slave_completed_sandboxes_disk_limit_bytes": 96 << 20,
+	} {
+		if got[name] != want {
+			t.Errorf("%s: got %v, want %v", name, got[name], want)
+		}
+	}
+	if next := got["mesos_slave_gc_next_removal_seconds"]; math.Abs(next-2*3600) > 10 {
+		t.Errorf("mesos_slave_gc_next_removal_seconds: got %v, want about 7200", next)
+	}
+}
+
+func TestMasterCollector_Snapshot(t *testing.T) {
+	for i, tt := range []struct {
+		// fixtures are served as the master's metrics snapshot, one per
+		// scrape; the metrics are compared after the last one.
+		fixtures []string
+		want     map[string][]string
+	}{
+		{
+			fixtures: []string{"framework_offers"},
+			want: map[string][]string{
+				"mesos_master_framework_offers_total": {
+					`{event="accepted",framework="f1"} 2`,
+					`{event="declined",framework="f1"} 7`,
+					`{event="rescinded",framework="f1"} 1`,
+					`{event="sent",framework="f1"} 10`,
+					`{event="sent",framework="f2"} 3`,
+				},
+			},
+		},
+		{
+			// The series of a framework which is gone are dropped.
+			fixtures: []string{"framework_offers", "framework_offers_gone"},
+			want: map[string][]string{
+				"mesos_master_framework_offers_total": {`{event="sent",framework="f2"} 4`},
+			},
+		},
+		{
+			fixtures: []string{"suppressed_roles"},
+			want: map[string][]string{
+				"mesos_master_framework_role_suppressed": {
+					`{framework="f1",role="eng/web"} 0`, `{framework="f1",role="slave_public"} 1`, `{framework="f2",role="eng/web"} 1`,
+				},
+				"mesos_master_role_suppressed_frameworks": {`{role="eng/web"} 1`, `{role="slave_public"} 1`},
+			},
+		},
+		{
+			fixtures: []string{"reconciliations"},
+			want: map[string][]string{
+				"mesos_master_reconcile_messages_total":        {`{} 12`},
+				"mesos_master_framework_reconcile_calls_total": {`{framework="f1"} 4`},
+			},
+		},
+		{
+			// Status updates are counted once, not also as messages.
+			fixtures: []string{"status_update_messages"},
+			want: map[string][]string{
+				"mesos_master_status_update_messages_total": {
+					`{outcome="invalid",type="status_update"} 3`,
+					`{outcome="invalid",type="status_update_acknowledgement"} 1`,
+					`{outcome="valid",type="status_update"} 100`,
+					`{outcome="valid",type="status_update_acknowledgement"} 98`,
+				},
+				"mesos_master_messages_outcomes_total": {
+					`{destination="executor",outcome="invalid",source="framework"} 0`,
+					`{destination="executor",outcome="valid",source="framework"} 7`,
+					`{destination="framework",outcome="invalid",source="executor"} 0`,
+					`{destination="framework",outcome="valid",source="executor"} 5`,
+				},
+			},
+		},
+		{
+			fixtures: []string{"slave_lifecycle"},
+			want: map[string][]string{
+				"mesos_master_slave_removals_total": {`{reason="registered"} 1`, `{reason="unhealthy"} 3`, `{reason="unregistered"} 0`},
+				"mesos_master_slave_unreachable_events_total": {
+					`{event="canceled"} 2`, `{event="completed"} 3`, `{event="scheduled"} 5`,
+				},
+			},
+		},
+		{
+			fixtures: []string{"principal_messages"},
+			want: map[string][]string{
+				"mesos_master_principal_messages_total": {
+					`{principal="marathon",state="processed"} 100`,
+					`{principal="marathon",state="received"} 120`,
+					`{principal="spark",state="processed"} 5`,
+					`{principal="spark",state="received"} 5`,
+				},
+			},
+		},
+		{
+			fixtures: []string{"event_queues"},
+			want: map[string][]string{
+				"mesos_master_event_queue_length":           {`{type="dispatches"} 40`, `{type="http_request"} 3`, `{type="message"} 12`},
+				"mesos_master_allocator_event_queue_length": {`{} 7`},
+			},
+		},
+	} {
+		var snapshot []byte
+		master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(snapshot)
+		}))
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(NewMasterCollector(master.URL, Options{Timeout: time.Second}))
+		var mfs []*dto.MetricFamily
+		var err error
+		for _, fixture := range tt.fixtures {
+			if snapshot, err = os.ReadFile(filepath.Join("testdata", "metrics", fixture+".json")); err != nil {
+				t.Fatal(err)
+			}
+			if mfs, err = reg.Gather(); err != nil {
+				t.Fatalf("test #%d: %s", i, err)
+			}
+		}
+		master.Close()
+
+		got := map[string][]string{}
+		for _, mf := range mfs {
+			if _, ok := tt.want[mf.GetName()]; !ok {
+				continue
+			}
+			for _, m := range mf.Metric {
+				got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d (%s): got: %v, want: %v", i, strings.Join(tt.fixtures, ", "), got, tt.want)
+		}
+	}
+}
+
+func TestMaintenanceCollector(t *testing.T) {
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/maintenance/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"draining_machines":[{"id":{"hostname":"h1","ip":"10.0.0.1"},"statuses":[]}],
+			"down_machines":[{"hostname":"h2","ip":"10.0.0.2"},{"hostname":"h3"}]}`))
+	}))
+	defer master.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewMaintenanceCollector(master.URL, Options{Timeout: time.Second}))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up": {`{collector="maintenance"} 1`},
+		"mesos_master_machine_maintenance_mode": {
+			`{hostname="h1",ip="10.0.0.1",mode="DRAINING"} 1`,
+			`{hostname="h2",ip="10.0.0.2",mode="DOWN"} 1`,
+			`{hostname="h3",ip="",mode="DOWN"} 1`,
+		},
+		"mesos_master_machines_maintenance": {`{mode="DOWN"} 2`, `{mode="DRAINING"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestOverlayCollectors(t *testing.T) {
+	agent := `{"ip":"10.0.0.1","overlays":[{"info":{"name":"dcos","subnet":"9.0.0.0/8","prefix":24},"subnet":"9.0.1.0/24",
+		"backend":{"vxlan":{"vni":1024,"vtep_ip":"44.128.0.1/20","vtep_name":"vtep1024"}},"state":{"status":"STATUS_OK"}}]}`
+	responses := map[string]string{
+		"/overlay-master/state":  `{"network":{"overlays":[{"name":"dcos","subnet":"9.0.0.0/8","prefix":24}]},"agents":[` + agent + `]}`,
+		"/overlay-agent/overlay": agent,
+	}
+	mesos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer mesos.Close()
+
+	reg := prometheus.NewRegistry()
+	opts := Options{Timeout: time.Second}
+	reg.MustRegister(NewOverlayMasterCollector(mesos.URL, opts), NewOverlayAgentCollector(mesos.URL, opts))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			got[mf.GetName()] = append(got[mf.GetName()], metricString(m))
+		}
+	}
+	want := map[string][]string{
+		"mesos_collector_up":              {`{collector="overlay_agent"} 1`, `{collector="overlay_master"} 1`},
+		"mesos_overlay_subnets":           {`{overlay="dcos"} 65536`},
+		"mesos_overlay_subnets_allocated": {`{overlay="dcos"} 1`},
+		"mesos_overlay_agent_status":      {`{agent_ip="10.0.0.1",overlay="dcos",status="STATUS_OK",subnet="9.0.1.0/24"} 1`},
+		"mesos_overlay_status":            {`{overlay="dcos",status="STATUS_OK",subnet="9.0.1.0/24"} 1`},
+		"mesos_overlay_vxlan_info":        {`{overlay="dcos",vni="1024",vtep_ip="44.128.0.1/20",vtep_name="vtep1024"} 1`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,450 @@
+// Package collector implements Prometheus collectors exporting the metrics
+// of Apache Mesos masters and slaves, for embedding into any registry.
+//
+// The collectors count their errors in the Internal of their Options, whose
+// Collectors should be registered next to them.
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type metricMap map[string]float64
+
+// Internal holds the counters of errors and collections of the collectors
+// created with it in their Options, and the context of their running scrape.
+// The nil *Internal stands for the default one of the package, which
+// collectors without Options.Internal share.
+type Internal struct {
+	errors         prometheus.Counter
+	responseErrors *prometheus.CounterVec
+	collects       *prometheus.CounterVec
+	scrape         currentContext
+}
+
+// NewInternal returns a new set of internal counters.
+func NewInternal() *Internal {
+	return &Internal{
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "mesos",
+			Subsystem: "collector",
+			Name:      "errors_total",
+			Help:      "Total number of internal mesos-collector errors.",
+		}),
+		responseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "mesos",
+			Subsystem: "collector",
+			Name:      "response_errors_total",
+			Help:      "Total number of unsuccessful responses from Mesos by reason.",
+		}, []string{"reason"}),
+		collects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "mesos",
+			Subsystem: "exporter",
+			Name:      "collect_total",
+			Help:      "Total number of collections attempted by collector, whether they succeeded or not.",
+		}, []string{"collector"}),
+		scrape: currentContext{ctx: context.Background()},
+	}
+}
+
+var defaultInternal = NewInternal()
+
+func (i *Internal) orDefault() *Internal {
+	if i == nil {
+		return defaultInternal
+	}
+	return i
+}
+
+// Collectors returns the counters of errors of the collectors,
+// mesos_collector_errors_total and mesos_collector_response_errors_total,
+// and of their collections, mesos_exporter_collect_total.
+func (i *Internal) Collectors() []prometheus.Collector {
+	i = i.orDefault()
+	return []prometheus.Collector{i.errors, i.responseErrors, i.collects}
+}
+
+// InternalCollectors returns the Collectors of the default Internal.
+func InternalCollectors() []prometheus.Collector {
+	return (*Internal)(nil).Collectors()
+}
+
+var (
+	notFoundInMap    = errors.New("Couldn't find key in map")
+	deadlineExceeded = errors.New("scrape deadline exceeded")
+)
+
+// Options configure how collectors poll Mesos.
+type Options struct {
+	// Client sends the requests to Mesos, e.g. to authenticate them. If
+	// nil, a client with Timeout which doesn't follow redirects is used.
+	Client *http.Client
+	// Timeout bounds requests of the default client.
+	Timeout time.Duration
+	// Internal counts the errors and collections of the collectors. If
+	// nil, the default one is used.
+	Internal *Internal
+}
+
+func (o Options) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return NewHTTPClient(o.Timeout)
+}
+
+// NewHTTPClient returns the default client for polling Mesos. Redirects aren't
+// followed, as the Mesos endpoints polled don't redirect unless something is
+// misconfigured, e.g. a non-leading master is polled for leader-only data.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// statusError is returned for responses with a status other than 200 OK.
+type statusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response from %s: %s", e.url, e.status)
+}
+
+// reason classifies the error for mesos_collector_response_errors_total.
+func (e *statusError) reason() string {
+	switch {
+	case e.code == http.StatusUnauthorized:
+		return "unauthorized"
+	case e.code == http.StatusForbidden:
+		return "forbidden"
+	case e.code >= 300 && e.code < 400:
+		return "redirect"
+	case e.code >= 400 && e.code < 500:
+		return "client_error"
+	case e.code >= 500:
+		return "server_error"
+	}
+	return "unexpected"
+}
+
+// getJSON fetches url and decodes the JSON response body into v. The client
+// timeout is shortened if needed to finish before the deadline of ctx.
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	return get(ctx, client, url, func(ctx context.Context, r io.Reader) error {
+		return decodeJSON(ctx, url, r, v)
+	})
+}
+
+// sharedResponses are the response bodies fetched by getSharedJSON during a
+// scrape, by URL.
+type sharedResponses struct {
+	mu        sync.Mutex
+	responses map[string]*sharedResponse
+}
+
+type sharedResponse struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+type sharedResponsesKey struct{}
+
+// getSharedJSON is getJSON for endpoints polled by several collectors of a
+// node. Within a scrape, url is only fetched by the first collector asking
+// for it, and the others decode the same response.
+func getSharedJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	s, ok := ctx.Value(sharedResponsesKey{}).(*sharedResponses)
+	if !ok {
+		return getJSON(ctx, client, url, v)
+	}
+	s.mu.Lock()
+	res, ok := s.responses[url]
+	if !ok {
+		res = &sharedResponse{}
+		s.responses[url] = res
+	}
+	s.mu.Unlock()
+
+	res.once.Do(func() {
+		res.err = get(ctx, client, url, func(_ context.Context, r io.Reader) (err error) {
+			res.data, err = io.ReadAll(r)
+			return err
+		})
+	})
+	if res.err != nil {
+		return res.err
+	}
+	return decodeJSON(ctx, url, bytes.NewReader(res.data), v)
+}
+
+// get fetches url and passes the response body to read.
+func get(ctx context.Context, client *http.Client, url string, read func(context.Context, io.Reader) error) (err error) {
+	ctx, span := tracer.Start(ctx, "fetch", trace.WithAttributes(attribute.String("url", url)))
+	defer func() { endSpan(span, err) }()
+
+	if t, ok := ctx.Deadline(); ok {
+		left := t.Sub(time.Now())
+		if left <= 0 {
+			return deadlineExceeded
+		}
+		if client.Timeout == 0 || left < client.Timeout {
+			c := *client
+			c.Timeout = left
+			client = &c
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		err := &statusError{url: url, code: res.StatusCode, status: res.Status}
+		internalFrom(ctx).responseErrors.WithLabelValues(err.reason()).Inc()
+		return err
+	}
+	return read(ctx, res.Body)
+}
+
+// decodeJSON decodes the response body r fetched from url into v.
+func decodeJSON(ctx context.Context, url string, r io.Reader, v interface{}) (err error) {
+	_, span := tracer.Start(ctx, "decode")
+	defer func() { endSpan(span, err) }()
+
+	decode := func(r io.Reader) error {
+		dec := json.NewDecoder(r)
+		if s, ok := v.(streamDecoder); ok {
+			return s.decodeStream(dec)
+		}
+		return dec.Decode(v)
+	}
+	if schemaDiagnostics == nil {
+		if err := decode(r); err != nil {
+			return fmt.Errorf("decoding response body from %s: %s", url, err)
+		}
+		return nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("decoding response body from %s: %s", url, err)
+	}
+	schemaDiagnostics.check(url, data, v)
+	return nil
+}
+
+// Megabytes is the number of bytes in the MB Mesos reports memory and disk
+// resources in.
+const Megabytes = 1 << 20
+
+func gauge(subsystem, name, help string, labels ...string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mesos",
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, labels)
+}
+
+func counter(subsystem, name, help string, labels ...string) *settableCounterVec {
+	return &settableCounterVec{
+		desc:   counterDesc(prometheus.BuildFQName("mesos", subsystem, name), help, labels, nil),
+		values: map[string]labeledValue{},
+	}
+}
+
+// descTypes maps the descs of gaugeDesc, counterDesc and newDesc to the
+// type of their samples, for constMetric and Families.
+var descTypes sync.Map
+
+func newDesc(typ prometheus.ValueType, name, help string, labels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	d := prometheus.NewDesc(name, help, labels, constLabels)
+	descTypes.Store(d, typ)
+	return d
+}
+
+func gaugeDesc(name, help string, labels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	return newDesc(prometheus.GaugeValue, name, help, labels, constLabels)
+}
+
+func counterDesc(name, help string, labels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	return newDesc(prometheus.CounterValue, name, help, labels, constLabels)
+}
+
+// constMetric returns a sample of desc of the type desc was created with,
+// or an untyped one if it wasn't created by newDesc.
+func constMetric(desc *prometheus.Desc, v float64, labels ...string) prometheus.Metric {
+	typ := prometheus.UntypedValue
+	if t, ok := descTypes.Load(desc); ok {
+		typ = t.(prometheus.ValueType)
+	}
+	return prometheus.MustNewConstMetric(desc, typ, v, labels...)
+}
+
+// settableCounterVec is a counter vector whose values are taken over from
+// Mesos instead of being counted by the exporter.
+type settableCounterVec struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	values map[string]labeledValue
+}
+
+type labeledValue struct {
+	labels []string
+	value  float64
+}
+
+// Set sets the counter with the given label values to v.
+func (c *settableCounterVec) Set(v float64, labels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labels, "\xff")] = labeledValue{labels, v}
+}
+
+// Reset deletes all counters, so that only the ones set again are exported.
+func (c *settableCounterVec) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values = map[string]labeledValue{}
+}
+
+func (c *settableCounterVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *settableCounterVec) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range c.values {
+		ch <- constMetric(c.desc, v.value, v.labels...)
+	}
+}
+
+// collectorUp exports whether the latest collection of a collector succeeded.
+// Every collector has a series of its own, distinguished by the collector
+// label.
+type collectorUp struct {
+	name string
+	desc *prometheus.Desc
+	*Internal
+}
+
+// collectorNames maps the descs of collectorUps to their names, for Name.
+var collectorNames sync.Map
+
+func newCollectorUp(name string, opts Options) collectorUp {
+	u := collectorUp{name, gaugeDesc(
+		"mesos_collector_up",
+		"Whether the latest collection of the collector succeeded.",
+		nil, prometheus.Labels{"collector": name},
+	), opts.Internal.orDefault()}
+	collectorNames.Store(u.desc, name)
+	return u
+}
+
+// Name returns the name of c as in the collector label of
+// mesos_collector_up, or "" if c doesn't export one.
+func Name(c prometheus.Collector) string {
+	var name string
+	for _, d := range describe(c) {
+		if n, ok := collectorNames.Load(d); ok {
+			name = n.(string)
+		}
+	}
+	return name
+}
+
+func (u collectorUp) collect(ch chan<- prometheus.Metric, ok bool) {
+	v := 0.0
+	if ok {
+		v = 1
+	}
+	ch <- constMetric(u.desc, v)
+}
+
+type metricCollector struct {
+	*http.Client
+	url     string
+	up      collectorUp
+	metrics map[prometheus.Collector]func(metricMap, prometheus.Collector) error
+}
+
+func newMetricCollector(name, url string, opts Options, metrics map[prometheus.Collector]func(metricMap, prometheus.Collector) error) *metricCollector {
+	return &metricCollector{
+		url:     url,
+		Client:  opts.client(),
+		up:      newCollectorUp(name, opts),
+		metrics: metrics,
+	}
+}
+
+func (c *metricCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/metrics/snapshot"
+	var m metricMap
+	if err := getJSON(ctx, c.Client, u, &m); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+
+	for cm, f := range c.metrics {
+		if err := f(m, cm); err != nil {
+			if err == notFoundInMap {
+				ch := make(chan *prometheus.Desc, 1)
+				cm.Describe(ch)
+				log.Printf("Couldn't find fields required to update %s\n", <-ch)
+			} else {
+				log.Printf("Error extracting metric: %s", err)
+			}
+			c.up.errors.Inc()
+			continue
+		}
+		cm.Collect(ch)
+	}
+}
+
+func (c *metricCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	for m, _ := range c.metrics {
+		m.Describe(ch)
+	}
+}
@@ -0,0 +1,50 @@
+package collector
+
+import "encoding/json"
+
+// UnmarshalJSON decodes both the master and the agent flavour of /state
+// across Mesos versions into the same model.
+func (st *State) UnmarshalJSON(data []byte) error {
+	type plain State
+	if err := json.Unmarshal(data, (*plain)(st)); err != nil {
+		return err
+	}
+	st.normalize()
+	return nil
+}
+
+// normalize moves the slaves and tasks of a decoded state to where the model
+// expects them. Every Mesos release from 0.28 through 1.11 reports the
+// slaves of /state under "slaves", and mem and disk in MB, so the version
+// doesn't matter; "agents" is only used by proxies rewriting the responses.
+func (st *State) normalize() {
+	if len(st.Slaves) == 0 {
+		st.Slaves = st.Agents
+	}
+	st.Agents = nil
+
+	for i := range st.Frameworks {
+		st.Frameworks[i].flattenExecutors()
+	}
+}
+
+// flattenExecutors moves tasks nested below executors, as reported by the
+// agent /state endpoint, into the framework task lists used by the master
+// /state endpoint.
+func (f *Framework) flattenExecutors() {
+	if len(f.Tasks) == 0 {
+		for _, e := range f.Executors {
+			f.Tasks = append(f.Tasks, e.Tasks...)
+		}
+	}
+	if len(f.Completed) == 0 {
+		for _, e := range f.Executors {
+			f.Completed = append(f.Completed, e.Completed...)
+		}
+		for _, e := range f.CompletedExecutors {
+			f.Completed = append(f.Completed, e.Tasks...)
+			f.Completed = append(f.Completed, e.Completed...)
+		}
+	}
+	f.Executors, f.CompletedExecutors = nil, nil
+}
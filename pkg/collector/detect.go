@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Roles of Mesos nodes.
+const (
+	RoleMaster = "master"
+	RoleSlave  = "slave"
+)
+
+// DetectRole probes the Mesos node running on url and reports whether it is a
+// master or a slave. Both serve /version, so the role is told apart by the
+// keys present in /metrics/snapshot.
+func DetectRole(url string, opts Options) (string, error) {
+	client := opts.client()
+	base := strings.TrimSuffix(url, "/")
+
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := getJSON(context.Background(), client, base+"/version", &v); err != nil {
+		return "", err
+	}
+
+	var m metricMap
+	if err := getJSON(context.Background(), client, base+"/metrics/snapshot", &m); err != nil {
+		return "", err
+	}
+
+	var role string
+	switch {
+	case hasKey(m, "master/elected"):
+		role = RoleMaster
+	case hasKey(m, "slave/registered"):
+		role = RoleSlave
+	default:
+		return "", fmt.Errorf("%s is neither a Mesos master nor slave", url)
+	}
+	log.Printf("Detected Mesos %s %s on %s", v.Version, role, url)
+	return role, nil
+}
+
+func hasKey(m metricMap, key string) bool {
+	_, ok := m[key]
+	return ok
+}
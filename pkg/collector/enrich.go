@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TaskIdentity is what a task is part of, in terms of the framework which
+// launched it. AppID is the long-lived application, e.g. a Marathon app, and
+// JobID the run of a batch job, e.g. a Jenkins build.
+type TaskIdentity struct {
+	AppID string
+	JobID string
+}
+
+// TaskEnricher recognizes the tasks of a framework and derives their
+// identity. It reports false for tasks it doesn't recognize, which are left
+// to the next enricher.
+type TaskEnricher func(f *Framework, t *Task) (TaskIdentity, bool)
+
+// WellKnownEnrichers are the enrichers of the frameworks recognized by the
+// exporter, see MarathonEnricher, SparkEnricher and JenkinsEnricher.
+var WellKnownEnrichers = []TaskEnricher{MarathonEnricher, SparkEnricher, JenkinsEnricher}
+
+// MarathonEnricher derives the app ID of Marathon tasks from their task ID,
+// which is the app ID with slashes replaced by underscores, followed by a
+// dot and the instance ID.
+func MarathonEnricher(f *Framework, t *Task) (TaskIdentity, bool) {
+	if !strings.HasPrefix(f.Name, "marathon") {
+		return TaskIdentity{}, false
+	}
+	// Marathon 1.5 and later append the instance to the app,
+	// app.instance-<uuid>._app.<n>, while app IDs may contain dots.
+	i := strings.Index(t.ID, ".instance-")
+	if i < 0 {
+		i = strings.LastIndex(t.ID, ".")
+	}
+	if i <= 0 {
+		return TaskIdentity{}, false
+	}
+	return TaskIdentity{AppID: "/" + strings.Replace(t.ID[:i], "_", "/", -1)}, true
+}
+
+var sparkDriverID = regexp.MustCompile(`^driver-\d{14}-\d{4}$`)
+
+// SparkEnricher recognizes the drivers launched by the Spark dispatcher,
+// whose task ID is the submission ID exported as job ID, and the executors
+// of Spark applications, whose framework ID is the application ID. Executors
+// are named after the application and their task ID.
+func SparkEnricher(f *Framework, t *Task) (TaskIdentity, bool) {
+	switch {
+	case sparkDriverID.MatchString(t.ID):
+		return TaskIdentity{JobID: t.ID}, true
+	case f.Name != "" && t.Name == f.Name+" "+t.ID:
+		return TaskIdentity{AppID: f.ID}, true
+	}
+	return TaskIdentity{}, false
+}
+
+var jenkinsBuildTag = regexp.MustCompile(`^jenkins-(.+)-\d+$`)
+
+// JenkinsEnricher recognizes tasks running Jenkins builds by their build tag,
+// jenkins-<job>-<build number>, in the BUILD_TAG label or the task name. The
+// job is exported as app ID and the build tag as job ID.
+func JenkinsEnricher(f *Framework, t *Task) (TaskIdentity, bool) {
+	tag := t.Name
+	for _, l := range t.Labels {
+		if l.Key == "BUILD_TAG" {
+			tag = l.Value
+		}
+	}
+	m := jenkinsBuildTag.FindStringSubmatch(tag)
+	if m == nil {
+		return TaskIdentity{}, false
+	}
+	return TaskIdentity{AppID: m[1], JobID: tag}, true
+}
+
+// taskLabeler computes the labels of per task metrics, including those of
+// the identity of tasks if there are enrichers. The state label always comes
+// last.
+type taskLabeler []TaskEnricher
+
+func (l taskLabeler) names() []string {
+	if len(l) == 0 {
+		return taskLabels
+	}
+	n := len(taskLabels)
+	return append(append(taskLabels[:n-1:n-1], "app_id", "job_id"), taskLabels[n-1])
+}
+
+// runningNames are the labels of per task metrics which stay the same over
+// the lifetime of a task.
+func (l taskLabeler) runningNames() []string {
+	names := l.names()
+	return names[:len(names)-1]
+}
+
+func (l taskLabeler) values(f *Framework, t *Task) []string {
+	v := []string{t.SlaveID, t.ID, t.ExecutorID, t.Name, t.FrameworkID}
+	if len(l) > 0 {
+		id := l.identity(f, t)
+		v = append(v, id.AppID, id.JobID)
+	}
+	return append(v, t.State)
+}
+
+func (l taskLabeler) identity(f *Framework, t *Task) TaskIdentity {
+	for _, e := range l {
+		if id, ok := e(f, t); ok {
+			return id
+		}
+	}
+	return TaskIdentity{}
+}
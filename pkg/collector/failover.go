@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// leaderChanges counts master failovers by comparing the leader of
+// consecutive scrapes. A master reelected after a restart keeps its PID, so
+// a new election time of the leader counts as well. The leader of the first
+// scrape isn't counted, as the exporter doesn't know what came before.
+type leaderChanges struct {
+	failovers *prometheus.Desc
+	changed   *prometheus.Desc
+
+	mu          sync.Mutex
+	seeded      bool
+	leader      string
+	electedTime float64
+	count       float64
+	changedTime float64
+}
+
+func newLeaderChanges() *leaderChanges {
+	return &leaderChanges{
+		failovers: counterDesc(
+			"mesos_master_failovers_total",
+			"Total number of leader changes observed by the exporter",
+			nil, nil,
+		),
+		changed: gaugeDesc(
+			"mesos_master_leader_change_timestamp_seconds",
+			"Unix timestamp of the latest leader change, observed or as reported by the leader",
+			nil, nil,
+		),
+	}
+}
+
+func (l *leaderChanges) observe(st *State) {
+	// Followers may not know the leader yet during an election.
+	if st.Leader == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reelected := st.ElectedTime != 0 && l.electedTime != 0 && st.ElectedTime != l.electedTime
+	changed := st.Leader != l.leader || reelected
+	if changed {
+		l.electedTime = 0
+		if l.seeded {
+			l.count++
+			l.changedTime = float64(time.Now().UnixNano()) / 1e9
+		}
+	}
+	// Only the leader reports when it was elected, which is more precise
+	// than the scrape which saw the change.
+	if st.ElectedTime != 0 && l.electedTime == 0 {
+		l.electedTime = st.ElectedTime
+		l.changedTime = st.ElectedTime
+	}
+	l.leader = st.Leader
+	l.seeded = true
+}
+
+func (l *leaderChanges) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.failovers
+	ch <- l.changed
+}
+
+func (l *leaderChanges) Collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.seeded {
+		return
+	}
+	ch <- constMetric(l.failovers, l.count)
+	if l.changedTime != 0 {
+		ch <- constMetric(l.changed, l.changedTime)
+	}
+}
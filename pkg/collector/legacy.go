@@ -0,0 +1,63 @@
+package collector
+
+// LegacyName is how a metric family was exported before it was renamed to
+// follow the Prometheus naming conventions.
+type LegacyName struct {
+	Name string
+	// Scale converts values to the legacy unit, e.g. bytes to MB.
+	Scale float64
+	// Labels maps renamed labels to their legacy names, and Values the
+	// values of these labels to their legacy values.
+	Labels map[string]string
+	Values map[string]string
+}
+
+// LegacyNames are the legacy names of renamed metric families, keyed by
+// their current name.
+var LegacyNames = map[string]LegacyName{
+	"mesos_master_mem_bytes":                                  {Name: "mesos_master_mem", Scale: 1.0 / Megabytes},
+	"mesos_master_mem_revocable_bytes":                        {Name: "mesos_master_mem_revocable", Scale: 1.0 / Megabytes},
+	"mesos_master_disk_bytes":                                 {Name: "mesos_master_disk", Scale: 1.0 / Megabytes},
+	"mesos_master_disk_revocable_bytes":                       {Name: "mesos_master_disk_revocable", Scale: 1.0 / Megabytes},
+	"mesos_slave_allocation_cpus":                             {Name: "mesos_slave_cpus"},
+	"mesos_slave_allocation_cpus_revocable":                   {Name: "mesos_slave_cpus_revocable"},
+	"mesos_slave_allocation_mem_bytes":                        {Name: "mesos_slave_mem", Scale: 1.0 / Megabytes},
+	"mesos_slave_allocation_mem_revocable_bytes":              {Name: "mesos_slave_mem_revocable", Scale: 1.0 / Megabytes},
+	"mesos_slave_allocation_disk_bytes":                       {Name: "mesos_slave_disk", Scale: 1.0 / Megabytes},
+	"mesos_slave_allocation_disk_revocable_bytes":             {Name: "mesos_slave_disk_revocable", Scale: 1.0 / Megabytes},
+	"mesos_slave_executors_terminated_total":                  {Name: "mesos_slave_executors_terminated"},
+	"mesos_executor_cpus_limit":                               {Name: "cpus_limit"},
+	"mesos_executor_cpu_system_seconds_total":                 {Name: "cpu_system_seconds_total"},
+	"mesos_executor_cpu_user_seconds_total":                   {Name: "cpu_user_seconds_total"},
+	"mesos_executor_cpu_throttled_seconds_total":              {Name: "cpu_throttled_seconds_total"},
+	"mesos_executor_mem_limit_bytes":                          {Name: "mem_limit_bytes"},
+	"mesos_executor_mem_rss_bytes":                            {Name: "mem_rss_bytes"},
+	"mesos_executor_network_receive_bytes_total":              {Name: "network_receive_bytes_total"},
+	"mesos_executor_network_receive_dropped_total":            {Name: "network_receive_dropped_total"},
+	"mesos_executor_network_receive_errors_total":             {Name: "network_receive_errors_total"},
+	"mesos_executor_network_receive_packets_total":            {Name: "network_receive_packets_total"},
+	"mesos_executor_network_transmit_bytes_total":             {Name: "network_transmit_bytes_total"},
+	"mesos_executor_network_transmit_dropped_total":           {Name: "network_transmit_dropped_total"},
+	"mesos_executor_network_transmit_errors_total":            {Name: "network_transmit_errors_total"},
+	"mesos_executor_network_transmit_packets_total":           {Name: "network_transmit_packets_total"},
+	"mesos_executor_perf_sample_duration_seconds":             {Name: "perf_sample_duration_seconds"},
+	"mesos_executor_perf_cycles":                              {Name: "perf_cycles"},
+	"mesos_executor_perf_instructions":                        {Name: "perf_instructions"},
+	"mesos_executor_perf_cache_references":                    {Name: "perf_cache_references"},
+	"mesos_executor_perf_cache_misses":                        {Name: "perf_cache_misses"},
+	"mesos_executor_processes":                                {Name: "processes"},
+	"mesos_executor_threads":                                  {Name: "threads"},
+	"mesos_executor_network_tcp_connections":                  {Name: "network_tcp_connections"},
+	"mesos_executor_network_traffic_control_backlog_packets":  {Name: "network_traffic_control_backlog_packets"},
+	"mesos_executor_network_traffic_control_dropped_total":    {Name: "network_traffic_control_dropped_total"},
+	"mesos_executor_network_traffic_control_overlimits_total": {Name: "network_traffic_control_overlimits_total"},
+	"mesos_executor_blkio_serviced_total":                     {Name: "blkio_serviced_total"},
+	"mesos_executor_blkio_service_bytes_total":                {Name: "blkio_service_bytes_total"},
+	"mesos_executor_blkio_service_seconds_total":              {Name: "blkio_service_seconds_total"},
+	"mesos_executor_blkio_wait_seconds_total":                 {Name: "blkio_wait_seconds_total"},
+	"mesos_executor_network_tcp_rtt_seconds": {
+		Name:   "network_tcp_rtt_seconds",
+		Labels: map[string]string{"percentile": "quantile"},
+		Values: map[string]string{"50": "0.5", "90": "0.9", "95": "0.95", "99": "0.99"},
+	},
+}
@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// maintenanceStatus is the response of /maintenance/status. Machines
+	// which are neither draining nor down are up.
+	maintenanceStatus struct {
+		DrainingMachines []struct {
+			ID machineID `json:"id"`
+		} `json:"draining_machines"`
+		DownMachines []machineID `json:"down_machines"`
+	}
+
+	machineID struct {
+		Hostname string `json:"hostname"`
+		IP       string `json:"ip"`
+	}
+
+	maintenanceCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		mode     *prometheus.Desc
+		machines *prometheus.Desc
+	}
+)
+
+// NewMaintenanceCollector returns a collector of the maintenance mode of the
+// machines of the master running on url, as opposed to their maintenance
+// schedule.
+func NewMaintenanceCollector(url string, opts Options) prometheus.Collector {
+	return &maintenanceCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("maintenance", opts),
+
+		mode: gaugeDesc(
+			"mesos_master_machine_maintenance_mode",
+			"Maintenance mode of a machine which isn't up (DRAINING or DOWN), always 1",
+			[]string{"hostname", "ip", "mode"}, nil,
+		),
+		machines: gaugeDesc(
+			"mesos_master_machines_maintenance",
+			"Number of machines by maintenance mode which aren't up (DRAINING or DOWN)",
+			[]string{"mode"}, nil,
+		),
+	}
+}
+
+func (c *maintenanceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/maintenance/status"
+	var st maintenanceStatus
+	if err := getJSON(ctx, c.Client, u, &st); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for _, m := range st.DrainingMachines {
+		ch <- constMetric(c.mode, 1, m.ID.Hostname, m.ID.IP, "DRAINING")
+	}
+	for _, m := range st.DownMachines {
+		ch <- constMetric(c.mode, 1, m.Hostname, m.IP, "DOWN")
+	}
+	ch <- constMetric(c.machines, float64(len(st.DrainingMachines)), "DRAINING")
+	ch <- constMetric(c.machines, float64(len(st.DownMachines)), "DOWN")
+}
+
+func (c *maintenanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.mode
+	ch <- c.machines
+}
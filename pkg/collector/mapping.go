@@ -0,0 +1,267 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// MappingRule maps fields of a Mesos JSON endpoint to a metric family.
+//
+// Paths are dot separated keys and array indexes, evaluated against the
+// endpoint response for single value rules or against each element of Items
+// otherwise. Label paths are evaluated the same way, where "$key" is the key
+// or index of the current element. Items rules need a label telling their
+// elements apart, either "$key" or one named by Unique.
+type MappingRule struct {
+	// Role restricts the rule to the master or slave. Without a role, the
+	// rule applies to the master if one is scraped and the slave otherwise.
+	Role     string            `json:"role"`
+	Endpoint string            `json:"endpoint"`
+	Items    string            `json:"items"`
+	Value    string            `json:"value"`
+	Name     string            `json:"name"`
+	Help     string            `json:"help"`
+	Type     string            `json:"type"`
+	Labels   map[string]string `json:"labels"`
+	// Unique is the label whose values are unique among the items, e.g.
+	// an ID.
+	Unique string `json:"unique"`
+}
+
+func (r MappingRule) Validate() error {
+	if r.Role != "" && r.Role != RoleMaster && r.Role != RoleSlave {
+		return fmt.Errorf("unknown role %q", r.Role)
+	}
+	if r.Endpoint == "" || r.Value == "" {
+		return fmt.Errorf("endpoint and value are required")
+	}
+	if !model.IsValidMetricName(model.LabelValue(r.Name)) {
+		return fmt.Errorf("invalid name %q", r.Name)
+	}
+	if _, err := r.valueType(); err != nil {
+		return err
+	}
+	keyed := false
+	for l, path := range r.Labels {
+		if !model.LabelName(l).IsValid() {
+			return fmt.Errorf("invalid label name %q", l)
+		}
+		keyed = keyed || path == "$key"
+	}
+	if r.Unique != "" {
+		if _, ok := r.Labels[r.Unique]; !ok {
+			return fmt.Errorf("unique label %q not in labels", r.Unique)
+		}
+		keyed = true
+	}
+	if r.Items != "" && !keyed {
+		return fmt.Errorf("items require a $key or unique label")
+	}
+	return nil
+}
+
+func (r MappingRule) valueType() (prometheus.ValueType, error) {
+	switch r.Type {
+	case "", "gauge":
+		return prometheus.GaugeValue, nil
+	case "counter":
+		return prometheus.CounterValue, nil
+	}
+	return 0, fmt.Errorf("unknown type %q", r.Type)
+}
+
+type compiledRule struct {
+	MappingRule
+	desc   *prometheus.Desc
+	labels []string
+	// errors counts items dropped for their labels.
+	errors prometheus.Counter
+}
+
+// mappingCollector exports the metric families described by mapping rules,
+// fetching every endpoint referenced by the rules once per collection.
+// The collector is reported as down if any endpoint couldn't be fetched.
+type mappingCollector struct {
+	*http.Client
+	url   string
+	up    collectorUp
+	rules []compiledRule
+}
+
+// NewMappingCollector returns a collector of the metric families described by
+// rules, fetched from the node with the given role running on url.
+func NewMappingCollector(role, url string, opts Options, rules []MappingRule) prometheus.Collector {
+	c := &mappingCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp(role+"_mappings", opts),
+	}
+	for _, r := range rules {
+		labels := make([]string, 0, len(r.Labels))
+		for l := range r.Labels {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		help := r.Help
+		if help == "" {
+			help = fmt.Sprintf("Value of %s in %s", r.Value, r.Endpoint)
+		}
+		vt, _ := r.valueType()
+		c.rules = append(c.rules, compiledRule{
+			MappingRule: r,
+			desc:        newDesc(vt, r.Name, help, labels, nil),
+			labels:      labels,
+			errors:      c.up.errors,
+		})
+	}
+	return c
+}
+
+func (c *mappingCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	responses := map[string]interface{}{}
+	up := true
+	for _, r := range c.rules {
+		doc, ok := responses[r.Endpoint]
+		if !ok {
+			u := strings.TrimSuffix(c.url, "/") + "/" + strings.TrimPrefix(r.Endpoint, "/")
+			if err := getJSON(ctx, c.Client, u, &doc); err != nil {
+				log.Printf("Error fetching %s: %s", u, err)
+				c.up.errors.Inc()
+				up = false
+			}
+			responses[r.Endpoint] = doc
+		}
+		if doc != nil {
+			r.collect(doc, ch)
+		}
+	}
+	c.up.collect(ch, up)
+}
+
+func (c *mappingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	for _, r := range c.rules {
+		ch <- r.desc
+	}
+}
+
+func (r compiledRule) collect(doc interface{}, ch chan<- prometheus.Metric) {
+	seen := map[string]bool{}
+	if r.Items == "" {
+		r.emit("", doc, seen, ch)
+		return
+	}
+	items, ok := lookup(doc, r.Items)
+	if !ok {
+		return
+	}
+	switch items := items.(type) {
+	case []interface{}:
+		for i, item := range items {
+			r.emit(strconv.Itoa(i), item, seen, ch)
+		}
+	case map[string]interface{}:
+		// Sorted, so the same item wins every time if items collide.
+		keys := make([]string, 0, len(items))
+		for k := range items {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			r.emit(k, items[k], seen, ch)
+		}
+	}
+}
+
+// emit exports the value of item, unless an earlier item of the collection
+// had the same label values. seen holds the label values exported so far.
+func (r compiledRule) emit(key string, item interface{}, seen map[string]bool, ch chan<- prometheus.Metric) {
+	v, ok := lookup(item, r.Value)
+	if !ok {
+		return
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return
+	}
+	values := make([]string, len(r.labels))
+	for i, l := range r.labels {
+		if path := r.Labels[l]; path == "$key" {
+			values[i] = key
+		} else if lv, ok := lookup(item, path); ok {
+			values[i] = toLabel(lv)
+		}
+	}
+	id := strings.Join(values, "\xff")
+	if seen[id] {
+		log.Printf("Dropping %s%v, its labels aren't unique", r.Name, values)
+		r.errors.Inc()
+		return
+	}
+	seen[id] = true
+	ch <- constMetric(r.desc, f, values...)
+}
+
+// lookup evaluates a dot separated path in a decoded JSON document.
+func lookup(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, p := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[p]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toLabel(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
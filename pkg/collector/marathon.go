@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	marathonApp struct {
+		ID             string         `json:"id"`
+		Instances      int            `json:"instances"`
+		TasksStaged    int            `json:"tasksStaged"`
+		TasksRunning   int            `json:"tasksRunning"`
+		TasksHealthy   int            `json:"tasksHealthy"`
+		TasksUnhealthy int            `json:"tasksUnhealthy"`
+		Deployments    []struct{}     `json:"deployments"`
+		Tasks          []marathonTask `json:"tasks"`
+	}
+
+	marathonTask struct {
+		ID      string `json:"id"`
+		SlaveID string `json:"slaveId"`
+		State   string `json:"state"`
+	}
+
+	marathonCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		instances   *prometheus.Desc
+		tasks       *prometheus.Desc
+		deployments *prometheus.Desc
+		taskInfo    *prometheus.Desc
+	}
+)
+
+// NewMarathonCollector returns a collector of the apps of the Marathon
+// running on url. The tasks of apps are exported with their Mesos task ID,
+// so the task metrics of the master state can be joined with them.
+func NewMarathonCollector(url string, opts Options) prometheus.Collector {
+	return &marathonCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("marathon", opts),
+
+		instances: gaugeDesc(
+			"mesos_marathon_app_instances",
+			"Number of instances Marathon is configured to run of an app",
+			[]string{"app"}, nil,
+		),
+		tasks: gaugeDesc(
+			"mesos_marathon_app_tasks",
+			"Number of tasks of an app by state, where healthy and unhealthy tasks are also running",
+			[]string{"app", "state"}, nil,
+		),
+		deployments: gaugeDesc(
+			"mesos_marathon_app_deployments",
+			"Number of deployments of an app in progress",
+			[]string{"app"}, nil,
+		),
+		taskInfo: gaugeDesc(
+			"mesos_marathon_task_info",
+			"Marathon app of a Mesos task, always 1",
+			[]string{"app", "task", "slave", "state"}, nil,
+		),
+	}
+}
+
+func (c *marathonCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/v2/apps?embed=apps.counts&embed=apps.deployments&embed=apps.tasks"
+	var apps struct {
+		Apps []marathonApp `json:"apps"`
+	}
+	if err := getJSON(ctx, c.Client, u, &apps); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for _, a := range apps.Apps {
+		ch <- constMetric(c.instances, float64(a.Instances), a.ID)
+		for state, n := range map[string]int{
+			"staged":    a.TasksStaged,
+			"running":   a.TasksRunning,
+			"healthy":   a.TasksHealthy,
+			"unhealthy": a.TasksUnhealthy,
+		} {
+			ch <- constMetric(c.tasks, float64(n), a.ID, state)
+		}
+		ch <- constMetric(c.deployments, float64(len(a.Deployments)), a.ID)
+		for _, t := range a.Tasks {
+			ch <- constMetric(c.taskInfo, 1, a.ID, t.ID, t.SlaveID, t.State)
+		}
+	}
+}
+
+func (c *marathonCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.instances
+	ch <- c.tasks
+	ch <- c.deployments
+	ch <- c.taskInfo
+}
@@ -0,0 +1,424 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewMasterCollector returns a collector of the metrics snapshot of the master
+// running on url.
+func NewMasterCollector(url string, opts Options) prometheus.Collector {
+	metrics := map[prometheus.Collector]func(metricMap, prometheus.Collector) error{
+		// CPU/Disk/Mem resources in free/used
+		gauge("master", "cpus", "Current CPU resources in cluster (fractional).", "type"): func(m metricMap, c prometheus.Collector) error {
+			total, ok := m["master/cpus_total"]
+			used, ok := m["master/cpus_used"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set(total - used)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
+			return nil
+		},
+		gauge("master", "cpus_revocable", "Current revocable CPU resources in cluster (fractional).", "type"): func(m metricMap, c prometheus.Collector) error {
+			total, ok := m["master/cpus_revocable_total"]
+			used, ok := m["master/cpus_revocable_used"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set(total - used)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
+			return nil
+		},
+		gauge("master", "mem_bytes", "Current memory resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
+			total, ok := m["master/mem_total"]
+			used, ok := m["master/mem_used"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
+			return nil
+		},
+		gauge("master", "mem_revocable_bytes", "Current revocable memory resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
+			total, ok := m["master/mem_revocable_total"]
+			used, ok := m["master/mem_revocable_used"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
+			return nil
+		},
+		gauge("master", "disk_bytes", "Current disk resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
+			total, ok := m["master/disk_total"]
+			used, ok := m["master/disk_used"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
+			return nil
+		},
+		gauge("master", "disk_revocable_bytes", "Current revocable disk resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
+			total, ok := m["master/disk_revocable_total"]
+			used, ok := m["master/disk_revocable_used"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
+			return nil
+		},
+
+		// Master stats about uptime and election state
+		prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "mesos",
+			Subsystem: "master",
+			Name:      "elected",
+			Help:      "1 if master is elected leader, 0 if not",
+		}): func(m metricMap, c prometheus.Collector) error {
+			elected, ok := m["master/elected"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(prometheus.Gauge).Set(elected)
+			return nil
+		},
+		prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "mesos",
+			Subsystem: "master",
+			Name:      "uptime_seconds",
+			Help:      "Number of seconds the master process is running.",
+		}): func(m metricMap, c prometheus.Collector) error {
+			uptime, ok := m["master/uptime_secs"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(prometheus.Gauge).Set(uptime)
+			return nil
+		},
+		// Master stats about agents
+		counter("master", "slave_registration_events_total", "Total number of registration events on this master since it booted.", "event"): func(m metricMap, c prometheus.Collector) error {
+			registrations, ok := m["master/slave_registrations"]
+			reregistrations, ok := m["master/slave_reregistrations"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(registrations, "register")
+			c.(*settableCounterVec).Set(reregistrations, "reregister")
+			return nil
+		},
+
+		counter("master", "slave_removal_events_total", "Total number of removal events on this master since it booted.", "event"): func(m metricMap, c prometheus.Collector) error {
+			scheduled, ok := m["master/slave_shutdowns_scheduled"]
+			canceled, ok := m["master/slave_shutdowns_canceled"]
+			completed, ok := m["master/slave_shutdowns_completed"]
+			removals, ok := m["master/slave_removals"]
+			if !ok {
+				return notFoundInMap
+			}
+
+			c.(*settableCounterVec).Set(scheduled, "scheduled")
+			c.(*settableCounterVec).Set(canceled, "canceled")
+			c.(*settableCounterVec).Set(completed, "completed")
+			c.(*settableCounterVec).Set(removals-completed, "died")
+			return nil
+		},
+		counter("master", "slave_removals_total", "Total number of slaves removed on this master since it booted by reason.", "reason"): func(m metricMap, c prometheus.Collector) error {
+			for k, v := range m {
+				// Since Mesos 1.1 only
+				if reason := strings.TrimPrefix(k, "master/slave_removals/reason_"); reason != k {
+					c.(*settableCounterVec).Set(v, reason)
+				}
+			}
+			return nil
+		},
+		counter("master", "slave_unreachable_events_total", "Total number of events of slaves becoming unreachable on this master since it booted.", "event"): func(m metricMap, c prometheus.Collector) error {
+			for _, event := range []string{"scheduled", "canceled", "completed"} {
+				// Since Mesos 1.1, where unreachable slaves aren't shut down
+				// any more.
+				v, ok := m["master/slave_unreachable_"+event]
+				if !ok {
+					return notFoundInMap
+				}
+				c.(*settableCounterVec).Set(v, event)
+			}
+			return nil
+		},
+		gauge("master", "slaves_state", "Current number of slaves known to the master per connection and registration state.", "connection_state", "registration_state"): func(m metricMap, c prometheus.Collector) error {
+			active, ok := m["master/slaves_active"]
+			inactive, ok := m["master/slaves_inactive"]
+			disconnected, ok := m["master/slaves_disconnected"]
+
+			if !ok {
+				return notFoundInMap
+			}
+			// FIXME: Make sure those assumptions are right
+			// Every "active" node is connected to the master
+			c.(*prometheus.GaugeVec).WithLabelValues("connected", "active").Set(active)
+			// Every "inactive" node is connected but node sending offers
+			c.(*prometheus.GaugeVec).WithLabelValues("connected", "inactive").Set(inactive)
+			// Every "disconnected" node is "inactive"
+			c.(*prometheus.GaugeVec).WithLabelValues("disconnected", "inactive").Set(disconnected)
+			// Every "connected" node is either active or inactive
+			return nil
+		},
+
+		// Master stats about frameworks
+		gauge("master", "frameworks_state", "Current number of frames known to the master per connection and registration state.", "connection_state", "registration_state"): func(m metricMap, c prometheus.Collector) error {
+			active, ok := m["master/frameworks_active"]
+			inactive, ok := m["master/frameworks_inactive"]
+			disconnected, ok := m["master/frameworks_disconnected"]
+
+			if !ok {
+				return notFoundInMap
+			}
+			// FIXME: Make sure those assumptions are right
+			// Every "active" framework is connected to the master
+			c.(*prometheus.GaugeVec).WithLabelValues("connected", "active").Set(active)
+			// Every "inactive" framework is connected but framework sending offers
+			c.(*prometheus.GaugeVec).WithLabelValues("connected", "inactive").Set(inactive)
+			// Every "disconnected" framework is "inactive"
+			c.(*prometheus.GaugeVec).WithLabelValues("disconnected", "inactive").Set(disconnected)
+			// Every "connected" framework is either active or inactive
+			return nil
+		},
+		prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "mesos",
+			Subsystem: "master",
+			Name:      "offers_pending",
+			Help:      "Current number of offers made by the master which aren't yet accepted or declined by frameworks.",
+		}): func(m metricMap, c prometheus.Collector) error {
+			offers, ok := m["master/outstanding_offers"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(prometheus.Gauge).Set(offers)
+			// c.(*prometheus.Gauge).Set(offers)
+			return nil
+		},
+		// Master stats about tasks
+		counter("master", "task_states_exit_total", "Total number of tasks processed by exit state.", "state"): func(m metricMap, c prometheus.Collector) error {
+			errored, ok := m["master/tasks_error"]
+			failed, ok := m["master/tasks_failed"]
+			finished, ok := m["master/tasks_finished"]
+			killed, ok := m["master/tasks_killed"]
+			lost, ok := m["master/tasks_lost"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(errored, "errored")
+			c.(*settableCounterVec).Set(failed, "failed")
+			c.(*settableCounterVec).Set(finished, "finished")
+			c.(*settableCounterVec).Set(killed, "killed")
+			c.(*settableCounterVec).Set(lost, "lost")
+			return nil
+		},
+		gauge("master", "task_states_current", "Current number of tasks by state.", "state"): func(m metricMap, c prometheus.Collector) error {
+			running, ok := m["master/tasks_running"]
+			staging, ok := m["master/tasks_staging"]
+			starting, ok := m["master/tasks_starting"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues("running").Set(running)
+			c.(*prometheus.GaugeVec).WithLabelValues("staging").Set(staging)
+			c.(*prometheus.GaugeVec).WithLabelValues("starting").Set(starting)
+			return nil
+		},
+
+		// Master stats about messages
+		counter("master", "messages_outcomes_total",
+			"Total number of messages by outcome of operation and direction.",
+			"source", "destination", "outcome"): func(m metricMap, c prometheus.Collector) error {
+			frameworkToExecutorValid, ok := m["master/valid_framework_to_executor_messages"]
+			frameworkToExecutorInvalid, ok := m["master/invalid_framework_to_executor_messages"]
+			executorToFrameworkValid, ok := m["master/valid_executor_to_framework_messages"]
+			executorToFrameworkInvalid, ok := m["master/invalid_executor_to_framework_messages"]
+
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(frameworkToExecutorValid, "framework", "executor", "valid")
+			c.(*settableCounterVec).Set(frameworkToExecutorInvalid, "framework", "executor", "invalid")
+
+			c.(*settableCounterVec).Set(executorToFrameworkValid, "executor", "framework", "valid")
+			c.(*settableCounterVec).Set(executorToFrameworkInvalid, "executor", "framework", "invalid")
+			return nil
+		},
+		counter("master", "status_update_messages_total", "Total number of status updates and acknowledgements of status updates received by outcome.", "type", "outcome"): func(m metricMap, c prometheus.Collector) error {
+			for _, typ := range []string{"status_updates", "status_update_acknowledgements"} {
+				for _, outcome := range []string{"valid", "invalid"} {
+					v, ok := m["master/"+outcome+"_"+typ]
+					if !ok {
+						return notFoundInMap
+					}
+					c.(*settableCounterVec).Set(v, strings.TrimSuffix(typ, "s"), outcome)
+				}
+			}
+			return nil
+		},
+		counter("master", "messages_type_total", "Total number of valid messages by type.", "type"): func(m metricMap, c prometheus.Collector) error {
+			for k, v := range m {
+				i := strings.Index("master/messages_", k)
+				if i == -1 {
+					continue
+				}
+				// FIXME: We expose things like messages_framework_to_executor twice
+				c.(*settableCounterVec).Set(v, k[i:])
+			}
+			return nil
+		},
+
+		// Master stats about offers, per framework since Mesos 1.4
+		counter("master", "framework_offers_total", "Total number of offers sent to a framework by what became of them.", "framework", "event"): func(m metricMap, c prometheus.Collector) error {
+			// Frameworks which are gone must not be exported forever.
+			c.(*settableCounterVec).Reset()
+			for k, v := range m {
+				// master/frameworks/<name>/<id>/offers/<event>, where the
+				// name is URL encoded and so free of slashes.
+				parts := strings.Split(k, "/")
+				if len(parts) != 6 || parts[0] != "master" || parts[1] != "frameworks" || parts[4] != "offers" {
+					continue
+				}
+				switch event := parts[5]; event {
+				case "sent", "accepted", "declined", "rescinded":
+					c.(*settableCounterVec).Set(v, parts[3], event)
+				}
+			}
+			return nil
+		},
+
+		// Master stats about authentication. Mesos only counts the
+		// authenticate messages, the authenticators only log the outcome.
+		counter("master", "authentication_attempts_total", "Total number of authentication attempts of frameworks and slaves, successful or not."): func(m metricMap, c prometheus.Collector) error {
+			attempts, ok := m["master/messages_authenticate"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(attempts)
+			return nil
+		},
+
+		// Master stats about offer suppression, per framework since Mesos 1.5
+		gauge("master", "framework_role_suppressed", "1 if a framework suppresses offers for a role, 0 if not.", "framework", "role"): func(m metricMap, c prometheus.Collector) error {
+			c.(*prometheus.GaugeVec).Reset()
+			for framework, roles := range suppressedRoles(m) {
+				for role, suppressed := range roles {
+					c.(*prometheus.GaugeVec).WithLabelValues(framework, role).Set(suppressed)
+				}
+			}
+			return nil
+		},
+		gauge("master", "role_suppressed_frameworks", "Number of frameworks suppressing offers for a role.", "role"): func(m metricMap, c prometheus.Collector) error {
+			c.(*prometheus.GaugeVec).Reset()
+			for _, roles := range suppressedRoles(m) {
+				for role, suppressed := range roles {
+					c.(*prometheus.GaugeVec).WithLabelValues(role).Add(suppressed)
+				}
+			}
+			return nil
+		},
+
+		// Master stats about task reconciliation, whose latency Mesos
+		// doesn't measure
+		counter("master", "reconcile_messages_total", "Total number of task reconciliation requests of frameworks using the scheduler driver."): func(m metricMap, c prometheus.Collector) error {
+			reconciles, ok := m["master/messages_reconcile_tasks"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(reconciles)
+			return nil
+		},
+		counter("master", "framework_reconcile_calls_total", "Total number of task reconciliation calls of a framework using the scheduler API.", "framework"): func(m metricMap, c prometheus.Collector) error {
+			c.(*settableCounterVec).Reset()
+			for k, v := range m {
+				// master/frameworks/<name>/<id>/calls/reconcile, since
+				// Mesos 1.4
+				parts := strings.Split(k, "/")
+				if len(parts) == 6 && parts[0] == "master" && parts[1] == "frameworks" && parts[4] == "calls" && parts[5] == "reconcile" {
+					c.(*settableCounterVec).Set(v, parts[3])
+				}
+			}
+			return nil
+		},
+
+		// Master stats about rate limiting, only with --rate_limits
+		counter("master", "principal_messages_total", "Total number of messages of frameworks received and processed by principal, which differ while rate limited.", "principal", "state"): func(m metricMap, c prometheus.Collector) error {
+			c.(*settableCounterVec).Reset()
+			for k, v := range m {
+				if !strings.HasPrefix(k, "frameworks/") {
+					continue
+				}
+				for _, state := range []string{"received", "processed"} {
+					if principal := strings.TrimSuffix(k, "/messages_"+state); principal != k {
+						c.(*settableCounterVec).Set(v, strings.TrimPrefix(principal, "frameworks/"), state)
+					}
+				}
+			}
+			return nil
+		},
+
+		// Master stats about events
+		gauge("master", "event_queue_length", "Current number of elements in event queue by type", "type"): func(m metricMap, c prometheus.Collector) error {
+			for typ, key := range map[string]string{
+				"message":      "master/event_queue_messages",
+				"http_request": "master/event_queue_http_requests",
+				"dispatches":   "master/event_queue_dispatches",
+			} {
+				v, ok := m[key]
+				if !ok {
+					return notFoundInMap
+				}
+				c.(*prometheus.GaugeVec).WithLabelValues(typ).Set(v)
+			}
+			return nil
+		},
+		prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "mesos",
+			Subsystem: "master",
+			Name:      "allocator_event_queue_length",
+			Help:      "Current number of dispatches queued up for the allocator.",
+		}): func(m metricMap, c prometheus.Collector) error {
+			dispatches, ok := m["allocator/mesos/event_queue_dispatches"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(prometheus.Gauge).Set(dispatches)
+			return nil
+		},
+
+		// Master stats about registrar
+		prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "mesos",
+			Subsystem: "master",
+			Name:      "state_store_seconds",
+			Help:      "Registry write latency in seconds",
+		}): func(m metricMap, c prometheus.Collector) error {
+			//	c.(*prometheus.Histogram).Buckets //FIXME
+			return nil
+		},
+	}
+	return newMetricCollector("master", url, opts, metrics)
+}
+
+// suppressedRoles returns by framework ID and role whether the framework
+// suppresses offers for the role, from the metrics
+// master/frameworks/<name>/<id>/roles/<role>/suppressed. Roles may be
+// hierarchical and contain slashes.
+func suppressedRoles(m metricMap) map[string]map[string]float64 {
+	roles := map[string]map[string]float64{}
+	for k, v := range m {
+		parts := strings.SplitN(k, "/", 6)
+		if len(parts) != 6 || parts[0] != "master" || parts[1] != "frameworks" || parts[4] != "roles" || !strings.HasSuffix(parts[5], "/suppressed") {
+			continue
+		}
+		if roles[parts[3]] == nil {
+			roles[parts[3]] = map[string]float64{}
+		}
+		roles[parts[3]][strings.TrimSuffix(parts[5], "/suppressed")] = v
+	}
+	return roles
+}
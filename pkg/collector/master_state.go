@@ -0,0 +1,1125 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// Task is a task of a framework.
+	Task struct {
+		Name        string     `json:"name"`
+		ID          string     `json:"id"`
+		ExecutorID  string     `json:"executor_id"`
+		FrameworkID string     `json:"framework_id"`
+		SlaveID     string     `json:"slave_id"`
+		State       string     `json:"state"`
+		Labels      []Label    `json:"labels,omitempty" mesos:"optional"`
+		Resources   Resources  `json:"resources"`
+		Statuses    []Status   `json:"statuses"`
+		Discovery   *Discovery `json:"discovery,omitempty" mesos:"optional"`
+		Container   *Container `json:"container,omitempty" mesos:"optional"`
+	}
+
+	// Label is a key value pair attached to a task.
+	Label struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+
+	// Status is a status update of a task.
+	Status struct {
+		State     string  `json:"state"`
+		Timestamp float64 `json:"timestamp"`
+		Reason    string  `json:"reason,omitempty" mesos:"optional"`
+		Source    string  `json:"source,omitempty" mesos:"optional"`
+	}
+
+	// Slave is a slave registered with the master.
+	Slave struct {
+		ID         string    `json:"id"`
+		PID        string    `json:"pid"`
+		Hostname   string    `json:"hostname"`
+		Port       int       `json:"port,omitempty" mesos:"optional"`
+		Active     bool      `json:"active"`
+		Used       Resources `json:"used_resources"`
+		Unreserved Resources `json:"unreserved_resources"`
+		Total      Resources `json:"resources"`
+
+		// The full resources tell apart reservations, revocable resources
+		// and disk sources. They are only reported by Mesos 1.0 and later.
+		UsedFull       *Resources           `json:"used_resources_full,omitempty" mesos:"optional"`
+		UnreservedFull *Resources           `json:"unreserved_resources_full,omitempty" mesos:"optional"`
+		ReservedFull   map[string]Resources `json:"reserved_resources_full,omitempty" mesos:"optional"`
+		OfferedFull    *Resources           `json:"offered_resources_full,omitempty" mesos:"optional"`
+
+		// Domain is the fault domain of the slave, since Mesos 1.5.
+		Domain *Domain `json:"domain,omitempty" mesos:"optional"`
+
+		// malformed is set if a resource block couldn't be decoded.
+		malformed error
+	}
+
+	// Domain is the fault domain of a slave, its region and zone.
+	Domain struct {
+		FaultDomain struct {
+			Region struct {
+				Name string `json:"name"`
+			} `json:"region"`
+			Zone struct {
+				Name string `json:"name"`
+			} `json:"zone"`
+		} `json:"fault_domain"`
+	}
+
+	// Framework is a framework registered with the master.
+	Framework struct {
+		ID                 string              `json:"id"`
+		Name               string              `json:"name"`
+		Active             bool                `json:"active"`
+		Tasks              []Task              `json:"tasks"`
+		Completed          []Task              `json:"completed_tasks"`
+		Executors          []FrameworkExecutor `json:"executors,omitempty" mesos:"optional"`
+		CompletedExecutors []FrameworkExecutor `json:"completed_executors,omitempty" mesos:"optional"`
+		// Resources are the resources allocated to the framework, i.e.
+		// used by its tasks or offered to it.
+		Resources Resources `json:"resources"`
+		// Checkpoint tells whether the slaves checkpoint the tasks of the
+		// framework, so they survive restarts of slaves.
+		Checkpoint bool `json:"checkpoint"`
+		// FailoverTimeout is how long, in seconds, the master waits for
+		// a disconnected framework before killing its tasks.
+		FailoverTimeout float64 `json:"failover_timeout"`
+		// Capabilities are the capabilities the framework registered with,
+		// e.g. PARTITION_AWARE.
+		Capabilities []string `json:"capabilities,omitempty" mesos:"optional"`
+		// PID is the libprocess PID of frameworks using the scheduler
+		// driver, empty for those using the v1 HTTP scheduler API.
+		PID string `json:"pid,omitempty" mesos:"optional"`
+
+		// skippedCompleted is the number of completed tasks dropped by
+		// the filter to stay within its limit.
+		skippedCompleted int
+	}
+
+	// FrameworkExecutor is an executor of a framework, as reported by Mesos
+	// 1.5 and later, which lists tasks below their executors.
+	FrameworkExecutor struct {
+		ID        string `json:"id"`
+		Tasks     []Task `json:"tasks"`
+		Completed []Task `json:"completed_tasks"`
+	}
+
+	// State is the state of a master, after version compatibility handling,
+	// normalization and filtering.
+	State struct {
+		Version string `json:"version"`
+		PID     string `json:"pid"`
+		Leader  string `json:"leader"`
+		// ElectedTime is the Unix timestamp of the election of the master,
+		// only reported by the leader.
+		ElectedTime float64     `json:"elected_time,omitempty" mesos:"optional"`
+		Slaves      []Slave     `json:"slaves,omitempty" mesos:"optional"`
+		Frameworks  []Framework `json:"frameworks"`
+
+		// Agents is only used while decoding, see UnmarshalJSON.
+		Agents []Slave `json:"agents,omitempty" mesos:"optional"`
+
+		// malformedSlaves is the number of slaves dropped because of
+		// malformed resources.
+		malformedSlaves int
+		// maxCompleted limits the completed tasks per framework kept while
+		// decoding, see decodeStream.
+		maxCompleted int
+	}
+
+	// MasterStateCollector collects metrics derived from the state of a
+	// master.
+	MasterStateCollector struct {
+		*http.Client
+		url     string
+		path    string
+		filter  StateFilter
+		up      collectorUp
+		metrics map[prometheus.Collector]func(*State, prometheus.Collector)
+		// constMetrics are created from scratch on every collection.
+		constMetrics []stateMetric
+
+		leaderOnly  bool
+		transitions *taskTransitions
+		leaders     *leaderChanges
+	}
+
+	stateMetric struct {
+		desc    *prometheus.Desc
+		collect func(*State, *prometheus.Desc, chan<- prometheus.Metric)
+	}
+
+	// StateOptions configure how the master state is fetched and exported.
+	StateOptions struct {
+		Options
+		// Path is the path of the state endpoint, relative to the master
+		// URL, "/state" if empty.
+		Path   string
+		Filter StateFilter
+		// LeaderOnly suppresses all metrics if the master isn't the
+		// leader, whose state is the only authoritative one.
+		LeaderOnly bool
+		// CountersFile persists counters derived by comparing scrapes.
+		CountersFile string
+		// StatusTimestamps exports task status times with the time of the
+		// status as sample timestamp.
+		StatusTimestamps bool
+		// LegacyUnits exports memory and disk in KiB instead of bytes, as
+		// earlier versions of the exporter did.
+		LegacyUnits bool
+		// Events is called with the task state changes between scrapes.
+		Events func([]TaskEvent)
+		// Buckets override the DefaultBuckets of histogram families by
+		// name.
+		Buckets map[string][]float64
+		// ResourceUnits declare the units of custom resources, which are
+		// exported scaled in families of their own, by resource name.
+		ResourceUnits map[string]ResourceUnit
+		// Enrichers derive the app_id and job_id labels of per task
+		// metrics, which are only exported if there are any.
+		Enrichers []TaskEnricher
+	}
+
+	// StateFilter drops the parts of a state which shouldn't be exported.
+	StateFilter struct {
+		// IncludeInactive keeps the tasks of inactive and disconnected
+		// frameworks.
+		IncludeInactive bool
+		// CompletedRetention drops completed tasks whose last status is
+		// older than this. Zero keeps all completed tasks.
+		CompletedRetention time.Duration
+		// MaxCompleted limits the number of completed tasks per framework
+		// to the most recent ones. Zero keeps all completed tasks. The
+		// collector drops the others while decoding the state, before
+		// counting task state changes, but Mesos still sends them all, so
+		// it only bounds what is kept and exported, not the size of the
+		// response.
+		MaxCompleted int
+	}
+)
+
+// taskLabels are the labels of per task metrics, see taskLabeler. The state
+// label always comes last.
+var taskLabels = []string{"slave", "task", "executor", "name", "framework", "state"}
+
+// NewMasterStateCollector returns a collector of metrics derived from the
+// state of the master running on url.
+func NewMasterStateCollector(url string, opts StateOptions) *MasterStateCollector {
+	labels := []string{"slave"}
+	labeler := taskLabeler(opts.Enrichers)
+	// Mesos reports memory and disk in MB.
+	bytes := float64(Megabytes)
+	if opts.LegacyUnits {
+		bytes = Megabytes >> 10
+	}
+	transitions := newTaskTransitions(opts.CountersFile, opts.Buckets)
+	transitions.events = opts.Events
+	transitions.errors = opts.Internal.orDefault().errors
+	if opts.Path == "" {
+		opts.Path = "/state"
+	}
+	c := &MasterStateCollector{
+		Client: opts.client(),
+		url:    url,
+		path:   "/" + strings.TrimPrefix(opts.Path, "/"),
+		filter: opts.Filter,
+		up:     newCollectorUp("master_state", opts.Options),
+
+		leaderOnly:  opts.LeaderOnly,
+		transitions: transitions,
+		leaders:     newLeaderChanges(),
+		metrics: map[prometheus.Collector]func(*State, prometheus.Collector){
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Information about a slave, always 1",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "info",
+			}, []string{"slave", "pid", "hostname", "port", "active", "region", "zone"}): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					region, zone := s.faultDomain()
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID, s.PID, s.Hostname, strconv.Itoa(s.port()), strconv.FormatBool(s.Active), region, zone).Set(1)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Total slave CPUs (fractional)",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "cpus",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Total.CPUs)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Used slave CPUs (fractional)",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "cpus_used",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Used.CPUs)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Unreserved slave CPUs (fractional)",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "cpus_unreserved",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Unreserved.CPUs)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Total slave memory in bytes",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "mem_bytes",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Total.Mem * bytes)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Used slave memory in bytes",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "mem_used_bytes",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Used.Mem * bytes)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Unreserved slave memory in bytes",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "mem_unreserved_bytes",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Unreserved.Mem * bytes)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Total slave disk space in bytes",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "disk_bytes",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Total.Disk * bytes)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Used slave disk space in bytes",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "disk_used_bytes",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Used.Disk * bytes)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Unreserved slave disk space in bytes",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "disk_unreserved_bytes",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(s.Unreserved.Disk * bytes)
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Total number of slave ports",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "ports",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					size := s.Total.Ports.size()
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(float64(size))
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Used number of slave ports",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "ports_used",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					size := s.Used.Ports.size()
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(float64(size))
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Unreserved number of slave ports",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "ports_unreserved",
+			}, labels): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					size := s.Unreserved.Ports.size()
+					c.(*prometheus.GaugeVec).WithLabelValues(s.ID).Set(float64(size))
+				}
+			},
+			prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Help:      "Number of ports in each port range of a slave by type (total, used, unreserved)",
+				Namespace: "mesos",
+				Subsystem: "slave",
+				Name:      "port_range_ports",
+			}, []string{"slave", "type", "range"}): func(st *State, c prometheus.Collector) {
+				for _, s := range st.Slaves {
+					for typ, rs := range map[string]Ranges{
+						"total":      s.Total.Ports,
+						"used":       s.Used.Ports,
+						"unreserved": s.Unreserved.Ports,
+					} {
+						for _, r := range rs {
+							c.(*prometheus.GaugeVec).WithLabelValues(s.ID, typ, r.String()).Set(float64(r.size()))
+						}
+					}
+				}
+			},
+		},
+		constMetrics: []stateMetric{
+			{
+				gaugeDesc(
+					"mesos_slave_resources",
+					"Resources of a slave by type (used, unreserved, reserved, offered), resource, role, reservation principal, revocability and disk source. Scalar resources are in the unit reported by Mesos, ranges and sets in number of elements",
+					append([]string{"slave", "type"}, resourceKeyLabels...), nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, s := range st.Slaves {
+						for typ, rs := range s.resourcesByType() {
+							for k, v := range rs.sums() {
+								ch <- constMetric(desc, v, append([]string{s.ID, typ}, k.labelValues()...)...)
+							}
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_slave_resources_defaulted",
+					"Resources of a slave by type (total, used, unreserved) which were missing from the master state and are exported as 0, always 1",
+					[]string{"slave", "type", "resource"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, s := range st.Slaves {
+						for typ, r := range map[string]Resources{
+							"total":      s.Total,
+							"used":       s.Used,
+							"unreserved": s.Unreserved,
+						} {
+							for _, name := range r.missing {
+								ch <- constMetric(desc, 1, s.ID, typ, name)
+							}
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_slave_largest_task_cpus",
+					"CPUs of the largest task which fits on an active slave, i.e. its unused CPUs",
+					labels, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, s := range st.Slaves {
+						if s.Active {
+							ch <- constMetric(desc, s.free().CPUs, s.ID)
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_slave_largest_task_mem_bytes",
+					"Memory of the largest task which fits on an active slave, i.e. its unused memory, in bytes",
+					labels, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, s := range st.Slaves {
+						if s.Active {
+							ch <- constMetric(desc, s.free().Mem*bytes, s.ID)
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_cluster_largest_task_cpus",
+					"CPUs of the largest task which fits on any active slave, by the resource it's the largest in (cpus or mem)",
+					[]string{"by"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					if byCPUs, byMem, ok := st.largestTasks(); ok {
+						ch <- constMetric(desc, byCPUs.CPUs, "cpus")
+						ch <- constMetric(desc, byMem.CPUs, "mem")
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_cluster_largest_task_mem_bytes",
+					"Memory of the largest task which fits on any active slave in bytes, by the resource it's the largest in (cpus or mem)",
+					[]string{"by"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					if byCPUs, byMem, ok := st.largestTasks(); ok {
+						ch <- constMetric(desc, byCPUs.Mem*bytes, "cpus")
+						ch <- constMetric(desc, byMem.Mem*bytes, "mem")
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_slaves_malformed",
+					"Number of slaves which weren't exported because their resources couldn't be decoded",
+					nil, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					ch <- constMetric(desc, float64(st.malformedSlaves))
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_completed_tasks_skipped",
+					"Number of completed tasks of a framework which weren't exported because of the configured limit",
+					[]string{"framework"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						ch <- constMetric(desc, float64(f.skippedCompleted), f.ID)
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_frameworks_api",
+					"Number of frameworks by the API they're connected with: the v1 HTTP scheduler API (http) or the scheduler driver of libmesos (driver)",
+					[]string{"api"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					apis := map[string]float64{"http": 0, "driver": 0}
+					for _, f := range st.Frameworks {
+						apis[f.api()]++
+					}
+					for api, n := range apis {
+						ch <- constMetric(desc, n, api)
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_checkpoint",
+					"1 if the tasks of a framework are checkpointed by slaves and survive their restarts, 0 if not",
+					[]string{"framework", "name"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						v := 0.0
+						if f.Checkpoint {
+							v = 1
+						}
+						ch <- constMetric(desc, v, f.ID, f.Name)
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_failover_timeout_seconds",
+					"Time the master waits for a disconnected framework to fail over before killing its tasks",
+					[]string{"framework", "name"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						ch <- constMetric(desc, f.FailoverTimeout, f.ID, f.Name)
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_capability",
+					"1 if a framework registered with a capability, 0 if not",
+					[]string{"framework", "name", "capability"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						for capability, has := range f.capabilities() {
+							v := 0.0
+							if has {
+								v = 1
+							}
+							ch <- constMetric(desc, v, f.ID, f.Name, capability)
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_resource_share",
+					"Share of the total resources of the cluster allocated to a framework by resource (cpus, mem, disk)",
+					[]string{"framework", "resource"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					total := st.totalResources()
+					for _, f := range st.Frameworks {
+						for resource, share := range f.shares(total) {
+							ch <- constMetric(desc, share, f.ID, resource)
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_dominant_share",
+					"Largest share of any resource of the cluster allocated to a framework, which the DRF allocator orders frameworks by",
+					[]string{"framework"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					total := st.totalResources()
+					for _, f := range st.Frameworks {
+						var dominant float64
+						for _, share := range f.shares(total) {
+							dominant = math.Max(dominant, share)
+						}
+						ch <- constMetric(desc, dominant, f.ID)
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_slave_frameworks",
+					"Number of distinct frameworks with running tasks on a slave",
+					labels, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					frameworks := st.spread()
+					for _, s := range st.Slaves {
+						ch <- constMetric(desc, float64(len(frameworks[s.ID])), s.ID)
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_framework_slaves",
+					"Number of distinct slaves a framework has running tasks on",
+					[]string{"framework"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						ch <- constMetric(desc, float64(len(f.slaves())), f.ID)
+					}
+				},
+			},
+			taskResourceMetric(labeler, "cpus_limit", "Fractional CPUs allocated to running tasks", func(r Resources) float64 { return r.CPUs }),
+			taskResourceMetric(labeler, "mem_limit_bytes", "Memory allocated to running tasks in bytes", func(r Resources) float64 { return r.Mem * Megabytes }),
+			taskResourceMetric(labeler, "disk_limit_bytes", "Disk space allocated to running tasks in bytes", func(r Resources) float64 { return r.Disk * Megabytes }),
+			{
+				gaugeDesc("mesos_task_labels", taskLabelsHelp, []string{"task", "framework"}, nil),
+				func(st *State, _ *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						for _, tasks := range [][]Task{f.Tasks, f.Completed} {
+							for _, t := range tasks {
+								ch <- t.labelsMetric()
+							}
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_task_port_info",
+					"Ports of running tasks by their discovery info and port mappings, always 1",
+					[]string{"slave", "task", "framework", "port_name", "protocol", "host_port", "container_port"}, nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						for _, t := range f.Tasks {
+							if t.State != "TASK_RUNNING" {
+								continue
+							}
+							for _, p := range t.ports() {
+								ch <- constMetric(desc, 1,
+									t.SlaveID, t.ID, t.FrameworkID, p.name, p.protocol, p.hostPort, p.containerPort)
+							}
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_task_state_time_seconds",
+					"Unix timestamp of the latest status update of tasks which haven't terminated yet",
+					labeler.names(), nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						for _, t := range f.Tasks {
+							if s, ok := t.lastStatus(); ok && !IsTerminal(t.State) {
+								ch <- opts.statusMetric(desc, s, labeler.values(&f, &t))
+							}
+						}
+					}
+				},
+			},
+			{
+				gaugeDesc(
+					"mesos_task_finished_time_seconds",
+					"Unix timestamp of the terminal status update of terminated tasks",
+					labeler.names(), nil,
+				),
+				func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+					for _, f := range st.Frameworks {
+						// Terminated tasks stay in the task list until their
+						// status update got acknowledged.
+						for _, tasks := range [][]Task{f.Tasks, f.Completed} {
+							for _, t := range tasks {
+								if s, ok := t.lastStatus(); ok && IsTerminal(t.State) {
+									ch <- opts.statusMetric(desc, s, labeler.values(&f, &t))
+								}
+							}
+						}
+					}
+				},
+			},
+		},
+	}
+	c.constMetrics = append(c.constMetrics, resourceUnitMetrics(opts.ResourceUnits)...)
+	return c
+}
+
+const taskLabelsHelp = "Mesos labels of a task as label_<key> labels, always 1"
+
+// labelsMetric returns the mesos_task_labels series of the task. Every task
+// has labels of its own, so the series of different tasks have different
+// label names. Keys are sanitized into valid label names, and of keys which
+// end up the same only the first one is kept.
+func (t Task) labelsMetric() prometheus.Metric {
+	names := []string{"task", "framework"}
+	values := []string{t.ID, t.FrameworkID}
+	seen := map[string]bool{}
+	labels := append([]Label(nil), t.Labels...)
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Key < labels[j].Key })
+	for _, l := range labels {
+		name := "label_" + invalidLabelChars.ReplaceAllString(l.Key, "_")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		values = append(values, l.Value)
+	}
+	desc := prometheus.NewDesc("mesos_task_labels", taskLabelsHelp, names, nil)
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// taskResourceMetric exports a resource of all running tasks.
+func taskResourceMetric(labeler taskLabeler, name, help string, get func(Resources) float64) stateMetric {
+	return stateMetric{
+		gaugeDesc("mesos_task_"+name, help, labeler.runningNames(), nil),
+		func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+			for _, f := range st.Frameworks {
+				for _, t := range f.Tasks {
+					if IsTerminal(t.State) {
+						continue
+					}
+					labels := labeler.values(&f, &t)
+					ch <- constMetric(desc, get(t.Resources), labels[:len(labels)-1]...)
+				}
+			}
+		},
+	}
+}
+
+// statusMetric returns a gauge of the status timestamp, which is also used as
+// the sample timestamp if configured.
+func (opts StateOptions) statusMetric(desc *prometheus.Desc, s Status, labels []string) prometheus.Metric {
+	m := constMetric(desc, s.Timestamp, labels...)
+	if opts.StatusTimestamps {
+		m = prometheus.NewMetricWithTimestamp(s.time(), m)
+	}
+	return m
+}
+
+func (c *MasterStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	s, err := c.fetchState(ctx)
+	if err != nil {
+		log.Print(err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+	// Followers see leader changes too, so they're tracked even while only
+	// the leader is exported.
+	c.leaders.observe(s)
+	if c.leaderOnly && !s.IsLeader() {
+		return
+	}
+	c.leaders.Collect(ch)
+	// Task state changes are diffed before filtering, as tasks of frameworks
+	// dropped by the filter would otherwise be counted again once they're
+	// back, e.g. after a scheduler failover.
+	c.transitions.observe(s)
+	c.filter.apply(s)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for c, set := range c.metrics {
+		// Start from scratch, so series of slaves, frameworks and tasks
+		// which are gone aren't exported with their last value forever.
+		if v, ok := c.(*prometheus.GaugeVec); ok {
+			v.Reset()
+		}
+		set(s, c)
+		c.Collect(ch)
+	}
+	for _, m := range c.constMetrics {
+		m.collect(s, m.desc, ch)
+	}
+
+	c.transitions.Collect(ch)
+}
+
+// FetchState fetches the state from the master and applies the filter.
+func (c *MasterStateCollector) FetchState(ctx context.Context) (*State, error) {
+	s, err := c.fetchState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.filter.apply(s)
+	return s, nil
+}
+
+// fetchState fetches the state from the master without filtering it.
+func (c *MasterStateCollector) fetchState(ctx context.Context) (*State, error) {
+	u := strings.TrimSuffix(c.url, "/") + c.path
+	s := State{maxCompleted: c.filter.MaxCompleted}
+	if err := getJSON(ctx, c.Client, u, &s); err != nil {
+		return nil, fmt.Errorf("Error fetching %s: %s", u, err)
+	}
+	s.dropMalformedSlaves()
+	s.dedupeSlaves()
+	return &s, nil
+}
+
+// dropMalformedSlaves drops slaves whose resources couldn't be decoded, as
+// any value exported for them would be made up.
+func (st *State) dropMalformedSlaves() {
+	slaves := st.Slaves[:0]
+	for _, s := range st.Slaves {
+		if s.malformed != nil {
+			log.Printf("Skipping slave %s: %s", s.PID, s.malformed)
+			st.malformedSlaves++
+			continue
+		}
+		slaves = append(slaves, s)
+	}
+	st.Slaves = slaves
+}
+
+// dedupeSlaves reconciles slaves listed more than once, e.g. after
+// re-registering with a new PID, so their resources aren't counted twice.
+// Slaves with the same ID are merged into the active or otherwise the last
+// listed one, and inactive slaves are dropped if an active one runs on the
+// same host and port.
+func (st *State) dedupeSlaves() {
+	var (
+		slaves []Slave
+		byID   = map[string]int{}
+	)
+	for _, s := range st.Slaves {
+		i, ok := byID[s.ID]
+		switch {
+		case !ok:
+			byID[s.ID] = len(slaves)
+			slaves = append(slaves, s)
+		case s.Active || !slaves[i].Active:
+			slaves[i] = s
+		}
+	}
+
+	active := map[string]bool{}
+	for _, s := range slaves {
+		if s.Active {
+			active[s.address()] = true
+		}
+	}
+	st.Slaves = st.Slaves[:0]
+	for _, s := range slaves {
+		if !s.Active && active[s.address()] {
+			continue
+		}
+		st.Slaves = append(st.Slaves, s)
+	}
+}
+
+// free returns the resources of the slave not used by any task, which a
+// single task can be launched with.
+func (s Slave) free() Resources {
+	return Resources{
+		CPUs: math.Max(s.Total.CPUs-s.Used.CPUs, 0),
+		Mem:  math.Max(s.Total.Mem-s.Used.Mem, 0),
+		Disk: math.Max(s.Total.Disk-s.Used.Disk, 0),
+	}
+}
+
+// spread returns the IDs of the frameworks with running tasks on each slave
+// by slave ID.
+func (st *State) spread() map[string]map[string]bool {
+	frameworks := map[string]map[string]bool{}
+	for _, f := range st.Frameworks {
+		for id := range f.slaves() {
+			if frameworks[id] == nil {
+				frameworks[id] = map[string]bool{}
+			}
+			frameworks[id][f.ID] = true
+		}
+	}
+	return frameworks
+}
+
+// slaves returns the IDs of the slaves the framework has running tasks on.
+func (f Framework) slaves() map[string]bool {
+	slaves := map[string]bool{}
+	for _, t := range f.Tasks {
+		if !IsTerminal(t.State) {
+			slaves[t.SlaveID] = true
+		}
+	}
+	return slaves
+}
+
+// largestTasks returns the free resources of the active slaves with the most
+// free CPUs and the most free memory, ties broken by the other resource.
+// These are the largest tasks which can be launched, as tasks can't span
+// slaves however much capacity is free in total.
+func (st *State) largestTasks() (byCPUs, byMem Resources, ok bool) {
+	for _, s := range st.Slaves {
+		if !s.Active {
+			continue
+		}
+		f := s.free()
+		if !ok || f.CPUs > byCPUs.CPUs || f.CPUs == byCPUs.CPUs && f.Mem > byCPUs.Mem {
+			byCPUs = f
+		}
+		if !ok || f.Mem > byMem.Mem || f.Mem == byMem.Mem && f.CPUs > byMem.CPUs {
+			byMem = f
+		}
+		ok = true
+	}
+	return byCPUs, byMem, ok
+}
+
+// api returns the API the framework is connected with. Only frameworks
+// using the scheduler driver have a libprocess PID.
+func (f Framework) api() string {
+	if f.PID != "" {
+		return "driver"
+	}
+	return "http"
+}
+
+// frameworkCapabilities are the capabilities exported for every framework,
+// so frameworks lacking one can be found.
+var frameworkCapabilities = []string{
+	"GPU_RESOURCES",
+	"MULTI_ROLE",
+	"PARTITION_AWARE",
+	"REGION_AWARE",
+	"RESERVATION_REFINEMENT",
+	"REVOCABLE_RESOURCES",
+	"SHARED_RESOURCES",
+	"TASK_KILLING_STATE",
+}
+
+// capabilities returns whether the framework has each of the well-known
+// capabilities, and any other it registered with.
+func (f Framework) capabilities() map[string]bool {
+	caps := map[string]bool{}
+	for _, c := range frameworkCapabilities {
+		caps[c] = false
+	}
+	for _, c := range f.Capabilities {
+		caps[c] = true
+	}
+	return caps
+}
+
+// totalResources returns the resources of all slaves of the cluster, which
+// the allocator computes shares against.
+func (st *State) totalResources() (total Resources) {
+	for _, s := range st.Slaves {
+		total.CPUs += s.Total.CPUs
+		total.Mem += s.Total.Mem
+		total.Disk += s.Total.Disk
+	}
+	return total
+}
+
+// shares returns the share of total allocated to the framework by resource,
+// leaving out resources the cluster has none of.
+func (f Framework) shares(total Resources) map[string]float64 {
+	shares := map[string]float64{}
+	for resource, r := range map[string][2]float64{
+		"cpus": {f.Resources.CPUs, total.CPUs},
+		"mem":  {f.Resources.Mem, total.Mem},
+		"disk": {f.Resources.Disk, total.Disk},
+	} {
+		if r[1] > 0 {
+			shares[resource] = r[0] / r[1]
+		}
+	}
+	return shares
+}
+
+// faultDomain returns the region and zone of the slave, empty if it has no
+// fault domain.
+func (s Slave) faultDomain() (region, zone string) {
+	if s.Domain == nil {
+		return "", ""
+	}
+	return s.Domain.FaultDomain.Region.Name, s.Domain.FaultDomain.Zone.Name
+}
+
+// address returns the host and port the slave listens on.
+func (s Slave) address() string {
+	return net.JoinHostPort(s.Hostname, strconv.Itoa(s.port()))
+}
+
+func (c *MasterStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	for metric := range c.metrics {
+		metric.Describe(ch)
+	}
+	for _, m := range c.constMetrics {
+		ch <- m.desc
+	}
+	c.transitions.Describe(ch)
+	c.leaders.Describe(ch)
+}
+
+// IsLeader reports whether the state was served by the leading master.
+func (st *State) IsLeader() bool {
+	return st.PID != "" && st.PID == st.Leader
+}
+
+func (f StateFilter) apply(st *State) {
+	frameworks := st.Frameworks[:0]
+	for _, fw := range st.Frameworks {
+		if !fw.Active && !f.IncludeInactive {
+			continue
+		}
+		if f.CompletedRetention > 0 {
+			fw.Completed = f.recentTasks(fw.Completed, time.Now().Add(-f.CompletedRetention))
+		}
+		// Mesos appends tasks to the completed tasks as they complete, so
+		// the oldest ones are dropped.
+		// States fetched by the collector are already within the limit,
+		// unless the tasks were nested below executors.
+		if f.MaxCompleted > 0 && len(fw.Completed) > f.MaxCompleted {
+			skipped := len(fw.Completed) - f.MaxCompleted
+			fw.skippedCompleted += skipped
+			fw.Completed = fw.Completed[skipped:]
+		}
+		frameworks = append(frameworks, fw)
+	}
+	st.Frameworks = frameworks
+}
+
+func (f StateFilter) recentTasks(tasks []Task, since time.Time) []Task {
+	recent := tasks[:0]
+	for _, t := range tasks {
+		if s, ok := t.lastStatus(); ok && s.time().Before(since) {
+			continue
+		}
+		recent = append(recent, t)
+	}
+	return recent
+}
+
+// IsTerminal reports whether a task in this state won't ever change state
+// again.
+func IsTerminal(state string) bool {
+	switch state {
+	case "TASK_FINISHED", "TASK_FAILED", "TASK_KILLED", "TASK_LOST", "TASK_ERROR",
+		"TASK_DROPPED", "TASK_GONE", "TASK_GONE_BY_OPERATOR":
+		return true
+	}
+	return false
+}
+
+// lastStatus returns the most recent status of the task. Mesos orders
+// statuses from oldest to newest.
+func (t Task) lastStatus() (Status, bool) {
+	if len(t.Statuses) == 0 {
+		return Status{}, false
+	}
+	return t.Statuses[len(t.Statuses)-1], true
+}
+
+func (s Status) time() time.Time {
+	sec, frac := math.Modf(s.Timestamp)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// UnmarshalJSON decodes a slave, defaulting missing resource blocks to zero
+// and recording malformed ones instead of failing to decode the whole state.
+func (s *Slave) UnmarshalJSON(data []byte) error {
+	type plain Slave
+	var raw struct {
+		plain
+		Used       json.RawMessage `json:"used_resources"`
+		Unreserved json.RawMessage `json:"unreserved_resources"`
+		Total      json.RawMessage `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Slave(raw.plain)
+
+	for _, b := range []struct {
+		name string
+		data json.RawMessage
+		r    *Resources
+	}{
+		{"used_resources", raw.Used, &s.Used},
+		{"unreserved_resources", raw.Unreserved, &s.Unreserved},
+		{"resources", raw.Total, &s.Total},
+	} {
+		if len(b.data) == 0 || string(b.data) == "null" {
+			*b.r = Resources{missing: scalarResources}
+			continue
+		}
+		if err := json.Unmarshal(b.data, b.r); err != nil && s.malformed == nil {
+			s.malformed = fmt.Errorf("malformed %s: %s", b.name, err)
+		}
+	}
+	return nil
+}
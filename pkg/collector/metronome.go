@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metronomeTimeFormat is the format of the timestamps of Metronome.
+const metronomeTimeFormat = "2006-01-02T15:04:05.000-0700"
+
+type (
+	metronomeJob struct {
+		ID             string               `json:"id"`
+		ActiveRuns     []metronomeRun       `json:"activeRuns"`
+		HistorySummary *metronomeRunSummary `json:"historySummary"`
+	}
+
+	metronomeRunSummary struct {
+		SuccessCount  int    `json:"successCount"`
+		FailureCount  int    `json:"failureCount"`
+		LastSuccessAt string `json:"lastSuccessAt"`
+		LastFailureAt string `json:"lastFailureAt"`
+	}
+
+	metronomeRun struct {
+		ID     string          `json:"id"`
+		Status string          `json:"status"`
+		Tasks  []metronomeTask `json:"tasks"`
+	}
+
+	metronomeTask struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+
+	metronomeCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		lastSuccess *prometheus.Desc
+		lastFailure *prometheus.Desc
+		runs        *prometheus.Desc
+		activeRuns  *prometheus.Desc
+		taskInfo    *prometheus.Desc
+	}
+)
+
+// NewMetronomeCollector returns a collector of the jobs of the Metronome
+// running on url. Like with NewMarathonCollector, the tasks of active runs
+// are exported with their Mesos task ID to be joined with the task metrics.
+func NewMetronomeCollector(url string, opts Options) prometheus.Collector {
+	return &metronomeCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("metronome", opts),
+
+		lastSuccess: gaugeDesc(
+			"mesos_metronome_job_last_success_timestamp_seconds",
+			"Time the latest successful run of a job finished",
+			[]string{"job"}, nil,
+		),
+		lastFailure: gaugeDesc(
+			"mesos_metronome_job_last_failure_timestamp_seconds",
+			"Time the latest failed run of a job finished",
+			[]string{"job"}, nil,
+		),
+		runs: counterDesc(
+			"mesos_metronome_job_runs_total",
+			"Total number of finished runs of a job by result",
+			[]string{"job", "result"}, nil,
+		),
+		activeRuns: gaugeDesc(
+			"mesos_metronome_job_active_runs",
+			"Number of runs of a job in progress by status",
+			[]string{"job", "status"}, nil,
+		),
+		taskInfo: gaugeDesc(
+			"mesos_metronome_task_info",
+			"Metronome job run of a Mesos task, always 1",
+			[]string{"job", "run", "task", "state"}, nil,
+		),
+	}
+}
+
+func (c *metronomeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/v1/jobs?embed=activeRuns&embed=historySummary"
+	var jobs []metronomeJob
+	if err := getJSON(ctx, c.Client, u, &jobs); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for _, j := range jobs {
+		if s := j.HistorySummary; s != nil {
+			ch <- constMetric(c.runs, float64(s.SuccessCount), j.ID, "success")
+			ch <- constMetric(c.runs, float64(s.FailureCount), j.ID, "failure")
+			// Jobs which never succeeded or failed have no timestamp.
+			if t, err := time.Parse(metronomeTimeFormat, s.LastSuccessAt); err == nil {
+				ch <- constMetric(c.lastSuccess, float64(t.UnixNano())/1e9, j.ID)
+			}
+			if t, err := time.Parse(metronomeTimeFormat, s.LastFailureAt); err == nil {
+				ch <- constMetric(c.lastFailure, float64(t.UnixNano())/1e9, j.ID)
+			}
+		}
+
+		active := map[string]int{}
+		for _, r := range j.ActiveRuns {
+			active[r.Status]++
+			for _, t := range r.Tasks {
+				ch <- constMetric(c.taskInfo, 1, j.ID, r.ID, t.ID, t.Status)
+			}
+		}
+		for status, n := range active {
+			ch <- constMetric(c.activeRuns, float64(n), j.ID, status)
+		}
+	}
+}
+
+func (c *metronomeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.lastSuccess
+	ch <- c.lastFailure
+	ch <- c.runs
+	ch <- c.activeRuns
+	ch <- c.taskInfo
+}
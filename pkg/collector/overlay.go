@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// overlayInfo is a virtual network of the overlay module, whose subnet
+	// is split into subnets of prefix bits allocated to the agents.
+	overlayInfo struct {
+		Name   string `json:"name"`
+		Subnet string `json:"subnet"`
+		Prefix int    `json:"prefix"`
+	}
+
+	overlayAgent struct {
+		IP       string              `json:"ip"`
+		Overlays []overlayAgentState `json:"overlays"`
+	}
+
+	overlayAgentState struct {
+		Info    overlayInfo `json:"info"`
+		Subnet  string      `json:"subnet"`
+		Backend struct {
+			VXLAN *struct {
+				VNI      int    `json:"vni"`
+				VTEPIP   string `json:"vtep_ip"`
+				VTEPName string `json:"vtep_name"`
+			} `json:"vxlan"`
+		} `json:"backend"`
+		State struct {
+			Status string `json:"status"`
+		} `json:"state"`
+	}
+
+	overlayMasterCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		subnets   *prometheus.Desc
+		allocated *prometheus.Desc
+		status    *prometheus.Desc
+	}
+
+	overlayAgentCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		status *prometheus.Desc
+		vxlan  *prometheus.Desc
+	}
+)
+
+// NewOverlayMasterCollector returns a collector of the overlay networks of
+// the master running on url, as managed by the overlay network module.
+func NewOverlayMasterCollector(url string, opts Options) prometheus.Collector {
+	return &overlayMasterCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("overlay_master", opts),
+
+		subnets: gaugeDesc(
+			"mesos_overlay_subnets",
+			"Number of agent subnets an overlay network can be split into",
+			[]string{"overlay"}, nil,
+		),
+		allocated: gaugeDesc(
+			"mesos_overlay_subnets_allocated",
+			"Number of agent subnets of an overlay network allocated to agents",
+			[]string{"overlay"}, nil,
+		),
+		status: gaugeDesc(
+			"mesos_overlay_agent_status",
+			"Status of an overlay network on an agent as known to the master, always 1",
+			[]string{"agent_ip", "overlay", "subnet", "status"}, nil,
+		),
+	}
+}
+
+func (c *overlayMasterCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/overlay-master/state"
+	var s struct {
+		Network struct {
+			Overlays []overlayInfo `json:"overlays"`
+		} `json:"network"`
+		Agents []overlayAgent `json:"agents"`
+	}
+	if err := getJSON(ctx, c.Client, u, &s); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	allocated := map[string]int{}
+	for _, a := range s.Agents {
+		for _, o := range a.Overlays {
+			if o.Subnet != "" {
+				allocated[o.Info.Name]++
+			}
+			ch <- constMetric(c.status, 1, a.IP, o.Info.Name, o.Subnet, o.State.Status)
+		}
+	}
+	for _, o := range s.Network.Overlays {
+		if n, ok := o.subnets(); ok {
+			ch <- constMetric(c.subnets, n, o.Name)
+		}
+		ch <- constMetric(c.allocated, float64(allocated[o.Name]), o.Name)
+	}
+}
+
+// subnets returns the number of agent subnets of the overlay network.
+func (o overlayInfo) subnets() (float64, bool) {
+	_, n, err := net.ParseCIDR(o.Subnet)
+	if err != nil {
+		return 0, false
+	}
+	ones, _ := n.Mask.Size()
+	if o.Prefix < ones {
+		return 0, false
+	}
+	return float64(uint64(1) << uint(o.Prefix-ones)), true
+}
+
+func (c *overlayMasterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.subnets
+	ch <- c.allocated
+	ch <- c.status
+}
+
+// NewOverlayAgentCollector returns a collector of the overlay networks
+// configured on the slave running on url by the overlay network module.
+func NewOverlayAgentCollector(url string, opts Options) prometheus.Collector {
+	return &overlayAgentCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("overlay_agent", opts),
+
+		status: gaugeDesc(
+			"mesos_overlay_status",
+			"Status of an overlay network on the slave, always 1",
+			[]string{"overlay", "subnet", "status"}, nil,
+		),
+		vxlan: gaugeDesc(
+			"mesos_overlay_vxlan_info",
+			"VXLAN backend of an overlay network on the slave, always 1",
+			[]string{"overlay", "vni", "vtep_ip", "vtep_name"}, nil,
+		),
+	}
+}
+
+func (c *overlayAgentCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/overlay-agent/overlay"
+	var a overlayAgent
+	if err := getJSON(ctx, c.Client, u, &a); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for _, o := range a.Overlays {
+		ch <- constMetric(c.status, 1, o.Info.Name, o.Subnet, o.State.Status)
+		if v := o.Backend.VXLAN; v != nil {
+			ch <- constMetric(c.vxlan, 1, o.Info.Name, strconv.Itoa(v.VNI), v.VTEPIP, v.VTEPName)
+		}
+	}
+}
+
+func (c *overlayAgentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.status
+	ch <- c.vxlan
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// pid is a libprocess process ID, e.g. slave(1)@10.0.0.1:5051. IPv6 hosts
+// are enclosed in brackets, e.g. slave(1)@[2001:db8::1]:5051, though some
+// versions of libprocess leave them out.
+type pid struct {
+	id   string
+	host string
+	port int
+}
+
+func parsePID(s string) (pid, error) {
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return pid{}, fmt.Errorf("invalid PID %q: no @", s)
+	}
+	id, addr := s[:i], s[i+1:]
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// Unbracketed IPv6 hosts are followed by the port after the last
+		// colon.
+		j := strings.LastIndex(addr, ":")
+		if j < 0 || net.ParseIP(addr[:j]) == nil {
+			return pid{}, fmt.Errorf("invalid PID %q: %s", s, err)
+		}
+		host, port = addr[:j], addr[j+1:]
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return pid{}, fmt.Errorf("invalid port of PID %q: %s", s, err)
+	}
+	return pid{id: id, host: host, port: p}, nil
+}
+
+// port returns the port the slave listens on, taken from its PID if the
+// master doesn't report it, or 0 if it isn't known.
+func (s Slave) port() int {
+	if s.Port != 0 {
+		return s.Port
+	}
+	p, _ := parsePID(s.PID)
+	return p.port
+}
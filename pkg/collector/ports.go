@@ -0,0 +1,106 @@
+package collector
+
+import "strconv"
+
+type (
+	// Discovery is the service discovery info of a task.
+	Discovery struct {
+		Name  string `json:"name,omitempty" mesos:"optional"`
+		Ports struct {
+			Ports []DiscoveryPort `json:"ports,omitempty" mesos:"optional"`
+		} `json:"ports"`
+	}
+
+	// DiscoveryPort is a port a task is reachable on.
+	DiscoveryPort struct {
+		Number   int    `json:"number"`
+		Name     string `json:"name,omitempty" mesos:"optional"`
+		Protocol string `json:"protocol,omitempty" mesos:"optional"`
+	}
+
+	// Container is the container of a task, as far as port mappings are
+	// concerned.
+	Container struct {
+		Docker *struct {
+			PortMappings []PortMapping `json:"port_mappings,omitempty" mesos:"optional"`
+		} `json:"docker,omitempty" mesos:"optional"`
+		NetworkInfos []struct {
+			PortMappings []PortMapping `json:"port_mappings,omitempty" mesos:"optional"`
+		} `json:"network_infos,omitempty" mesos:"optional"`
+	}
+
+	// PortMapping maps a port of the slave to a port of a container.
+	PortMapping struct {
+		HostPort      int    `json:"host_port"`
+		ContainerPort int    `json:"container_port"`
+		Protocol      string `json:"protocol,omitempty" mesos:"optional"`
+	}
+
+	// taskPort is a port of a task as exported, with ports unknown left
+	// empty.
+	taskPort struct {
+		name, protocol, hostPort, containerPort string
+	}
+)
+
+// ports returns the ports of the task. Port mappings of the Docker
+// containerizer and of networks are matched with the discovery ports by
+// either of their ports, as frameworks announce the host port for bridged
+// and the container port for routable networks. Discovery ports without a
+// mapping are ports of the slave, as used by tasks on the host network.
+func (t Task) ports() []taskPort {
+	var discovery []DiscoveryPort
+	if t.Discovery != nil {
+		discovery = t.Discovery.Ports.Ports
+	}
+	var mappings []PortMapping
+	if c := t.Container; c != nil {
+		if c.Docker != nil {
+			mappings = append(mappings, c.Docker.PortMappings...)
+		}
+		for _, n := range c.NetworkInfos {
+			mappings = append(mappings, n.PortMappings...)
+		}
+	}
+
+	var ports []taskPort
+	mapped := map[int]bool{}
+	for _, m := range mappings {
+		p := taskPort{protocol: m.Protocol, hostPort: portString(m.HostPort), containerPort: portString(m.ContainerPort)}
+		for i, d := range discovery {
+			if (d.Number == m.HostPort || d.Number == m.ContainerPort) && !mapped[i] {
+				mapped[i] = true
+				p.name = d.Name
+				if p.protocol == "" {
+					p.protocol = d.Protocol
+				}
+				break
+			}
+		}
+		ports = append(ports, p)
+	}
+	for i, d := range discovery {
+		if !mapped[i] {
+			ports = append(ports, taskPort{name: d.Name, protocol: d.Protocol, hostPort: portString(d.Number)})
+		}
+	}
+	// Identical ports would be exported as duplicate series.
+	unique := ports[:0]
+	seen := map[taskPort]bool{}
+	for _, p := range ports {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// portString formats a port, where 0 means it isn't known, e.g. a host port
+// assigned by the network.
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
@@ -0,0 +1,29 @@
+package collector
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RegisterMaster registers the collectors of the master running on url with
+// r, i.e. those of its metrics snapshot and its state.
+func RegisterMaster(r prometheus.Registerer, url string, opts StateOptions) (*MasterStateCollector, error) {
+	state := NewMasterStateCollector(url, opts)
+	if err := register(r, NewMasterCollector(url, opts.Options), state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// RegisterSlave registers the collectors of the slave running on url with r,
+// i.e. those of its metrics snapshot, executor statistics and sandbox
+// retention. Within a Scrape, the latter two share one fetch of the state.
+func RegisterSlave(r prometheus.Registerer, url string, opts Options) error {
+	return register(r, NewSlaveCollector(url, opts), NewSlaveMonitorCollector(url, opts), NewSlaveGCCollector(url, opts))
+}
+
+func register(r prometheus.Registerer, cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
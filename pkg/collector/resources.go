@@ -0,0 +1,326 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Resources are the resources of a slave, framework or task. They are
+// decoded from the flat resource objects of the master state, or from the
+// arrays of single resources of its *_resources_full fields, which also
+// fill the totals of the scalar and port resources.
+type Resources struct {
+	CPUs  float64 `json:"cpus"`
+	Disk  float64 `json:"disk"`
+	Mem   float64 `json:"mem"`
+	Ports Ranges  `json:"ports,omitempty" mesos:"optional"`
+
+	// Items are the single resources, with their roles, reservations,
+	// revocability and disk sources. Only arrays report them.
+	Items []Resource `json:"-"`
+
+	// missing lists the scalar resources absent from the response,
+	// which are zero.
+	missing []string
+	// full is set if the resources were decoded from an array, which
+	// they are encoded as again.
+	full bool
+}
+
+type (
+	// Ranges are the port ranges of a ports resource.
+	Ranges []PortRange
+	// PortRange is the first and last port of a range.
+	PortRange [2]uint64
+)
+
+// scalarResources are the resources every slave and task is expected to
+// report.
+var scalarResources = []string{"cpus", "disk", "mem"}
+
+func (r *Resources) UnmarshalJSON(data []byte) error {
+	if d := bytes.TrimSpace(data); len(d) > 0 && d[0] == '[' {
+		*r = Resources{full: true}
+		if err := json.Unmarshal(d, &r.Items); err != nil {
+			return err
+		}
+		for _, item := range r.Items {
+			switch item.Name {
+			case "cpus":
+				r.CPUs += item.value()
+			case "disk":
+				r.Disk += item.value()
+			case "mem":
+				r.Mem += item.value()
+			case "ports":
+				if item.Ranges != nil {
+					for _, rng := range item.Ranges.Range {
+						r.Ports = append(r.Ports, PortRange{rng.Begin, rng.End})
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	type plain Resources
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	r.Items, r.missing, r.full = nil, nil, false
+	for _, name := range scalarResources {
+		if _, ok := fields[name]; !ok {
+			r.missing = append(r.missing, name)
+		}
+	}
+	return nil
+}
+
+func (r Resources) MarshalJSON() ([]byte, error) {
+	if r.full {
+		if r.Items == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(r.Items)
+	}
+	type plain Resources
+	return json.Marshal(plain(r))
+}
+
+func (rs *Ranges) UnmarshalJSON(data []byte) (err error) {
+	if data = bytes.Trim(data, `[]"`); len(data) == 0 {
+		return nil
+	}
+
+	var rng PortRange
+	for _, r := range bytes.Split(data, []byte(",")) {
+		ps := bytes.SplitN(r, []byte("-"), 2)
+		if len(ps) != 2 {
+			return fmt.Errorf("bad range: %s", r)
+		}
+
+		rng[0], err = strconv.ParseUint(string(bytes.TrimSpace(ps[0])), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		rng[1], err = strconv.ParseUint(string(bytes.TrimSpace(ps[1])), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		*rs = append(*rs, rng)
+	}
+
+	return nil
+}
+
+func (rs Ranges) size() uint64 {
+	var sz uint64
+	for i := range rs {
+		sz += rs[i].size()
+	}
+	return sz
+}
+
+func (r PortRange) size() uint64 {
+	return 1 + r[1] - r[0]
+}
+
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r[0], r[1])
+}
+
+// Resource is a single item of Resources, i.e. the JSON form of the
+// Resource protobuf message.
+type Resource struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Scalar *struct {
+		Value float64 `json:"value"`
+	} `json:"scalar,omitempty" mesos:"optional"`
+	Ranges *struct {
+		Range []struct {
+			Begin uint64 `json:"begin"`
+			End   uint64 `json:"end"`
+		} `json:"range"`
+	} `json:"ranges,omitempty" mesos:"optional"`
+	Set *struct {
+		Item []string `json:"item"`
+	} `json:"set,omitempty" mesos:"optional"`
+
+	// Role and Reservation are set by Mesos versions before reservation
+	// refinement, Reservations by later ones.
+	Role         string        `json:"role,omitempty" mesos:"optional"`
+	Reservation  *Reservation  `json:"reservation,omitempty" mesos:"optional"`
+	Reservations []Reservation `json:"reservations,omitempty" mesos:"optional"`
+
+	Revocable *struct{} `json:"revocable,omitempty" mesos:"optional"`
+	Disk      *DiskInfo `json:"disk,omitempty" mesos:"optional"`
+}
+
+// Reservation is a reservation of a resource for a role.
+type Reservation struct {
+	Type      string `json:"type,omitempty" mesos:"optional"`
+	Role      string `json:"role,omitempty" mesos:"optional"`
+	Principal string `json:"principal,omitempty" mesos:"optional"`
+}
+
+// DiskInfo tells where the disk space of a disk resource comes from.
+type DiskInfo struct {
+	Source *struct {
+		Type string `json:"type"`
+	} `json:"source,omitempty" mesos:"optional"`
+}
+
+// resourceKey are the dimensions resources are exported by.
+type resourceKey struct {
+	name, role, principal, revocable, diskSource string
+}
+
+// resourceKeyLabels are the label names of resourceKey.labelValues.
+var resourceKeyLabels = []string{"resource", "role", "principal", "revocable", "disk_source"}
+
+func (k resourceKey) labelValues() []string {
+	return []string{k.name, k.role, k.principal, k.revocable, k.diskSource}
+}
+
+func (r Resource) key() resourceKey {
+	k := resourceKey{
+		name:      r.Name,
+		role:      "*",
+		revocable: strconv.FormatBool(r.Revocable != nil),
+	}
+	if r.Role != "" {
+		k.role = r.Role
+	}
+	if r.Reservation != nil {
+		k.principal = r.Reservation.Principal
+	}
+	// With refined reservations the last reservation is the one applying.
+	if n := len(r.Reservations); n > 0 {
+		k.role, k.principal = r.Reservations[n-1].Role, r.Reservations[n-1].Principal
+	}
+	if r.Name == "disk" {
+		k.diskSource = "root"
+		if r.Disk != nil && r.Disk.Source != nil {
+			k.diskSource = strings.ToLower(r.Disk.Source.Type)
+		}
+	}
+	return k
+}
+
+// value returns the amount of a scalar resource and the number of elements
+// of range and set resources.
+func (r Resource) value() float64 {
+	switch {
+	case r.Scalar != nil:
+		return r.Scalar.Value
+	case r.Ranges != nil:
+		var n uint64
+		for _, rng := range r.Ranges.Range {
+			n += 1 + rng.End - rng.Begin
+		}
+		return float64(n)
+	case r.Set != nil:
+		return float64(len(r.Set.Item))
+	}
+	return 0
+}
+
+// sums sums up the items of r by their dimensions.
+func (r Resources) sums() map[resourceKey]float64 {
+	sums := map[resourceKey]float64{}
+	for _, item := range r.Items {
+		sums[item.key()] += item.value()
+	}
+	return sums
+}
+
+// resourcesByType returns the resources of s as reported by the
+// *_resources_full fields, by type (used, unreserved, reserved, offered).
+func (s *Slave) resourcesByType() map[string]Resources {
+	var reserved Resources
+	for _, rs := range s.ReservedFull {
+		reserved.Items = append(reserved.Items, rs.Items...)
+	}
+	byType := map[string]Resources{"reserved": reserved}
+	for typ, rs := range map[string]*Resources{
+		"used":       s.UsedFull,
+		"unreserved": s.UnreservedFull,
+		"offered":    s.OfferedFull,
+	} {
+		if rs != nil {
+			byType[typ] = *rs
+		} else {
+			byType[typ] = Resources{}
+		}
+	}
+	return byType
+}
+
+// ResourceUnit is the unit of a custom resource, which Mesos only knows as
+// an opaque number, e.g. Unit "bits_per_second" with Scale 1e6 for a
+// bandwidth resource in Mbps.
+type ResourceUnit struct {
+	// Unit is the suffix of the family name, in the base unit the amounts
+	// are converted to.
+	Unit string `json:"unit"`
+	// Scale is what amounts are multiplied by to convert them to Unit, 1 if
+	// zero.
+	Scale float64 `json:"scale"`
+}
+
+// ResourceMetricName returns the name of the family exporting resource in
+// unit u.
+func ResourceMetricName(resource string, u ResourceUnit) string {
+	return "mesos_slave_resource_" + invalidLabelChars.ReplaceAllString(resource, "_") + "_" + u.Unit
+}
+
+// resourceUnitMetrics returns the metrics exporting the resources with a
+// declared unit like mesos_slave_resources, but scaled to their unit.
+func resourceUnitMetrics(units map[string]ResourceUnit) []stateMetric {
+	var names []string
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var metrics []stateMetric
+	for _, name := range names {
+		name, u := name, units[name]
+		scale := u.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		metrics = append(metrics, stateMetric{
+			gaugeDesc(
+				ResourceMetricName(name, u),
+				fmt.Sprintf("%s resources of a slave in %s by type (used, unreserved, reserved, offered), role, reservation principal, revocability and disk source", name, strings.ReplaceAll(u.Unit, "_", " ")),
+				append([]string{"slave", "type"}, resourceKeyLabels[1:]...), nil,
+			),
+			func(st *State, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+				for _, s := range st.Slaves {
+					for typ, rs := range s.resourcesByType() {
+						for k, v := range rs.sums() {
+							if k.name == name {
+								ch <- constMetric(desc, v*scale, append([]string{s.ID, typ}, k.labelValues()[1:]...)...)
+							}
+						}
+					}
+				}
+			},
+		})
+	}
+	return metrics
+}
@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Problems reported by schema diagnostics.
+const (
+	schemaMissing  = "missing"
+	schemaType     = "type_mismatch"
+	schemaUnknown  = "unknown"
+	schemaArrayKey = "[]"
+)
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	resourcesType   = reflect.TypeOf(Resources{})
+	resourceType    = reflect.TypeOf(Resource{})
+)
+
+// schemaDiagnostics, if set, compares every decoded Mesos response with the
+// types it is decoded into and exports the differences.
+var schemaDiagnostics *schemaCollector
+
+type schemaIssue struct {
+	field, problem string
+}
+
+// schemaCollector exports the schema issues found in the latest response of
+// every endpoint.
+type schemaCollector struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	issues map[string]map[schemaIssue]bool // endpoint -> issues
+}
+
+// EnableSchemaDiagnostics makes all collectors compare the Mesos responses
+// they decode with the expected schema, and returns the collector exposing
+// missing, unknown and mistyped fields. It must be called before collecting.
+func EnableSchemaDiagnostics() prometheus.Collector {
+	if schemaDiagnostics == nil {
+		schemaDiagnostics = newSchemaCollector()
+	}
+	return schemaDiagnostics
+}
+
+func newSchemaCollector() *schemaCollector {
+	return &schemaCollector{
+		desc: gaugeDesc(
+			"mesos_collector_schema_issues",
+			"Fields of Mesos responses which were missing, unknown or had an unexpected type in the latest response, always 1.",
+			[]string{"endpoint", "field", "problem"}, nil,
+		),
+		issues: map[string]map[schemaIssue]bool{},
+	}
+}
+
+// check records the differences between the decoded JSON document data,
+// fetched from rawurl, and the type of v. Issues not seen in the previous
+// response of the endpoint are logged.
+func (c *schemaCollector) check(rawurl string, data []byte, v interface{}) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return
+	}
+	endpoint := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		endpoint = u.Path
+	}
+
+	issues := map[schemaIssue]bool{}
+	checkSchema(doc, reflect.TypeOf(v), "", issues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range issues {
+		if !c.issues[endpoint][i] {
+			log.Printf("Schema issue in %s: %s field %s", endpoint, i.problem, i.field)
+		}
+	}
+	c.issues[endpoint] = issues
+}
+
+func (c *schemaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *schemaCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for endpoint, issues := range c.issues {
+		for i := range issues {
+			ch <- constMetric(c.desc, 1, endpoint, i.field, i.problem)
+		}
+	}
+}
+
+// checkSchema walks doc and t in parallel, recording fields of t not tagged
+// mesos:"optional" missing in doc, fields whose JSON type doesn't fit and,
+// in objects missing some of their fields, the fields unknown to t, which
+// are likely their new names. Other unknown fields are simply not read by
+// the exporter. Array elements are reported under field[].
+func checkSchema(doc interface{}, t reflect.Type, path string, issues map[schemaIssue]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if doc == nil {
+		return
+	}
+	// Types decoding themselves may accept several representations, so
+	// only structs are walked into.
+	if t.Kind() != reflect.Struct && reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	// Resources are also reported as arrays of single resources.
+	if arr, ok := doc.([]interface{}); ok && t == resourcesType {
+		for _, v := range arr {
+			checkSchema(v, resourceType, path+schemaArrayKey, issues)
+		}
+		return
+	}
+
+	mismatch := func() { issues[schemaIssue{path, schemaType}] = true }
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		known := map[string]bool{}
+		missing := false
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := fieldName(f)
+			if name == "" {
+				continue
+			}
+			known[name] = true
+			v, ok := obj[name]
+			if !ok {
+				if f.Tag.Get("mesos") != "optional" {
+					issues[schemaIssue{joinField(path, name), schemaMissing}] = true
+					missing = true
+				}
+				continue
+			}
+			checkSchema(v, f.Type, joinField(path, name), issues)
+		}
+		if !missing {
+			return
+		}
+		for name := range obj {
+			if !known[name] {
+				issues[schemaIssue{joinField(path, name), schemaUnknown}] = true
+			}
+		}
+
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, v := range obj {
+			checkSchema(v, t.Elem(), joinField(path, "*"), issues)
+		}
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, v := range arr {
+			checkSchema(v, t.Elem(), path+schemaArrayKey, issues)
+		}
+
+	case reflect.String:
+		if _, ok := doc.(string); !ok {
+			mismatch()
+		}
+	case reflect.Bool:
+		if _, ok := doc.(bool); !ok {
+			mismatch()
+		}
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16,
+		reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64:
+		if _, ok := doc.(float64); !ok {
+			mismatch()
+		}
+	}
+}
+
+func fieldName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	name := tag
+	if i := strings.Index(tag, ","); i != -1 {
+		name = tag[:i]
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
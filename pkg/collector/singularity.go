@@ -0,0 +1,184 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	singularityState struct {
+		ActiveTasks              int     `json:"activeTasks"`
+		LaunchingTasks           int     `json:"launchingTasks"`
+		ScheduledTasks           int     `json:"scheduledTasks"`
+		CleaningTasks            int     `json:"cleaningTasks"`
+		LateTasks                int     `json:"lateTasks"`
+		FutureTasks              int     `json:"futureTasks"`
+		ActiveRequests           int     `json:"activeRequests"`
+		PausedRequests           int     `json:"pausedRequests"`
+		CooldownRequests         int     `json:"cooldownRequests"`
+		PendingRequests          int     `json:"pendingRequests"`
+		CleaningRequests         int     `json:"cleaningRequests"`
+		OverProvisionedRequests  int     `json:"overProvisionedRequests"`
+		UnderProvisionedRequests int     `json:"underProvisionedRequests"`
+		MaxTaskLag               float64 `json:"maxTaskLag"`
+	}
+
+	singularityRequest struct {
+		Request struct {
+			ID string `json:"id"`
+		} `json:"request"`
+		State              string `json:"state"`
+		PendingDeployState *struct {
+			CurrentDeployState string `json:"currentDeployState"`
+		} `json:"pendingDeployState"`
+	}
+
+	singularityTask struct {
+		TaskID struct {
+			ID        string `json:"id"`
+			RequestID string `json:"requestId"`
+			DeployID  string `json:"deployId"`
+		} `json:"taskId"`
+	}
+
+	singularityCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		tasks         *prometheus.Desc
+		requests      *prometheus.Desc
+		maxTaskLag    *prometheus.Desc
+		requestState  *prometheus.Desc
+		pendingDeploy *prometheus.Desc
+		taskInfo      *prometheus.Desc
+	}
+)
+
+// NewSingularityCollector returns a collector of the requests and tasks of
+// the Singularity whose API is served below url, e.g.
+// http://singularity:7099/singularity. The active tasks are exported with
+// their Mesos task ID to be joined with the task metrics.
+func NewSingularityCollector(url string, opts Options) prometheus.Collector {
+	return &singularityCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("singularity", opts),
+
+		tasks: gaugeDesc(
+			"mesos_singularity_tasks",
+			"Number of tasks known to Singularity by state, e.g. cleaning or late",
+			[]string{"state"}, nil,
+		),
+		requests: gaugeDesc(
+			"mesos_singularity_requests",
+			"Number of requests known to Singularity by state, e.g. paused or underprovisioned",
+			[]string{"state"}, nil,
+		),
+		maxTaskLag: gaugeDesc(
+			"mesos_singularity_max_task_lag_seconds",
+			"Time the most delayed task is late to be launched",
+			nil, nil,
+		),
+		requestState: gaugeDesc(
+			"mesos_singularity_request_state",
+			"State of a request, always 1",
+			[]string{"request", "state"}, nil,
+		),
+		pendingDeploy: gaugeDesc(
+			"mesos_singularity_request_pending_deploy",
+			"State of the pending deploy of a request, always 1",
+			[]string{"request", "state"}, nil,
+		),
+		taskInfo: gaugeDesc(
+			"mesos_singularity_task_info",
+			"Singularity request and deploy of an active Mesos task, always 1",
+			[]string{"request", "deploy", "task"}, nil,
+		),
+	}
+}
+
+func (c *singularityCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	var (
+		state    singularityState
+		requests []singularityRequest
+		tasks    []singularityTask
+	)
+	for _, e := range []struct {
+		path string
+		v    interface{}
+	}{
+		{"/api/state", &state},
+		{"/api/requests", &requests},
+		{"/api/tasks/active", &tasks},
+	} {
+		if !c.fetch(ctx, e.path, e.v) {
+			c.up.collect(ch, false)
+			return
+		}
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	for s, n := range map[string]int{
+		"active":    state.ActiveTasks,
+		"launching": state.LaunchingTasks,
+		"scheduled": state.ScheduledTasks,
+		"cleaning":  state.CleaningTasks,
+		"late":      state.LateTasks,
+		"future":    state.FutureTasks,
+	} {
+		ch <- constMetric(c.tasks, float64(n), s)
+	}
+	for s, n := range map[string]int{
+		"active":           state.ActiveRequests,
+		"paused":           state.PausedRequests,
+		"cooldown":         state.CooldownRequests,
+		"pending":          state.PendingRequests,
+		"cleaning":         state.CleaningRequests,
+		"overprovisioned":  state.OverProvisionedRequests,
+		"underprovisioned": state.UnderProvisionedRequests,
+	} {
+		ch <- constMetric(c.requests, float64(n), s)
+	}
+	// Singularity reports the lag in milliseconds.
+	ch <- constMetric(c.maxTaskLag, state.MaxTaskLag/1000)
+
+	for _, r := range requests {
+		ch <- constMetric(c.requestState, 1, r.Request.ID, r.State)
+		if d := r.PendingDeployState; d != nil {
+			ch <- constMetric(c.pendingDeploy, 1, r.Request.ID, d.CurrentDeployState)
+		}
+	}
+	for _, t := range tasks {
+		ch <- constMetric(c.taskInfo, 1, t.TaskID.RequestID, t.TaskID.DeployID, t.TaskID.ID)
+	}
+}
+
+func (c *singularityCollector) fetch(ctx context.Context, path string, v interface{}) bool {
+	u := strings.TrimSuffix(c.url, "/") + path
+	if err := getJSON(ctx, c.Client, u, v); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		return false
+	}
+	return true
+}
+
+func (c *singularityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.tasks
+	ch <- c.requests
+	ch <- c.maxTaskLag
+	ch <- c.requestState
+	ch <- c.pendingDeploy
+	ch <- c.taskInfo
+}
@@ -1,15 +1,15 @@
-package main
+package collector
 
 import (
-	"time"
-
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func newSlaveCollector(url string, timeout time.Duration) *metricCollector {
+// NewSlaveCollector returns a collector of the metrics snapshot of the slave
+// running on url.
+func NewSlaveCollector(url string, opts Options) prometheus.Collector {
 	metrics := map[prometheus.Collector]func(metricMap, prometheus.Collector) error{
 		// CPU/Disk/Mem resources in free/used
-		gauge("slave", "cpus", "Current CPU resources in cluster.", "type"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "allocation_cpus", "Current CPU resources in cluster (fractional).", "type"): func(m metricMap, c prometheus.Collector) error {
 			total, ok := m["slave/cpus_total"]
 			used, ok := m["slave/cpus_used"]
 			if !ok {
@@ -19,7 +19,7 @@ func newSlaveCollector(url string, timeout time.Duration) *metricCollector {
 			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
 			return nil
 		},
-		gauge("slave", "cpus_revocable", "Current revocable CPU resources in cluster.", "type"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "allocation_cpus_revocable", "Current revocable CPU resources in cluster (fractional).", "type"): func(m metricMap, c prometheus.Collector) error {
 			total, ok := m["slave/cpus_revocable_total"]
 			used, ok := m["slave/cpus_revocable_used"]
 			if !ok {
@@ -29,44 +29,44 @@ func newSlaveCollector(url string, timeout time.Duration) *metricCollector {
 			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
 			return nil
 		},
-		gauge("slave", "mem", "Current memory resources in cluster.", "type"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "allocation_mem_bytes", "Current memory resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
 			total, ok := m["slave/mem_total"]
 			used, ok := m["slave/mem_used"]
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.GaugeVec).WithLabelValues("free").Set(total - used)
-			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
 			return nil
 		},
-		gauge("slave", "mem_revocable", "Current revocable memory resources in cluster.", "type"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "allocation_mem_revocable_bytes", "Current revocable memory resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
 			total, ok := m["slave/mem_revocable_total"]
 			used, ok := m["slave/mem_revocable_used"]
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.GaugeVec).WithLabelValues("free").Set(total - used)
-			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
 			return nil
 		},
-		gauge("slave", "disk", "Current disk resources in cluster.", "type"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "allocation_disk_bytes", "Current disk resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
 			total, ok := m["slave/disk_total"]
 			used, ok := m["slave/disk_used"]
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.GaugeVec).WithLabelValues("free").Set(total - used)
-			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
 			return nil
 		},
-		gauge("slave", "disk_revocable", "Current disk resources in cluster.", "type"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "allocation_disk_revocable_bytes", "Current revocable disk resources in cluster in bytes.", "type"): func(m metricMap, c prometheus.Collector) error {
 			total, ok := m["slave/disk_revocable_total"]
 			used, ok := m["slave/disk_revocable_used"]
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.GaugeVec).WithLabelValues("free").Set(total - used)
-			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used)
+			c.(*prometheus.GaugeVec).WithLabelValues("free").Set((total - used) * Megabytes)
+			c.(*prometheus.GaugeVec).WithLabelValues("used").Set(used * Megabytes)
 			return nil
 		},
 
@@ -124,17 +124,20 @@ func newSlaveCollector(url string, timeout time.Duration) *metricCollector {
 			c.(prometheus.Gauge).Set(active)
 			return nil
 		},
-		prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "mesos",
-			Subsystem: "slave",
-			Name:      "executors_terminated",
-			Help:      "Total number of executor terminations.",
-		}): func(m metricMap, c prometheus.Collector) error {
+		counter("slave", "executors_terminated_total", "Total number of executor terminations."): func(m metricMap, c prometheus.Collector) error {
 			terminated, ok := m["slave/executors_terminated"]
 			if !ok {
 				return notFoundInMap
 			}
-			c.(prometheus.Counter).Set(terminated)
+			c.(*settableCounterVec).Set(terminated)
+			return nil
+		},
+		counter("slave", "executors_preempted_total", "Total number of executors destroyed by the QoS controller to free resources for non-revocable tasks."): func(m metricMap, c prometheus.Collector) error {
+			preempted, ok := m["slave/executors_preempted"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(preempted)
 			return nil
 		},
 
@@ -148,23 +151,43 @@ func newSlaveCollector(url string, timeout time.Duration) *metricCollector {
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.CounterVec).WithLabelValues("errored").Set(errored)
-			c.(*prometheus.CounterVec).WithLabelValues("failed").Set(failed)
-			c.(*prometheus.CounterVec).WithLabelValues("finished").Set(finished)
-			c.(*prometheus.CounterVec).WithLabelValues("killed").Set(killed)
-			c.(*prometheus.CounterVec).WithLabelValues("lost").Set(lost)
+			c.(*settableCounterVec).Set(errored, "errored")
+			c.(*settableCounterVec).Set(failed, "failed")
+			c.(*settableCounterVec).Set(finished, "finished")
+			c.(*settableCounterVec).Set(killed, "killed")
+			c.(*settableCounterVec).Set(lost, "lost")
 			return nil
 		},
-		counter("slave", "task_states_current", "Current number of tasks by state.", "state"): func(m metricMap, c prometheus.Collector) error {
+		gauge("slave", "task_states_current", "Current number of tasks by state.", "state"): func(m metricMap, c prometheus.Collector) error {
 			running, ok := m["slave/tasks_running"]
 			staging, ok := m["slave/tasks_staging"]
 			starting, ok := m["slave/tasks_starting"]
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.CounterVec).WithLabelValues("running").Set(running)
-			c.(*prometheus.CounterVec).WithLabelValues("staging").Set(staging)
-			c.(*prometheus.CounterVec).WithLabelValues("starting").Set(starting)
+			c.(*prometheus.GaugeVec).WithLabelValues("running").Set(running)
+			c.(*prometheus.GaugeVec).WithLabelValues("staging").Set(staging)
+			c.(*prometheus.GaugeVec).WithLabelValues("starting").Set(starting)
+			return nil
+		},
+
+		// Slave stats about sandbox garbage collection
+		gauge("slave", "gc_path_removals_pending", "Current number of sandboxes and other paths scheduled for garbage collection."): func(m metricMap, c prometheus.Collector) error {
+			pending, ok := m["gc/path_removals_pending"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*prometheus.GaugeVec).WithLabelValues().Set(pending)
+			return nil
+		},
+		counter("slave", "gc_path_removals_total", "Total number of paths removed by garbage collection by result.", "result"): func(m metricMap, c prometheus.Collector) error {
+			succeeded, ok := m["gc/path_removals_succeeded"]
+			failed, ok := m["gc/path_removals_failed"]
+			if !ok {
+				return notFoundInMap
+			}
+			c.(*settableCounterVec).Set(succeeded, "succeeded")
+			c.(*settableCounterVec).Set(failed, "failed")
 			return nil
 		},
 
@@ -181,13 +204,13 @@ func newSlaveCollector(url string, timeout time.Duration) *metricCollector {
 			if !ok {
 				return notFoundInMap
 			}
-			c.(*prometheus.CounterVec).WithLabelValues("framework", "valid").Set(frameworkMessagesValid)
-			c.(*prometheus.CounterVec).WithLabelValues("framework", "invalid").Set(frameworkMessagesInvalid)
-			c.(*prometheus.CounterVec).WithLabelValues("status", "valid").Set(statusUpdateValid)
-			c.(*prometheus.CounterVec).WithLabelValues("status", "invalid").Set(statusUpdateInvalid)
+			c.(*settableCounterVec).Set(frameworkMessagesValid, "framework", "valid")
+			c.(*settableCounterVec).Set(frameworkMessagesInvalid, "framework", "invalid")
+			c.(*settableCounterVec).Set(statusUpdateValid, "status", "valid")
+			c.(*settableCounterVec).Set(statusUpdateInvalid, "status", "invalid")
 
 			return nil
 		},
 	}
-	return newMetricCollector(url, timeout, metrics)
+	return newMetricCollector("slave", url, opts, metrics)
 }
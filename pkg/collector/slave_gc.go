@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	slaveGCState struct {
+		Flags struct {
+			GCDelay        string `json:"gc_delay"`
+			GCDiskHeadroom string `json:"gc_disk_headroom"`
+		} `json:"flags"`
+		Frameworks          []slaveGCFramework `json:"frameworks"`
+		CompletedFrameworks []slaveGCFramework `json:"completed_frameworks"`
+	}
+
+	slaveGCFramework struct {
+		CompletedExecutors []slaveGCExecutor `json:"completed_executors"`
+	}
+
+	slaveGCExecutor struct {
+		Resources      Resources `json:"resources"`
+		CompletedTasks []Task    `json:"completed_tasks"`
+	}
+
+	// slaveGCCollector exports the sandboxes of completed executors kept
+	// by a slave until they are garbage collected.
+	slaveGCCollector struct {
+		*http.Client
+		url string
+		up  collectorUp
+
+		delay       *prometheus.Desc
+		headroom    *prometheus.Desc
+		sandboxes   *prometheus.Desc
+		disk        *prometheus.Desc
+		nextRemoval *prometheus.Desc
+	}
+)
+
+// NewSlaveGCCollector returns a collector of the sandbox retention of the
+// slave running on url, derived from its state.
+func NewSlaveGCCollector(url string, opts Options) prometheus.Collector {
+	return &slaveGCCollector{
+		Client: opts.client(),
+		url:    url,
+		up:     newCollectorUp("slave_gc", opts),
+
+		delay: gaugeDesc(
+			"mesos_slave_gc_delay_seconds",
+			"Maximum time sandboxes of completed executors are kept, the --gc_delay of the slave",
+			nil, nil,
+		),
+		headroom: gaugeDesc(
+			"mesos_slave_gc_disk_headroom_ratio",
+			"Fraction of disk space the slave tries to keep free by shortening the gc delay, the --gc_disk_headroom of the slave",
+			nil, nil,
+		),
+		sandboxes: gaugeDesc(
+			"mesos_slave_completed_sandboxes",
+			"Number of sandboxes of completed executors kept by the slave",
+			nil, nil,
+		),
+		disk: gaugeDesc(
+			"mesos_slave_completed_sandboxes_disk_limit_bytes",
+			"Disk space allocated to the completed executors whose sandboxes are kept, the disk they can use if disk quotas are enforced",
+			nil, nil,
+		),
+		nextRemoval: gaugeDesc(
+			"mesos_slave_gc_next_removal_seconds",
+			"Time until the oldest sandbox is removed at the latest, the gc delay gets shorter as the disk fills up",
+			nil, nil,
+		),
+	}
+}
+
+func (c *slaveGCCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/state"
+	var s slaveGCState
+	if err := getSharedJSON(ctx, c.Client, u, &s); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	delay, err := parseMesosDuration(s.Flags.GCDelay)
+	if err != nil {
+		log.Printf("Error parsing gc_delay of %s: %s", c.url, err)
+		c.up.errors.Inc()
+	} else {
+		ch <- constMetric(c.delay, delay.Seconds())
+	}
+	if headroom, err := strconv.ParseFloat(s.Flags.GCDiskHeadroom, 64); err == nil {
+		ch <- constMetric(c.headroom, headroom)
+	}
+
+	var (
+		sandboxes int
+		disk      float64
+		oldest    = math.Inf(1)
+	)
+	for _, f := range append(s.Frameworks, s.CompletedFrameworks...) {
+		for _, e := range f.CompletedExecutors {
+			sandboxes++
+			disk += e.Resources.Disk * Megabytes
+			if finished := e.finished(); finished > 0 && finished < oldest {
+				oldest = finished
+			}
+		}
+	}
+	ch <- constMetric(c.sandboxes, float64(sandboxes))
+	ch <- constMetric(c.disk, disk)
+	if err == nil && !math.IsInf(oldest, 1) {
+		next := oldest + delay.Seconds() - float64(time.Now().UnixNano())/1e9
+		ch <- constMetric(c.nextRemoval, math.Max(next, 0))
+	}
+}
+
+// finished returns the time of the latest status update of the tasks of the
+// executor, i.e. about when it completed, or 0 if there is none.
+func (e slaveGCExecutor) finished() float64 {
+	var t float64
+	for _, task := range e.CompletedTasks {
+		if s, ok := task.lastStatus(); ok && s.Timestamp > t {
+			t = s.Timestamp
+		}
+	}
+	return t
+}
+
+func (c *slaveGCCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.delay
+	ch <- c.headroom
+	ch <- c.sandboxes
+	ch <- c.disk
+	ch <- c.nextRemoval
+}
+
+// mesosDurationUnits are the units of durations in Mesos flags.
+var mesosDurationUnits = map[string]time.Duration{
+	"ns":    time.Nanosecond,
+	"us":    time.Microsecond,
+	"ms":    time.Millisecond,
+	"secs":  time.Second,
+	"mins":  time.Minute,
+	"hrs":   time.Hour,
+	"days":  24 * time.Hour,
+	"weeks": 7 * 24 * time.Hour,
+}
+
+// parseMesosDuration parses a duration as formatted by Mesos, e.g. 1weeks or
+// 2.5hrs.
+func parseMesosDuration(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	unit, ok := mesosDurationUnits[s[i:]]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit of duration %q", s)
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(v * float64(unit)), nil
+}
@@ -0,0 +1,492 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	executor struct {
+		ID          string      `json:"executor_id"`
+		Name        string      `json:"executor_name"`
+		FrameworkID string      `json:"framework_id"`
+		Source      string      `json:"source"`
+		Statistics  *statistics `json:"statistics"`
+	}
+
+	statistics struct {
+		Timestamp float64 `json:"timestamp"`
+
+		CpusLimit             float64 `json:"cpus_limit"`
+		CpusSystemTimeSecs    float64 `json:"cpus_system_time_secs"`
+		CpusUserTimeSecs      float64 `json:"cpus_user_time_secs"`
+		CpusThrottledTimeSecs float64 `json:"cpus_throttled_time_secs" mesos:"optional"`
+
+		MemLimitBytes float64 `json:"mem_limit_bytes"`
+		MemRssBytes   float64 `json:"mem_rss_bytes"`
+
+		// Process counts are only reported by the cgroups isolators.
+		Processes *float64 `json:"processes" mesos:"optional"`
+		Threads   *float64 `json:"threads" mesos:"optional"`
+
+		// Network statistics require the port mapping isolator.
+		NetRxBytes   float64 `json:"net_rx_bytes" mesos:"optional"`
+		NetRxDropped float64 `json:"net_rx_dropped" mesos:"optional"`
+		NetRxErrors  float64 `json:"net_rx_errors" mesos:"optional"`
+		NetRxPackets float64 `json:"net_rx_packets" mesos:"optional"`
+		NetTxBytes   float64 `json:"net_tx_bytes" mesos:"optional"`
+		NetTxDropped float64 `json:"net_tx_dropped" mesos:"optional"`
+		NetTxErrors  float64 `json:"net_tx_errors" mesos:"optional"`
+		NetTxPackets float64 `json:"net_tx_packets" mesos:"optional"`
+
+		// Socket statistics require the port mapping isolator with
+		// --network_enable_socket_statistics_summary or _details.
+		NetTCPActiveConnections   *float64 `json:"net_tcp_active_connections" mesos:"optional"`
+		NetTCPTimeWaitConnections *float64 `json:"net_tcp_time_wait_connections" mesos:"optional"`
+		NetTCPRTTMicrosecsP50     *float64 `json:"net_tcp_rtt_microsecs_p50" mesos:"optional"`
+		NetTCPRTTMicrosecsP90     *float64 `json:"net_tcp_rtt_microsecs_p90" mesos:"optional"`
+		NetTCPRTTMicrosecsP95     *float64 `json:"net_tcp_rtt_microsecs_p95" mesos:"optional"`
+		NetTCPRTTMicrosecsP99     *float64 `json:"net_tcp_rtt_microsecs_p99" mesos:"optional"`
+
+		// Traffic control statistics require the port mapping isolator
+		// with egress rate limiting.
+		NetTrafficControl []struct {
+			ID         string  `json:"id"`
+			Backlog    float64 `json:"backlog"`
+			Drops      float64 `json:"drops"`
+			Overlimits float64 `json:"overlimits"`
+		} `json:"net_traffic_control_statistics" mesos:"optional"`
+
+		// Perf statistics require the perf isolator.
+		Perf *perfStatistics `json:"perf" mesos:"optional"`
+
+		// Block IO statistics require the cgroups/blkio isolator.
+		Blkio *blkioStatistics `json:"blkio_statistics" mesos:"optional"`
+	}
+
+	// blkioStatistics are the block IO statistics of a container by device.
+	// The throttling policy accounts all IO, the CFQ policy only IO of
+	// devices using the CFQ scheduler.
+	blkioStatistics struct {
+		CFQ        []blkioDeviceStatistics `json:"cfq" mesos:"optional"`
+		Throttling []blkioDeviceStatistics `json:"throttling" mesos:"optional"`
+	}
+
+	// blkioDeviceStatistics are the statistics of a device, or of all
+	// devices if Device isn't set.
+	blkioDeviceStatistics struct {
+		Device *struct {
+			Major int `json:"major_number"`
+			Minor int `json:"minor_number"`
+		} `json:"device" mesos:"optional"`
+		Serviced      []blkioValue `json:"io_serviced" mesos:"optional"`
+		ServiceBytes  []blkioValue `json:"io_service_bytes" mesos:"optional"`
+		ServiceTimeNs []blkioValue `json:"io_service_time" mesos:"optional"`
+		WaitTimeNs    []blkioValue `json:"io_wait_time" mesos:"optional"`
+	}
+
+	blkioValue struct {
+		Op    string  `json:"op"`
+		Value float64 `json:"value"`
+	}
+
+	// perfStatistics are hardware events counted during the latest sample
+	// of the perf isolator, which lasts Duration seconds.
+	perfStatistics struct {
+		Duration        float64 `json:"duration"`
+		Cycles          float64 `json:"cycles"`
+		Instructions    float64 `json:"instructions"`
+		CacheReferences float64 `json:"cache_references"`
+		CacheMisses     float64 `json:"cache_misses"`
+	}
+
+	// slaveExecutorState is the part of the state of a slave telling how
+	// its executors are run.
+	slaveExecutorState struct {
+		Frameworks []struct {
+			ID        string              `json:"id"`
+			Executors []slaveExecutorInfo `json:"executors"`
+		} `json:"frameworks"`
+	}
+
+	slaveExecutorInfo struct {
+		ID   string `json:"id"`
+		Type string `json:"type" mesos:"optional"`
+		// Container is the ID of the container of the executor.
+		Container string          `json:"container"`
+		Tasks     []slaveTaskInfo `json:"tasks"`
+		Queued    []slaveTaskInfo `json:"queued_tasks"`
+	}
+
+	slaveTaskInfo struct {
+		ID        string         `json:"id"`
+		Container *containerType `json:"container" mesos:"optional"`
+	}
+
+	containerType struct {
+		Type string `json:"type"`
+	}
+
+	// executorKind is how an executor is run, exported as the
+	// container_id, containerizer and executor_type labels.
+	executorKind struct {
+		containerID, containerizer, executorType string
+	}
+
+	slaveCollector struct {
+		*http.Client
+		url     string
+		up      collectorUp
+		metrics map[*prometheus.Desc]func(*statistics) float64
+		perf    map[*prometheus.Desc]func(*perfStatistics) float64
+
+		processes *prometheus.Desc
+		threads   *prometheus.Desc
+
+		tcpConnections *prometheus.Desc
+		tcpRTT         *prometheus.Desc
+		tcBacklog      *prometheus.Desc
+		tcDrops        *prometheus.Desc
+		tcOverlimits   *prometheus.Desc
+
+		blkioServiced     *prometheus.Desc
+		blkioServiceBytes *prometheus.Desc
+		blkioServiceTime  *prometheus.Desc
+		blkioWaitTime     *prometheus.Desc
+	}
+)
+
+// NewSlaveMonitorCollector returns a collector of the resource statistics of
+// the executors of the slave running on url.
+func NewSlaveMonitorCollector(url string, opts Options) prometheus.Collector {
+	labels := []string{"id", "framework_id", "source", "container_id", "containerizer", "executor_type"}
+
+	return &slaveCollector{
+		Client: opts.client(),
+		up:     newCollectorUp("slave_monitor", opts),
+		url:    url,
+		metrics: map[*prometheus.Desc]func(*statistics) float64{
+			// CPU
+			gaugeDesc(
+				"mesos_executor_cpus_limit",
+				"Current limit of CPUs for task",
+				labels, nil,
+			): func(s *statistics) float64 { return s.CpusLimit },
+			counterDesc(
+				"mesos_executor_cpu_system_seconds_total",
+				"Total system CPU seconds",
+				labels, nil,
+			): func(s *statistics) float64 { return s.CpusSystemTimeSecs },
+			counterDesc(
+				"mesos_executor_cpu_user_seconds_total",
+				"Total user CPU seconds",
+				labels, nil,
+			): func(s *statistics) float64 { return s.CpusUserTimeSecs },
+			counterDesc(
+				"mesos_executor_cpu_throttled_seconds_total",
+				"Total time CPU was throttled",
+				labels, nil,
+			): func(s *statistics) float64 { return s.CpusThrottledTimeSecs },
+
+			// Memory
+			gaugeDesc(
+				"mesos_executor_mem_limit_bytes",
+				"Current memory limit in bytes",
+				labels, nil,
+			): func(s *statistics) float64 { return s.MemLimitBytes },
+			gaugeDesc(
+				"mesos_executor_mem_rss_bytes",
+				"Current rss memory usage",
+				labels, nil,
+			): func(s *statistics) float64 { return s.MemRssBytes },
+
+			// Network
+			// - RX
+			counterDesc(
+				"mesos_executor_network_receive_bytes_total",
+				"Total bytes received",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetRxBytes },
+			counterDesc(
+				"mesos_executor_network_receive_dropped_total",
+				"Total packets dropped while receiving",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetRxDropped },
+			counterDesc(
+				"mesos_executor_network_receive_errors_total",
+				"Total errors while receiving",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetRxErrors },
+			counterDesc(
+				"mesos_executor_network_receive_packets_total",
+				"Total packets received",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetRxPackets },
+			// - TX
+			counterDesc(
+				"mesos_executor_network_transmit_bytes_total",
+				"Total bytes transmitted",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetTxBytes },
+			counterDesc(
+				"mesos_executor_network_transmit_dropped_total",
+				"Total packets dropped while transmitting",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetTxDropped },
+			counterDesc(
+				"mesos_executor_network_transmit_errors_total",
+				"Total errors while transmitting",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetTxErrors },
+			counterDesc(
+				"mesos_executor_network_transmit_packets_total",
+				"Total packets transmitted",
+				labels, nil,
+			): func(s *statistics) float64 { return s.NetTxPackets },
+		},
+		// Perf samples are exported as they are, since they don't add up.
+		perf: map[*prometheus.Desc]func(*perfStatistics) float64{
+			gaugeDesc(
+				"mesos_executor_perf_sample_duration_seconds",
+				"Duration of the latest perf sample",
+				labels, nil,
+			): func(p *perfStatistics) float64 { return p.Duration },
+			gaugeDesc(
+				"mesos_executor_perf_cycles",
+				"CPU cycles during the latest perf sample",
+				labels, nil,
+			): func(p *perfStatistics) float64 { return p.Cycles },
+			gaugeDesc(
+				"mesos_executor_perf_instructions",
+				"Instructions during the latest perf sample",
+				labels, nil,
+			): func(p *perfStatistics) float64 { return p.Instructions },
+			gaugeDesc(
+				"mesos_executor_perf_cache_references",
+				"Cache references during the latest perf sample",
+				labels, nil,
+			): func(p *perfStatistics) float64 { return p.CacheReferences },
+			gaugeDesc(
+				"mesos_executor_perf_cache_misses",
+				"Cache misses during the latest perf sample",
+				labels, nil,
+			): func(p *perfStatistics) float64 { return p.CacheMisses },
+		},
+
+		processes: gaugeDesc(
+			"mesos_executor_processes",
+			"Current number of processes in the container",
+			labels, nil,
+		),
+		threads: gaugeDesc(
+			"mesos_executor_threads",
+			"Current number of threads in the container",
+			labels, nil,
+		),
+
+		tcpConnections: gaugeDesc(
+			"mesos_executor_network_tcp_connections",
+			"Current number of TCP connections by state (active, time_wait)",
+			append(labels, "state"), nil,
+		),
+		tcpRTT: gaugeDesc(
+			"mesos_executor_network_tcp_rtt_seconds",
+			"Round trip time of TCP connections by percentile",
+			append(labels, "percentile"), nil,
+		),
+		tcBacklog: gaugeDesc(
+			"mesos_executor_network_traffic_control_backlog_packets",
+			"Current number of packets queued by a traffic control queueing discipline",
+			append(labels, "qdisc"), nil,
+		),
+		tcDrops: counterDesc(
+			"mesos_executor_network_traffic_control_dropped_total",
+			"Total packets dropped by a traffic control queueing discipline",
+			append(labels, "qdisc"), nil,
+		),
+		tcOverlimits: counterDesc(
+			"mesos_executor_network_traffic_control_overlimits_total",
+			"Total packets exceeding the rate limit of a traffic control queueing discipline",
+			append(labels, "qdisc"), nil,
+		),
+
+		blkioServiced: counterDesc(
+			"mesos_executor_blkio_serviced_total",
+			"Total block IO operations by device and operation (read, write)",
+			append(labels, "device", "op"), nil,
+		),
+		blkioServiceBytes: counterDesc(
+			"mesos_executor_blkio_service_bytes_total",
+			"Total bytes of block IO by device and operation (read, write)",
+			append(labels, "device", "op"), nil,
+		),
+		blkioServiceTime: counterDesc(
+			"mesos_executor_blkio_service_seconds_total",
+			"Total time block IO was serviced by devices using the CFQ scheduler by device and operation (read, write)",
+			append(labels, "device", "op"), nil,
+		),
+		blkioWaitTime: counterDesc(
+			"mesos_executor_blkio_wait_seconds_total",
+			"Total time block IO waited in the queue of devices using the CFQ scheduler by device and operation (read, write)",
+			append(labels, "device", "op"), nil,
+		),
+	}
+}
+
+func (c *slaveCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/monitor/statistics"
+	stats := []executor{}
+	if err := getSharedJSON(ctx, c.Client, u, &stats); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+	// The state only adds the kind labels, which are left empty if it can't
+	// be fetched.
+	u = strings.TrimSuffix(c.url, "/") + "/state"
+	var st slaveExecutorState
+	if err := getSharedJSON(ctx, c.Client, u, &st); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+	}
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+	kinds := map[[2]string]executorKind{}
+	for _, f := range st.Frameworks {
+		for _, e := range f.Executors {
+			kinds[[2]string{f.ID, e.ID}] = e.kind()
+		}
+	}
+	for _, exec := range stats {
+		// Executors which just terminated may be gone from the state.
+		kind := kinds[[2]string{exec.FrameworkID, exec.ID}]
+		labels := []string{exec.ID, exec.FrameworkID, exec.Source, kind.containerID, kind.containerizer, kind.executorType}
+		for desc, get := range c.metrics {
+			ch <- constMetric(desc, get(exec.Statistics), labels...)
+		}
+		if p := exec.Statistics.Perf; p != nil {
+			for desc, get := range c.perf {
+				ch <- constMetric(desc, get(p), labels...)
+			}
+		}
+		if p := exec.Statistics.Processes; p != nil {
+			ch <- constMetric(c.processes, *p, labels...)
+		}
+		if t := exec.Statistics.Threads; t != nil {
+			ch <- constMetric(c.threads, *t, labels...)
+		}
+		if b := exec.Statistics.Blkio; b != nil {
+			c.collectBlkio(ch, b, labels)
+		}
+		c.collectSockets(ch, exec.Statistics, labels)
+	}
+}
+
+// collectSockets exports the socket and traffic control statistics of the
+// port mapping isolator which the slave reported for an executor.
+func (c *slaveCollector) collectSockets(ch chan<- prometheus.Metric, s *statistics, labels []string) {
+	with := func(label string) []string {
+		return append(append([]string{}, labels...), label)
+	}
+	for state, v := range map[string]*float64{
+		"active":    s.NetTCPActiveConnections,
+		"time_wait": s.NetTCPTimeWaitConnections,
+	} {
+		if v != nil {
+			ch <- constMetric(c.tcpConnections, *v, with(state)...)
+		}
+	}
+	// The quantile label is reserved for summaries, which need a count and
+	// sum of all observations.
+	for percentile, v := range map[string]*float64{
+		"50": s.NetTCPRTTMicrosecsP50,
+		"90": s.NetTCPRTTMicrosecsP90,
+		"95": s.NetTCPRTTMicrosecsP95,
+		"99": s.NetTCPRTTMicrosecsP99,
+	} {
+		if v != nil {
+			ch <- constMetric(c.tcpRTT, *v/1e6, with(percentile)...)
+		}
+	}
+	for _, tc := range s.NetTrafficControl {
+		ch <- constMetric(c.tcBacklog, tc.Backlog, with(tc.ID)...)
+		ch <- constMetric(c.tcDrops, tc.Drops, with(tc.ID)...)
+		ch <- constMetric(c.tcOverlimits, tc.Overlimits, with(tc.ID)...)
+	}
+}
+
+// collectBlkio exports the block IO statistics of an executor per device.
+// Totals of all devices and of all operations are left out, they can be
+// summed up.
+func (c *slaveCollector) collectBlkio(ch chan<- prometheus.Metric, b *blkioStatistics, labels []string) {
+	export := func(desc *prometheus.Desc, stats []blkioDeviceStatistics, values func(blkioDeviceStatistics) []blkioValue, scale float64) {
+		for _, d := range stats {
+			if d.Device == nil {
+				continue
+			}
+			device := fmt.Sprintf("%d:%d", d.Device.Major, d.Device.Minor)
+			for _, v := range values(d) {
+				switch v.Op {
+				case "READ", "WRITE":
+					ch <- constMetric(desc, v.Value*scale,
+						append(labels, device, strings.ToLower(v.Op))...)
+				}
+			}
+		}
+	}
+	export(c.blkioServiced, b.Throttling, func(d blkioDeviceStatistics) []blkioValue { return d.Serviced }, 1)
+	export(c.blkioServiceBytes, b.Throttling, func(d blkioDeviceStatistics) []blkioValue { return d.ServiceBytes }, 1)
+	export(c.blkioServiceTime, b.CFQ, func(d blkioDeviceStatistics) []blkioValue { return d.ServiceTimeNs }, 1e-9)
+	export(c.blkioWaitTime, b.CFQ, func(d blkioDeviceStatistics) []blkioValue { return d.WaitTimeNs }, 1e-9)
+}
+
+// kind returns how the executor is run. The command executor, also used for
+// Docker containers, runs a single task with the ID of the executor. The
+// slave doesn't report the container info of executors, so the containerizer
+// is taken from their tasks, where tasks without container info run in the
+// Mesos containerizer.
+func (e slaveExecutorInfo) kind() executorKind {
+	tasks := append(append([]slaveTaskInfo(nil), e.Tasks...), e.Queued...)
+	k := executorKind{containerID: e.Container, containerizer: "mesos", executorType: "custom"}
+	if len(tasks) > 0 && tasks[0].Container != nil && tasks[0].Container.Type == "DOCKER" {
+		k.containerizer = "docker"
+	}
+	switch {
+	case e.Type == "DEFAULT":
+		k.executorType = "default"
+	case len(tasks) == 1 && tasks[0].ID == e.ID:
+		k.executorType = "command"
+	}
+	return k
+}
+
+func (c *slaveCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	for metric := range c.metrics {
+		ch <- metric
+	}
+	for metric := range c.perf {
+		ch <- metric
+	}
+	ch <- c.processes
+	ch <- c.threads
+	ch <- c.tcpConnections
+	ch <- c.tcpRTT
+	ch <- c.tcBacklog
+	ch <- c.tcDrops
+	ch <- c.tcOverlimits
+	ch <- c.blkioServiced
+	ch <- c.blkioServiceBytes
+	ch <- c.blkioServiceTime
+	ch <- c.blkioWaitTime
+}
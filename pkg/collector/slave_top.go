@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// slaveTopCollector exports the executors of a slave using the most CPU
+	// and memory, so heavy hitters are found without exporting all
+	// executors.
+	slaveTopCollector struct {
+		*http.Client
+		url string
+		n   int
+		up  collectorUp
+
+		cpus *prometheus.Desc
+		mem  *prometheus.Desc
+
+		mu sync.Mutex
+		// cpuTimes are the CPU seconds of the executors seen in the
+		// previous collection, to compute their CPU usage from.
+		cpuTimes map[[2]string]cpuTime
+	}
+
+	cpuTime struct {
+		seconds, timestamp float64
+	}
+
+	executorUsage struct {
+		exec  executor
+		value float64
+	}
+)
+
+// NewSlaveTopCollector returns a collector of the n executors with the
+// highest CPU and memory usage of the slave running on url. CPU usage is
+// averaged between collections, so only executors seen before are ranked.
+func NewSlaveTopCollector(url string, opts Options, n int) prometheus.Collector {
+	labels := []string{"id", "framework_id", "source"}
+	return &slaveTopCollector{
+		Client: opts.client(),
+		url:    url,
+		n:      n,
+		up:     newCollectorUp("slave_top", opts),
+
+		cpus: gaugeDesc(
+			"mesos_slave_top_executor_cpus_used",
+			"CPUs used since the previous scrape by the executors using the most CPU",
+			labels, nil,
+		),
+		mem: gaugeDesc(
+			"mesos_slave_top_executor_mem_rss_bytes",
+			"Resident memory of the executors using the most memory",
+			labels, nil,
+		),
+		cpuTimes: map[[2]string]cpuTime{},
+	}
+}
+
+func (c *slaveTopCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, span := c.up.start()
+	defer span.End()
+
+	u := strings.TrimSuffix(c.url, "/") + "/monitor/statistics"
+	stats := []executor{}
+	if err := getSharedJSON(ctx, c.Client, u, &stats); err != nil {
+		log.Printf("Error fetching %s: %s", u, err)
+		c.up.errors.Inc()
+		c.up.collect(ch, false)
+		return
+	}
+	c.up.collect(ch, true)
+
+	_, build := tracer.Start(ctx, "build")
+	defer build.End()
+
+	c.mu.Lock()
+	var cpus, mem []executorUsage
+	cpuTimes := map[[2]string]cpuTime{}
+	for _, e := range stats {
+		if e.Statistics == nil {
+			continue
+		}
+		key := [2]string{e.FrameworkID, e.ID}
+		now := cpuTime{e.Statistics.CpusUserTimeSecs + e.Statistics.CpusSystemTimeSecs, e.Statistics.Timestamp}
+		cpuTimes[key] = now
+		if prev, ok := c.cpuTimes[key]; ok && now.timestamp > prev.timestamp {
+			cpus = append(cpus, executorUsage{e, (now.seconds - prev.seconds) / (now.timestamp - prev.timestamp)})
+		}
+		mem = append(mem, executorUsage{e, e.Statistics.MemRssBytes})
+	}
+	c.cpuTimes = cpuTimes
+	c.mu.Unlock()
+
+	for desc, usage := range map[*prometheus.Desc][]executorUsage{c.cpus: cpus, c.mem: mem} {
+		for _, u := range topUsage(usage, c.n) {
+			ch <- constMetric(desc, u.value, u.exec.ID, u.exec.FrameworkID, u.exec.Source)
+		}
+	}
+}
+
+// topUsage returns the n highest usages, ties broken by executor ID so the
+// same executors are exported in consecutive collections.
+func topUsage(usage []executorUsage, n int) []executorUsage {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].value != usage[j].value {
+			return usage[i].value > usage[j].value
+		}
+		return usage[i].exec.ID < usage[j].exec.ID
+	})
+	if len(usage) > n {
+		usage = usage[:n]
+	}
+	return usage
+}
+
+func (c *slaveTopCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up.desc
+	ch <- c.cpus
+	ch <- c.mem
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// streamDecoder is implemented by types which decode themselves from the
+// tokens of a response as they are read, instead of from the whole response.
+type streamDecoder interface {
+	decodeStream(*json.Decoder) error
+}
+
+// decodeStream decodes the state field by field. If maxCompleted is set,
+// only the most recent completed tasks of each framework are kept while
+// they are decoded, so the others are never held in memory.
+func (st *State) decodeStream(dec *json.Decoder) error {
+	err := decodeObject(dec, reflect.ValueOf(st).Elem(), func(key string) (bool, error) {
+		if key != "frameworks" {
+			return false, nil
+		}
+		return true, decodeArray(dec, func() error {
+			var f Framework
+			if err := st.decodeFramework(dec, &f); err != nil {
+				return err
+			}
+			st.Frameworks = append(st.Frameworks, f)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	st.normalize()
+	return nil
+}
+
+func (st *State) decodeFramework(dec *json.Decoder, f *Framework) error {
+	return decodeObject(dec, reflect.ValueOf(f).Elem(), func(key string) (bool, error) {
+		if key != "completed_tasks" || st.maxCompleted <= 0 {
+			return false, nil
+		}
+		// Mesos appends tasks as they complete, so the ring of the latest
+		// tasks drops the oldest ones.
+		var ring []Task
+		next := 0
+		err := decodeArray(dec, func() error {
+			var t Task
+			if err := dec.Decode(&t); err != nil {
+				return err
+			}
+			if len(ring) < st.maxCompleted {
+				ring = append(ring, t)
+				return nil
+			}
+			ring[next] = t
+			next = (next + 1) % len(ring)
+			f.skippedCompleted++
+			return nil
+		})
+		f.Completed = append(append(make([]Task, 0, len(ring)), ring[next:]...), ring[:next]...)
+		return true, err
+	})
+}
+
+// decodeObject decodes the JSON object at the position of dec into the
+// struct v, field by field by their JSON names. Keys custom reports to
+// handle are left to it, values of unknown keys are skipped.
+func decodeObject(dec *json.Decoder, v reflect.Value, custom func(key string) (bool, error)) error {
+	if null, err := expectDelim(dec, '{'); null || err != nil {
+		return err
+	}
+	fields := map[string]reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		if name := fieldName(v.Type().Field(i)); name != "" {
+			fields[name] = v.Field(i)
+		}
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := t.(string)
+		handled, err := custom(key)
+		if err != nil {
+			return err
+		}
+		if handled {
+			continue
+		}
+		if f, ok := fields[key]; ok {
+			err = dec.Decode(f.Addr().Interface())
+		} else {
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// decodeArray calls decode for every element of the JSON array at the
+// position of dec.
+func decodeArray(dec *json.Decoder, decode func() error) error {
+	if null, err := expectDelim(dec, '['); null || err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := decode(); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// expectDelim reads the opening delimiter of an object or array, and reports
+// whether the value is null instead.
+func expectDelim(dec *json.Decoder, delim json.Delim) (null bool, err error) {
+	t, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if t == nil {
+		return true, nil
+	}
+	if t != delim {
+		return false, fmt.Errorf("expected %s, got %v", delim, t)
+	}
+	return false, nil
+}
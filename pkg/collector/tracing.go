@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of collectors. Unless a tracer provider is set
+// with otel.SetTracerProvider, spans aren't recorded.
+var tracer = otel.Tracer("github.com/mesosphere/mesos-exporter")
+
+// currentContext is the context of the running scrape, which collectors
+// derive their spans from, as the Collector interface doesn't pass one.
+type currentContext struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+func (c *currentContext) set(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ctx = ctx
+}
+
+func (c *currentContext) get() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+// Scrape runs f, which is expected to gather the collectors of i, with ctx as
+// the context of their requests to Mesos. Spans of the collectors become part
+// of the trace of ctx and requests are bounded by its deadline. Scrapes of the
+// same Internal mustn't overlap. Endpoints of a node polled by several of its
+// collectors are only fetched once per scrape.
+func (i *Internal) Scrape(ctx context.Context, f func()) {
+	i = i.orDefault()
+	ctx = context.WithValue(ctx, sharedResponsesKey{}, &sharedResponses{responses: map[string]*sharedResponse{}})
+	i.scrape.set(ctx)
+	defer i.scrape.set(context.Background())
+	f()
+}
+
+// Scrape runs f as a scrape of the collectors of the default Internal.
+func Scrape(ctx context.Context, f func()) {
+	(*Internal)(nil).Scrape(ctx, f)
+}
+
+type internalKey struct{}
+
+// internalFrom returns the Internal of the collection ctx belongs to.
+func internalFrom(ctx context.Context) *Internal {
+	i, _ := ctx.Value(internalKey{}).(*Internal)
+	return i.orDefault()
+}
+
+// start starts the span of the collector within the running scrape and
+// counts the collection.
+func (u collectorUp) start() (context.Context, trace.Span) {
+	u.collects.WithLabelValues(u.name).Inc()
+	ctx := context.WithValue(u.scrape.get(), internalKey{}, u.Internal)
+	return tracer.Start(ctx, "collect", trace.WithAttributes(attribute.String("collector", u.name)))
+}
+
+// endSpan records err, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
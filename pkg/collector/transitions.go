@@ -0,0 +1,282 @@
+package collector
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// taskTransitions counts tasks entering a terminal state by diffing the task
+// states of consecutive scrapes. Tasks already terminated when first seen are
+// only counted if they weren't there yet in the first scrape, so a restart of
+// the exporter doesn't count all completed tasks known to the master again.
+//
+// If a file is given, the counts and task states are saved to it after every
+// observation and restored on startup, so counters keep increasing across
+// restarts and tasks finishing in between are counted.
+//
+// The launch latency and run time of tasks observed starting and finishing
+// are tracked by histograms, with the task ID and slave hostname as
+// exemplar. They aren't persisted.
+//
+// If events is set, it's called with the state changes of each observation.
+// Errors saving the file are counted in errors.
+type taskTransitions struct {
+	desc     *prometheus.Desc
+	launch   *prometheus.HistogramVec
+	duration *prometheus.HistogramVec
+	file     string
+	events   func([]TaskEvent)
+	errors   prometheus.Counter
+
+	mu     sync.Mutex
+	seeded bool
+	states map[string]string // task key -> last seen state
+	counts map[transitionKey]float64
+}
+
+// TaskEvent is a task state change between two scrapes.
+type TaskEvent struct {
+	FrameworkID   string  `json:"framework_id"`
+	FrameworkName string  `json:"framework_name,omitempty"`
+	TaskID        string  `json:"task_id"`
+	TaskName      string  `json:"task_name"`
+	SlaveID       string  `json:"slave_id"`
+	Hostname      string  `json:"hostname,omitempty"`
+	State         string  `json:"state"`
+	PreviousState string  `json:"previous_state,omitempty"`
+	Reason        string  `json:"reason,omitempty"`
+	Source        string  `json:"source,omitempty"`
+	Timestamp     float64 `json:"timestamp,omitempty"`
+}
+
+type transitionKey struct {
+	framework, state, reason, source string
+}
+
+// savedTransitions is the file format of persisted task transitions.
+type savedTransitions struct {
+	States map[string]string `json:"states"`
+	Counts []savedCount      `json:"counts"`
+}
+
+type savedCount struct {
+	Framework string  `json:"framework"`
+	State     string  `json:"state"`
+	Reason    string  `json:"reason"`
+	Source    string  `json:"source"`
+	Value     float64 `json:"value"`
+}
+
+// DefaultBuckets are the buckets of the histogram families, which
+// StateOptions.Buckets can override by family name.
+var DefaultBuckets = map[string][]float64{
+	"mesos_task_launch_latency_seconds": prometheus.ExponentialBuckets(0.1, 2, 12),
+	"mesos_task_duration_seconds":       prometheus.ExponentialBuckets(1, 4, 10),
+}
+
+func newTaskTransitions(file string, buckets map[string][]float64) *taskTransitions {
+	bucketsOf := func(name string) []float64 {
+		if b, ok := buckets[name]; ok {
+			return b
+		}
+		return DefaultBuckets[name]
+	}
+
+	t := &taskTransitions{
+		errors: defaultInternal.errors,
+		desc: counterDesc(
+			"mesos_tasks_finished_total",
+			"Total number of tasks which entered a terminal state, as observed by the exporter.",
+			[]string{"framework", "state", "reason", "source"}, nil,
+		),
+		launch: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "mesos",
+			Subsystem: "task",
+			Name:      "launch_latency_seconds",
+			Help:      "Time from the first status update of tasks to them running, as observed by the exporter.",
+			Buckets:   bucketsOf("mesos_task_launch_latency_seconds"),
+		}, []string{"framework"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "mesos",
+			Subsystem: "task",
+			Name:      "duration_seconds",
+			Help:      "Time from the first to the terminal status update of tasks, as observed by the exporter.",
+			Buckets:   bucketsOf("mesos_task_duration_seconds"),
+		}, []string{"framework", "state"}),
+		file:   file,
+		states: map[string]string{},
+		counts: map[transitionKey]float64{},
+	}
+	if file != "" {
+		if err := t.load(); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error restoring task transitions from %s: %s", file, err)
+		}
+	}
+	return t
+}
+
+func (t *taskTransitions) load() error {
+	data, err := os.ReadFile(t.file)
+	if err != nil {
+		return err
+	}
+	var saved savedTransitions
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	if saved.States != nil {
+		t.states, t.seeded = saved.States, true
+	}
+	for _, c := range saved.Counts {
+		t.counts[transitionKey{c.Framework, c.State, c.Reason, c.Source}] = c.Value
+	}
+	return nil
+}
+
+// save atomically replaces the file with the current transitions. The
+// caller must hold t.mu.
+func (t *taskTransitions) save() error {
+	saved := savedTransitions{States: t.states}
+	for k, v := range t.counts {
+		saved.Counts = append(saved.Counts, savedCount{k.framework, k.state, k.reason, k.source, v})
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.file), filepath.Base(t.file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.file)
+}
+
+func (t *taskTransitions) observe(st *State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hostnames := map[string]string{}
+	for _, s := range st.Slaves {
+		hostnames[s.ID] = s.Hostname
+	}
+
+	var (
+		states = map[string]string{}
+		events []TaskEvent
+	)
+	for _, f := range st.Frameworks {
+		for _, tasks := range [][]Task{f.Tasks, f.Completed} {
+			for _, tk := range tasks {
+				key := tk.FrameworkID + "/" + tk.ID
+				states[key] = tk.State
+
+				prev, known := t.states[key]
+				if prev == tk.State || (!known && !t.seeded) {
+					continue
+				}
+				s, _ := tk.lastStatus()
+				events = append(events, TaskEvent{
+					FrameworkID:   tk.FrameworkID,
+					FrameworkName: f.Name,
+					TaskID:        tk.ID,
+					TaskName:      tk.Name,
+					SlaveID:       tk.SlaveID,
+					Hostname:      hostnames[tk.SlaveID],
+					State:         tk.State,
+					PreviousState: prev,
+					Reason:        s.Reason,
+					Source:        s.Source,
+					Timestamp:     s.Timestamp,
+				})
+
+				exemplar := prometheus.Labels{"task_id": tk.ID, "hostname": hostnames[tk.SlaveID]}
+				// Tasks may have finished already when seen running for
+				// the first time.
+				if prev != "TASK_RUNNING" && !IsTerminal(prev) {
+					if d, ok := tk.since("TASK_RUNNING"); ok {
+						observe(t.launch.WithLabelValues(tk.FrameworkID), d, exemplar)
+					}
+				}
+				if !IsTerminal(tk.State) {
+					continue
+				}
+				// The reason and source tell apart e.g. tasks killed for
+				// exceeding their memory limit from crashing ones.
+				t.counts[transitionKey{tk.FrameworkID, tk.State, s.Reason, s.Source}]++
+				if d, ok := tk.since(tk.State); ok {
+					observe(t.duration.WithLabelValues(tk.FrameworkID, tk.State), d, exemplar)
+				}
+			}
+		}
+	}
+	t.states, t.seeded = states, true
+	if t.events != nil && len(events) > 0 {
+		t.events(events)
+	}
+
+	if t.file != "" {
+		if err := t.save(); err != nil {
+			log.Printf("Error saving task transitions to %s: %s", t.file, err)
+			t.errors.Inc()
+		}
+	}
+}
+
+// since returns the time from the first status update of the task to the
+// first one with the given state.
+func (t Task) since(state string) (float64, bool) {
+	if len(t.Statuses) == 0 {
+		return 0, false
+	}
+	for _, s := range t.Statuses {
+		if s.State == state {
+			return s.Timestamp - t.Statuses[0].Timestamp, true
+		}
+	}
+	return 0, false
+}
+
+// observe adds v to the histogram with the exemplar, unless the exemplar is
+// longer than allowed, which would panic.
+func observe(o prometheus.Observer, v float64, exemplar prometheus.Labels) {
+	var n int
+	for k, v := range exemplar {
+		n += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	if n > prometheus.ExemplarMaxRunes {
+		o.Observe(v)
+		return
+	}
+	o.(prometheus.ExemplarObserver).ObserveWithExemplar(v, exemplar)
+}
+
+func (t *taskTransitions) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.desc
+	t.launch.Describe(ch)
+	t.duration.Describe(ch)
+}
+
+func (t *taskTransitions) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, v := range t.counts {
+		ch <- constMetric(t.desc, v, k.framework, k.state, k.reason, k.source)
+	}
+	t.launch.Collect(ch)
+	t.duration.Collect(ch)
+}
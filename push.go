@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var pushErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "mesos",
+	Subsystem: "collector",
+	Name:      "push_errors_total",
+	Help:      "Total number of metrics which couldn't be pushed by output.",
+}, []string{"output"})
+
+func init() {
+	prometheus.MustRegister(pushErrorCounter)
+}
+
+// pushLoop gathers metrics right away and then every interval, and hands
+// them to send, which delivers them to a push based system. Gathering shares
+// the scrape handler's lock and bounds upstream fetches by the interval.
+func pushLoop(name string, h *scrapeHandler, g prometheus.Gatherer, interval time.Duration, send func([]*dto.MetricFamily) error) {
+	pushErrorCounter.WithLabelValues(name)
+	tick := time.Tick(interval)
+	for ; ; <-tick {
+		var (
+			mfs []*dto.MetricFamily
+			err error
+		)
+		if !h.scrape(interval, func() { mfs, err = g.Gather() }) {
+			log.Printf("Skipping push to %s, still waiting for the scrape in progress", name)
+			continue
+		}
+		if err != nil {
+			// Like promhttp, send what could be gathered anyway.
+			log.Printf("Error gathering metrics for %s: %s", name, err)
+		}
+		if err := send(mfs); err != nil {
+			log.Printf("Error pushing metrics to %s: %s", name, err)
+			pushErrorCounter.WithLabelValues(name).Inc()
+		}
+	}
+}
+
+// pushGateway returns a function pushing metrics to the Pushgateway at url,
+// replacing all metrics previously pushed by this exporter instance.
+func pushGateway(url, job string) func([]*dto.MetricFamily) error {
+	instance, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Error getting hostname for the Pushgateway instance label: %s", err)
+	}
+	return func(mfs []*dto.MetricFamily) error {
+		g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+		return push.New(url, job).Grouping("instance", instance).Gatherer(g).Push()
+	}
+}
+
+// textfileOutput returns a function atomically replacing file with the
+// metrics, for the node_exporter textfile collector.
+func textfileOutput(file string) func([]*dto.MetricFamily) error {
+	return func(mfs []*dto.MetricFamily) error {
+		g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+		return prometheus.WriteToTextfile(file, g)
+	}
+}
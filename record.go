@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// recorder records the responses of the polled endpoints to recordDir, or
+// serves them from replayDir without contacting the targets.
+type recorder struct {
+	recordDir, replayDir string
+}
+
+// client returns c, or the default client of the collectors if nil,
+// recording or replaying responses.
+func (r recorder) client(c *http.Client, timeout time.Duration) *http.Client {
+	if r.recordDir == "" && r.replayDir == "" {
+		return c
+	}
+	if c == nil {
+		c = collector.NewHTTPClient(timeout)
+	}
+	wrapped := *c
+	if r.replayDir != "" {
+		wrapped.Transport = replayTransport{dir: r.replayDir}
+		return &wrapped
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	wrapped.Transport = recordingTransport{next: next, dir: r.recordDir}
+	return &wrapped
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// recordFile returns the file the response for u is recorded in, named after
+// its host, path and query, so the same flags replay the same responses.
+func recordFile(dir string, u *url.URL) string {
+	name := u.Host + u.Path
+	if u.RawQuery != "" {
+		name += "?" + u.RawQuery
+	}
+	return filepath.Join(dir, unsafeFileChars.ReplaceAllString(name, "_")+".json")
+}
+
+// recordingTransport saves the bodies of successful responses.
+type recordingTransport struct {
+	next http.RoundTripper
+	dir  string
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(recordFile(t.dir, req.URL), body, 0644); err != nil {
+		return nil, fmt.Errorf("error recording response: %s", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// replayTransport serves the responses saved by recordingTransport, and 404
+// for endpoints which weren't recorded.
+type replayTransport struct {
+	dir string
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	body, err := os.ReadFile(recordFile(t.dir, req.URL))
+	switch {
+	case os.IsNotExist(err):
+		res.StatusCode, res.Status = http.StatusNotFound, "404 Not Found"
+		res.Body = http.NoBody
+	case err != nil:
+		return nil, err
+	default:
+		res.StatusCode, res.Status = http.StatusOK, "200 OK"
+		res.Header.Set("Content-Type", "application/json")
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		res.ContentLength = int64(len(body))
+	}
+	return res, nil
+}
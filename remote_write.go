@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteConfig configures pushing to a Prometheus remote_write
+// endpoint, as accepted by e.g. Mimir, Cortex or Thanos Receive.
+type remoteWriteConfig struct {
+	URL string `json:"url"`
+	httpClientConfig
+}
+
+func (c *remoteWriteConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	return c.httpClientConfig.validate()
+}
+
+type (
+	timeSeries struct {
+		labels []labelPair
+		value  float64
+		// timestamp is in milliseconds since the epoch.
+		timestamp int64
+		// cumulative is set for counters and the buckets, sums and
+		// counts of histograms and summaries.
+		cumulative bool
+	}
+
+	labelPair struct {
+		name, value string
+	}
+)
+
+// remoteWrite returns a function sending metrics to a remote_write endpoint.
+func remoteWrite(cfg *remoteWriteConfig, timeout time.Duration) (func([]*dto.MetricFamily) error, error) {
+	client, err := cfg.newClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return func(mfs []*dto.MetricFamily) error {
+		body := snappy.Encode(nil, encodeWriteRequest(toTimeSeries(mfs, time.Now())))
+		req, err := http.NewRequest("POST", cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("User-Agent", "mesos-exporter")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+			return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+		}
+		return nil
+	}, nil
+}
+
+// toTimeSeries flattens metric families into series the way Prometheus
+// does when scraping them. Samples without timestamp get now.
+func toTimeSeries(mfs []*dto.MetricFamily, now time.Time) []timeSeries {
+	var series []timeSeries
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			ts := now.UnixNano() / int64(time.Millisecond)
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			cumulative := mf.GetType() != dto.MetricType_GAUGE && mf.GetType() != dto.MetricType_UNTYPED
+			add := func(suffix string, v float64, extra ...labelPair) {
+				labels := []labelPair{{"__name__", mf.GetName() + suffix}}
+				for _, l := range m.Label {
+					labels = append(labels, labelPair{l.GetName(), l.GetValue()})
+				}
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				series = append(series, timeSeries{labels, v, ts, cumulative && (suffix != "" || mf.GetType() == dto.MetricType_COUNTER)})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					add("", q.GetValue(), labelPair{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.Bucket {
+					add("_bucket", float64(b.GetCumulativeCount()), labelPair{"le", formatFloat(b.GetUpperBound())})
+				}
+				if n := len(h.Bucket); n == 0 || !math.IsInf(h.Bucket[n-1].GetUpperBound(), 1) {
+					add("_bucket", float64(h.GetSampleCount()), labelPair{"le", "+Inf"})
+				}
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			default:
+				add("", m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes series as prometheus.WriteRequest protobuf
+// message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sb)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ruleGroup and rule are the Prometheus rule file format.
+type (
+	ruleGroup struct {
+		Name  string `yaml:"name"`
+		Rules []rule `yaml:"rules"`
+	}
+
+	rule struct {
+		Record      string            `yaml:"record,omitempty"`
+		Alert       string            `yaml:"alert,omitempty"`
+		Expr        string            `yaml:"expr"`
+		For         string            `yaml:"for,omitempty"`
+		Labels      map[string]string `yaml:"labels,omitempty"`
+		Annotations map[string]string `yaml:"annotations,omitempty"`
+	}
+)
+
+// ruleBuilder writes the queries of rules, with metrics named as configured,
+// restricted to the series matching selector, and aggregations keeping the
+// groupBy labels.
+type ruleBuilder struct {
+	overrides map[string]metricOverride
+	selector  string
+	groupBy   []string
+}
+
+// m returns the selector of metric, which is a metric name optionally
+// followed by label matchers, e.g. mesos_master_cpus{type="used"}.
+func (b ruleBuilder) m(metric string) string {
+	name, matchers := metric, ""
+	if i := strings.Index(metric, "{"); i >= 0 {
+		name, matchers = metric[:i], strings.TrimSuffix(metric[i+1:], "}")
+	}
+	if o, ok := b.overrides[name]; ok && o.Name != "" {
+		name = o.Name
+	}
+	var all []string
+	for _, s := range []string{matchers, b.selector} {
+		if s != "" {
+			all = append(all, s)
+		}
+	}
+	if len(all) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(all, ", ") + "}"
+}
+
+// by returns the grouping clause of an aggregation by labels and groupBy.
+func (b ruleBuilder) by(labels ...string) string {
+	labels = append(append([]string{}, b.groupBy...), labels...)
+	if len(labels) == 0 {
+		return ""
+	}
+	return " by (" + strings.Join(labels, ", ") + ")"
+}
+
+// on returns the matching clause of binary operations between aggregations.
+func (b ruleBuilder) on(labels ...string) string {
+	labels = append(append([]string{}, b.groupBy...), labels...)
+	if len(labels) == 0 {
+		return ""
+	}
+	return " on (" + strings.Join(labels, ", ") + ")"
+}
+
+// groups returns the curated recording and alerting rules. Alerts carry
+// the given labels next to their severity.
+func (b ruleBuilder) groups(labels map[string]string) []ruleGroup {
+	withSeverity := func(s string) map[string]string {
+		l := map[string]string{"severity": s}
+		for k, v := range labels {
+			l[k] = v
+		}
+		return l
+	}
+	failed := `mesos_tasks_finished_total{state=~"TASK_FAILED|TASK_LOST|TASK_ERROR"}`
+
+	return []ruleGroup{
+		{"mesos.rules", []rule{
+			{
+				Record: "mesos:cpus_utilization:ratio",
+				Expr:   fmt.Sprintf("sum%s (%s) / sum%s (%s)", b.by(), b.m(`mesos_master_cpus{type="used"}`), b.by(), b.m("mesos_master_cpus")),
+			},
+			{
+				Record: "mesos:mem_utilization:ratio",
+				Expr:   fmt.Sprintf("sum%s (%s) / sum%s (%s)", b.by(), b.m(`mesos_master_mem_bytes{type="used"}`), b.by(), b.m("mesos_master_mem_bytes")),
+			},
+			{
+				Record: "mesos:tasks_finished:rate5m",
+				Expr:   fmt.Sprintf("sum%s (rate(%s[5m]))", b.by("framework"), b.m("mesos_tasks_finished_total")),
+			},
+			{
+				Record: "mesos:tasks_failed:rate5m",
+				Expr:   fmt.Sprintf("sum%s (rate(%s[5m]))", b.by("framework"), b.m(failed)),
+			},
+			{
+				Record: "mesos:role_reserved_cpus_utilization:ratio",
+				Expr: fmt.Sprintf("sum%s (%s) / sum%s (%s)",
+					b.by("role"), b.m(`mesos_slave_resources{type="used", resource="cpus", role!="*"}`),
+					b.by("role"), b.m(`mesos_slave_resources{type="reserved", resource="cpus"}`)),
+			},
+			{
+				Record: "mesos:role_reserved_mem_utilization:ratio",
+				Expr: fmt.Sprintf("sum%s (%s) / sum%s (%s)",
+					b.by("role"), b.m(`mesos_slave_resources{type="used", resource="mem", role!="*"}`),
+					b.by("role"), b.m(`mesos_slave_resources{type="reserved", resource="mem"}`)),
+			},
+		}},
+		{"mesos.alerts", []rule{
+			{
+				Alert:       "MesosExporterCollectorDown",
+				Expr:        fmt.Sprintf("%s == 0", b.m("mesos_collector_up")),
+				For:         "5m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "The {{ $labels.collector }} collector of {{ $labels.instance }} can't poll Mesos."},
+			},
+			{
+				Alert:       "MesosExporterStuck",
+				Expr:        fmt.Sprintf("rate(%s[10m]) == 0", b.m("mesos_exporter_collect_total")),
+				For:         "10m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "The {{ $labels.collector }} collector of {{ $labels.instance }} stopped collecting."},
+			},
+			{
+				Alert:       "MesosSlaveDown",
+				Expr:        fmt.Sprintf("%s == 1", b.m(`mesos_slave_info{active="false"}`)),
+				For:         "5m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "Slave {{ $labels.hostname }} is inactive."},
+			},
+			{
+				Alert:       "MesosClusterCPUsExhausted",
+				Expr:        "mesos:cpus_utilization:ratio > 0.9",
+				For:         "15m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "{{ $value | humanizePercentage }} of the cluster CPUs are in use."},
+			},
+			{
+				Alert:       "MesosClusterMemoryExhausted",
+				Expr:        "mesos:mem_utilization:ratio > 0.9",
+				For:         "15m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "{{ $value | humanizePercentage }} of the cluster memory is in use."},
+			},
+			{
+				Alert:       "MesosTaskFailureRateHigh",
+				Expr:        fmt.Sprintf("mesos:tasks_failed:rate5m /%s mesos:tasks_finished:rate5m > 0.2", b.on("framework")),
+				For:         "10m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "{{ $value | humanizePercentage }} of the tasks of framework {{ $labels.framework }} fail."},
+			},
+			{
+				Alert: "MesosSlaveChurnHigh",
+				Expr: fmt.Sprintf("sum%s (increase(%s[1h])) + sum%s (increase(%s[1h])) > 5",
+					b.by(), b.m(`mesos_master_slave_unreachable_events_total{event="completed"}`),
+					b.by(), b.m(`mesos_master_slave_registration_events_total{event="reregister"}`)),
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "{{ $value }} slaves became unreachable or reregistered in the last hour."},
+			},
+			{
+				Alert: "MesosMasterOverloaded",
+				Expr: fmt.Sprintf("%s > 1000 or %s > 1000",
+					b.m("mesos_master_event_queue_length"), b.m("mesos_master_allocator_event_queue_length")),
+				For:         "5m",
+				Labels:      withSeverity("warning"),
+				Annotations: map[string]string{"summary": "Master {{ $labels.instance }} has a backlog of {{ $value }} events and falls behind."},
+			},
+			{
+				Alert: "MesosFrameworkNotFailoverSafe",
+				Expr: fmt.Sprintf("%s == 0 or%s %s < 300",
+					b.m("mesos_framework_checkpoint"), b.on("framework"), b.m("mesos_framework_failover_timeout_seconds")),
+				For:         "1h",
+				Labels:      withSeverity("info"),
+				Annotations: map[string]string{"summary": "Tasks of framework {{ $labels.name }} don't survive slave restarts or short framework outages."},
+			},
+			{
+				Alert: "MesosRoleReservationExhausted",
+				Expr: fmt.Sprintf("mesos:role_reserved_cpus_utilization:ratio > 0.95 or%s mesos:role_reserved_mem_utilization:ratio > 0.95",
+					b.on("role")),
+				For:         "15m",
+				Labels:      withSeverity("info"),
+				Annotations: map[string]string{"summary": "Role {{ $labels.role }} uses {{ $value | humanizePercentage }} of its reserved resources."},
+			},
+		}},
+	}
+}
+
+// rules prints the curated Prometheus rules for the metrics of the exporter.
+func rules(args []string) {
+	fs := flag.NewFlagSet("mesos-exporter rules", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to the configuration file of the exporter, whose metric renames are applied")
+	selector := fs.String("selector", "", `Label matchers restricting all queries, e.g. job="mesos"`)
+	groupBy := fs.String("group-by", "", "Comma separated labels, e.g. cluster, kept by all aggregations to evaluate rules per value")
+	labels := fs.String("labels", "", "Comma separated name=value labels added to all alerts, e.g. team=infra")
+
+	fs.Parse(args)
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+	b := ruleBuilder{overrides: cfg.Metrics, selector: *selector}
+	if *groupBy != "" {
+		b.groupBy = strings.Split(*groupBy, ",")
+	}
+	alertLabels := map[string]string{}
+	if *labels != "" {
+		for _, l := range strings.Split(*labels, ",") {
+			kv := strings.SplitN(l, "=", 2)
+			if len(kv) != 2 {
+				log.Fatalf("Invalid label %q, want name=value", l)
+			}
+			alertLabels[kv[0]] = kv[1]
+		}
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(map[string][]ruleGroup{"groups": b.groups(alertLabels)}); err != nil {
+		log.Fatal(err)
+	}
+}
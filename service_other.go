@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// runService reports whether the exporter is run as a Windows service, which
+// it never is on other systems.
+func runService(serve func()) bool {
+	return false
+}
@@ -0,0 +1,76 @@
+//go:build windows
+
+package main
+
+import (
+	"log"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// serviceName is the event log source of the exporter run as Windows
+// service.
+const serviceName = "mesos-exporter"
+
+// runService runs serve as Windows service and logs to the event log if the
+// exporter was started by the service control manager, and reports whether
+// it was.
+func runService(serve func()) bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Fatalf("Error detecting Windows service: %s", err)
+	}
+	if !isService {
+		return false
+	}
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		log.Fatalf("Error opening event log: %s", err)
+	}
+	defer elog.Close()
+	log.SetOutput(eventLogWriter{elog})
+	log.SetFlags(0)
+
+	go serve()
+	if err := svc.Run(serviceName, serviceHandler{}); err != nil {
+		log.Fatalf("Error running Windows service: %s", err)
+	}
+	return true
+}
+
+// serviceHandler handles the requests of the service control manager while
+// the exporter serves in the background, until it's stopped.
+type serviceHandler struct{}
+
+func (serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+	for r := range requests {
+		switch r.Cmd {
+		case svc.Interrogate:
+			status <- r.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// eventLogWriter writes log lines to the event log, errors as such.
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	var err error
+	if strings.HasPrefix(msg, "Error") {
+		err = w.elog.Error(1, msg)
+	} else {
+		err = w.elog.Info(1, msg)
+	}
+	return len(p), err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// checkTargets gathers g once and fails if any collector couldn't poll its
+// target, e.g. as it's unreachable or rejects the exporter's credentials.
+func checkTargets(h *scrapeHandler, g prometheus.Gatherer) error {
+	var err error
+	failed := map[string]bool{}
+	h.scrape(0, func() {
+		mfs, gerr := g.Gather()
+		if gerr != nil {
+			err = gerr
+			return
+		}
+		for _, mf := range mfs {
+			if mf.GetName() != "mesos_collector_up" {
+				continue
+			}
+			for _, m := range mf.Metric {
+				if m.GetGauge().GetValue() == 1 {
+					continue
+				}
+				var labels []string
+				for _, l := range m.Label {
+					labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+				}
+				failed["{"+strings.Join(labels, ",")+"}"] = true
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		var names []string
+		for n := range failed {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("collectors failed: %s", strings.Join(names, " "))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statsdPacketSize keeps datagrams below the usual MTU.
+const statsdPacketSize = 1432
+
+// statsdOutput sends metrics to a StatsD server, with labels as DogStatsD
+// tags as understood by Datadog agents and Telegraf.
+//
+// Cumulative series are sent as counters of the increase since the previous
+// push, so after the first push, and all other series as gauges.
+type statsdOutput struct {
+	conn   net.Conn
+	prefix string
+
+	mu   sync.Mutex
+	prev map[string]float64
+}
+
+func newStatsdOutput(addr, prefix string) (*statsdOutput, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdOutput{conn: conn, prefix: prefix, prev: map[string]float64{}}, nil
+}
+
+func (o *statsdOutput) send(mfs []*dto.MetricFamily) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var (
+		packet []byte
+		prev   = map[string]float64{}
+		errs   []error
+	)
+	flush := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := o.conn.Write(packet); err != nil {
+			errs = append(errs, err)
+		}
+		packet = packet[:0]
+	}
+
+	for _, s := range toTimeSeries(mfs, time.Now()) {
+		line := o.line(s, prev)
+		if line == "" {
+			continue
+		}
+		if len(packet)+len(line)+1 > statsdPacketSize {
+			flush()
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	flush()
+	o.prev = prev
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// line formats a series as StatsD line, recording cumulative values in prev.
+func (o *statsdOutput) line(s timeSeries, prev map[string]float64) string {
+	var (
+		name string
+		tags []string
+	)
+	for _, l := range s.labels {
+		if l.name == "__name__" {
+			name = o.prefix + l.value
+			continue
+		}
+		tags = append(tags, l.name+":"+statsdEscape(l.value))
+	}
+
+	v, typ := s.value, "g"
+	if s.cumulative {
+		key := name + "|" + strings.Join(tags, ",")
+		prev[key] = s.value
+		last, ok := o.prev[key]
+		if !ok {
+			return ""
+		}
+		// Counter resets start from 0 again.
+		if v = s.value - last; v < 0 {
+			v = s.value
+		}
+		typ = "c"
+	}
+
+	line := name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + typ
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// statsdEscape replaces characters with special meaning in DogStatsD tags.
+func statsdEscape(v string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(v)
+}
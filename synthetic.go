@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// syntheticURL is the master URL the synthetic state is served on.
+const syntheticURL = "http://synthetic"
+
+// syntheticState fabricates the state of a master with the given number of
+// slaves and tasks, spread across frameworks, zones and task states like in
+// a busy cluster. The same numbers always give the same state.
+func syntheticState(slaves, tasks int) *collector.State {
+	r := rand.New(rand.NewSource(1))
+	now := float64(time.Now().Unix())
+	st := &collector.State{
+		Version:     "1.11.0",
+		PID:         "master@10.0.0.1:5050",
+		Leader:      "master@10.0.0.1:5050",
+		ElectedTime: now - 86400,
+	}
+
+	for i := 0; i < slaves; i++ {
+		s := collector.Slave{
+			ID:       fmt.Sprintf("synthetic-S%d", i),
+			PID:      fmt.Sprintf("slave(1)@10.%d.%d.%d:5051", i>>16&255, i>>8&255, i&255),
+			Hostname: fmt.Sprintf("agent-%d.synthetic", i),
+			Port:     5051,
+			Active:   r.Float64() > 0.01,
+			Total:    collector.Resources{CPUs: 32, Mem: 128 << 10, Disk: 1 << 20},
+			Domain:   &collector.Domain{},
+		}
+		s.Domain.FaultDomain.Region.Name = "synthetic"
+		s.Domain.FaultDomain.Zone.Name = fmt.Sprintf("synthetic-%c", 'a'+i%3)
+		st.Slaves = append(st.Slaves, s)
+	}
+
+	frameworks := tasks/10000 + 3
+	for i := 0; i < frameworks; i++ {
+		st.Frameworks = append(st.Frameworks, collector.Framework{
+			ID:              fmt.Sprintf("synthetic-F%d", i),
+			Name:            fmt.Sprintf("framework-%d", i),
+			Active:          true,
+			Checkpoint:      true,
+			FailoverTimeout: 604800,
+		})
+	}
+
+	for i := 0; i < tasks && slaves > 0; i++ {
+		f, s := &st.Frameworks[i%frameworks], &st.Slaves[r.Intn(slaves)]
+		mem, disk := 128<<r.Intn(5), 1024<<r.Intn(4)
+		res := collector.Resources{CPUs: float64(1+r.Intn(8)) / 4, Mem: float64(mem), Disk: float64(disk)}
+		t := collector.Task{
+			Name:        fmt.Sprintf("app-%d", i%500),
+			ID:          fmt.Sprintf("app-%d.%d", i%500, i),
+			ExecutorID:  fmt.Sprintf("app-%d.%d", i%500, i),
+			FrameworkID: f.ID,
+			SlaveID:     s.ID,
+			State:       "TASK_RUNNING",
+			Labels:      []collector.Label{{Key: "team", Value: fmt.Sprintf("team-%d", i%20)}},
+			Resources:   res,
+		}
+		started := now - r.Float64()*86400
+		t.Statuses = []collector.Status{
+			{State: "TASK_STAGING", Timestamp: started},
+			{State: "TASK_STARTING", Timestamp: started + 1},
+			{State: "TASK_RUNNING", Timestamp: started + 2 + r.Float64()*10},
+		}
+
+		// One task in ten has terminated.
+		if i%10 == 9 {
+			t.State = "TASK_FINISHED"
+			if r.Intn(4) == 0 {
+				t.State = "TASK_FAILED"
+			}
+			t.Statuses = append(t.Statuses, collector.Status{State: t.State, Timestamp: started + 60 + r.Float64()*3600})
+			f.Completed = append(f.Completed, t)
+			continue
+		}
+		f.Tasks = append(f.Tasks, t)
+		for _, rs := range []*collector.Resources{&s.Used, &f.Resources} {
+			rs.CPUs += res.CPUs
+			rs.Mem += res.Mem
+			rs.Disk += res.Disk
+		}
+	}
+	for i := range st.Slaves {
+		s := &st.Slaves[i]
+		s.Unreserved = s.Total
+	}
+	return st
+}
+
+// syntheticTransport serves the state, encoded once, on path and 404 for all
+// other endpoints.
+type syntheticTransport struct {
+	path  string
+	state []byte
+}
+
+func newSyntheticTransport(path string, slaves, tasks int) (syntheticTransport, error) {
+	if path == "" {
+		path = "/state"
+	}
+	path = "/" + strings.TrimPrefix(path, "/")
+	state, err := json.Marshal(syntheticState(slaves, tasks))
+	return syntheticTransport{path: path, state: state}, err
+}
+
+func (t syntheticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Body:       http.NoBody,
+	}
+	if req.URL.Path == t.path {
+		res.StatusCode, res.Status = http.StatusOK, "200 OK"
+		res.Header.Set("Content-Type", "application/json")
+		res.Body = io.NopCloser(bytes.NewReader(t.state))
+		res.ContentLength = int64(len(t.state))
+	}
+	return res, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// tracer creates the spans of scrapes, below which collectors add theirs.
+// Until setupTracing is called, spans aren't recorded.
+var tracer = otel.Tracer("github.com/mesosphere/mesos-exporter")
+
+// setupTracing exports spans to the OTLP receiver at endpoint.
+func setupTracing(endpoint, protocol string) error {
+	ctx := context.Background()
+
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch protocol {
+	case otlpGRPC:
+		exp, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	case otlpHTTP:
+		exp, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	default:
+		return fmt.Errorf("unknown OTLP protocol %q", protocol)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := sdkresource.Merge(sdkresource.Default(), sdkresource.NewSchemaless(attribute.String("service.name", "mesos-exporter")))
+	if err != nil {
+		return err
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res)))
+	return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultConfig configures access to HashiCorp Vault, to read credentials
+// from it instead of files. The client authenticates with the token in
+// token_file, or logs in with AppRole.
+type vaultConfig struct {
+	URL       string        `json:"url"`
+	TokenFile string        `json:"token_file"`
+	AppRole   *vaultAppRole `json:"approle"`
+	TLSConfig tlsConfig     `json:"tls_config"`
+}
+
+type vaultAppRole struct {
+	// Mount is the path the AppRole auth method is mounted at, "approle"
+	// if empty.
+	Mount        string `json:"mount"`
+	RoleID       string `json:"role_id"`
+	SecretIDFile string `json:"secret_id_file"`
+}
+
+func (c *vaultConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if (c.TokenFile == "") == (c.AppRole == nil) {
+		return fmt.Errorf("exactly one of token_file and approle must be set")
+	}
+	if c.AppRole != nil && (c.AppRole.RoleID == "" || c.AppRole.SecretIDFile == "") {
+		return fmt.Errorf("approle: role_id and secret_id_file are required")
+	}
+	return c.TLSConfig.validate()
+}
+
+// vaultClient reads secrets from Vault. Its token is renewed in the
+// background before its lease runs out, and with AppRole it logs in again
+// if the token can't be renewed anymore.
+type vaultClient struct {
+	url    string
+	cfg    vaultConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+func newVaultClient(cfg vaultConfig, timeout time.Duration) (*vaultClient, error) {
+	t, err := cfg.TLSConfig.transport()
+	if err != nil {
+		return nil, err
+	}
+	return &vaultClient{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		cfg:    cfg,
+		client: &http.Client{Timeout: timeout, Transport: t},
+	}, nil
+}
+
+// vaultAuth is the auth block of Vault responses.
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// vaultResponse is the envelope of Vault responses.
+type vaultResponse struct {
+	Data          map[string]interface{} `json:"data"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Auth          *vaultAuth             `json:"auth"`
+}
+
+// currentToken returns the token to authenticate with, logging in first if
+// needed.
+func (v *vaultClient) currentToken() (string, error) {
+	if v.cfg.TokenFile != "" {
+		b, err := os.ReadFile(v.cfg.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token == "" {
+		if _, err := v.login(); err != nil {
+			return "", err
+		}
+	}
+	return v.token, nil
+}
+
+// login logs in with AppRole. v.mu must be held.
+func (v *vaultClient) login() (*vaultAuth, error) {
+	secretID, err := os.ReadFile(v.cfg.AppRole.SecretIDFile)
+	if err != nil {
+		return nil, err
+	}
+	mount := v.cfg.AppRole.Mount
+	if mount == "" {
+		mount = "approle"
+	}
+	var res vaultResponse
+	err = v.do("POST", "/v1/auth/"+strings.Trim(mount, "/")+"/login", "", map[string]string{
+		"role_id":   v.cfg.AppRole.RoleID,
+		"secret_id": strings.TrimSpace(string(secretID)),
+	}, &res)
+	if err != nil {
+		return nil, fmt.Errorf("logging in with AppRole: %s", err)
+	}
+	if res.Auth == nil || res.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("logging in with AppRole: no token returned")
+	}
+	v.token = res.Auth.ClientToken
+	return res.Auth, nil
+}
+
+// renew renews the token, or logs in again with AppRole if that fails, and
+// returns its new lease.
+func (v *vaultClient) renew() (*vaultAuth, error) {
+	token, err := v.currentToken()
+	if err != nil {
+		return nil, err
+	}
+	var res vaultResponse
+	err = v.do("POST", "/v1/auth/token/renew-self", token, struct{}{}, &res)
+	if err == nil && res.Auth != nil {
+		return res.Auth, nil
+	}
+	if v.cfg.AppRole == nil {
+		return nil, fmt.Errorf("renewing token: %v", err)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.login()
+}
+
+// run keeps the token valid by renewing it at half of its lease. It returns
+// once the token doesn't expire or can't be renewed.
+func (v *vaultClient) run() {
+	if v.cfg.TokenFile != "" && !v.renewable() {
+		return
+	}
+	for {
+		auth, err := v.renew()
+		var wait time.Duration
+		switch {
+		case err != nil:
+			log.Printf("Error renewing Vault token: %s", err)
+			wait = time.Minute
+		case auth.LeaseDuration == 0:
+			return
+		case !auth.Renewable && v.cfg.AppRole == nil:
+			log.Printf("Vault token isn't renewable and expires in %ds", auth.LeaseDuration)
+			return
+		default:
+			wait = time.Duration(auth.LeaseDuration) * time.Second / 2
+		}
+		time.Sleep(wait)
+	}
+}
+
+// renewable reports whether the token from the token file expires and can
+// be renewed. Tokens which can't be looked up are assumed to be renewable.
+func (v *vaultClient) renewable() bool {
+	token, err := v.currentToken()
+	if err != nil {
+		return true
+	}
+	var res vaultResponse
+	if err := v.do("GET", "/v1/auth/token/lookup-self", token, nil, &res); err != nil {
+		log.Printf("Error looking up Vault token: %s", err)
+		return true
+	}
+	renewable, _ := res.Data["renewable"].(bool)
+	ttl, _ := res.Data["ttl"].(float64)
+	return renewable && ttl > 0
+}
+
+// read reads the secret at path, e.g. secret/data/mesos-exporter. The data
+// of KV version 2 secrets is unwrapped. The lease tells how long it may be
+// cached.
+func (v *vaultClient) read(path string) (map[string]interface{}, time.Duration, error) {
+	token, err := v.currentToken()
+	if err != nil {
+		return nil, 0, err
+	}
+	var res vaultResponse
+	if err := v.do("GET", "/v1/"+strings.TrimPrefix(path, "/"), token, nil, &res); err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %s", path, err)
+	}
+	data := res.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	return data, time.Duration(res.LeaseDuration) * time.Second, nil
+}
+
+func (v *vaultClient) do(method, path, token string, body, res interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, v.url+path, r)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// vaultSecret caches a secret read from Vault for its lease, but at most
+// defaultSecretTTL. KV version 1 secrets report a lease of 768h unless they
+// set a refresh interval, so rotated secrets would be missed for weeks.
+type vaultSecret struct {
+	vault *vaultClient
+	path  string
+
+	mu      sync.Mutex
+	data    map[string]interface{}
+	expires time.Time
+}
+
+const defaultSecretTTL = 5 * time.Minute
+
+// get returns the secret, reading it again once it expired. If that
+// fails, the previous secret is used until Vault is back.
+func (s *vaultSecret) get() (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data != nil && time.Now().Before(s.expires) {
+		return s.data, nil
+	}
+	data, lease, err := s.vault.read(s.path)
+	if err != nil {
+		if s.data == nil {
+			return nil, err
+		}
+		log.Printf("Error refreshing Vault secret, using the previous one: %s", err)
+		return s.data, nil
+	}
+	if lease == 0 || lease > defaultSecretTTL {
+		lease = defaultSecretTTL
+	}
+	s.data, s.expires = data, time.Now().Add(lease)
+	return data, nil
+}
+
+// secretString returns the string field of a secret, or "" if there is none.
+func secretString(data map[string]interface{}, field string) string {
+	v, _ := data[field].(string)
+	return v
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mesosphere/mesos-exporter/pkg/collector"
+)
+
+// defaultWebhookStates are the terminal states notified about if a webhook
+// doesn't list any, i.e. those of tasks which didn't finish on purpose.
+var defaultWebhookStates = []string{"TASK_FAILED", "TASK_LOST", "TASK_ERROR", "TASK_DROPPED", "TASK_GONE"}
+
+// webhookConfig configures a webhook receiving a POST for tasks of the
+// given frameworks, by name or ID, entering one of the given states. All
+// frameworks are selected if none is given.
+type webhookConfig struct {
+	URL        string   `json:"url"`
+	Frameworks []string `json:"frameworks"`
+	States     []string `json:"states"`
+	httpClientConfig
+}
+
+func (c *webhookConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	for _, s := range c.States {
+		if !collector.IsTerminal(s) {
+			return fmt.Errorf("%s isn't a terminal task state", s)
+		}
+	}
+	return c.httpClientConfig.validate()
+}
+
+// webhookPayload is the body of webhook requests.
+type webhookPayload struct {
+	Events []collector.TaskEvent `json:"events"`
+}
+
+// webhook posts the matching task events of every observation to its URL.
+// Requests are sent in the background, so a slow receiver doesn't hold up
+// scrapes, and events are dropped if it can't keep up.
+type webhook struct {
+	url        string
+	client     *http.Client
+	frameworks map[string]bool
+	states     map[string]bool
+	queue      chan []collector.TaskEvent
+}
+
+func newWebhook(cfg webhookConfig, timeout time.Duration) (*webhook, error) {
+	client, err := cfg.newClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	states := cfg.States
+	if len(states) == 0 {
+		states = defaultWebhookStates
+	}
+	w := &webhook{
+		url:        cfg.URL,
+		client:     client,
+		frameworks: map[string]bool{},
+		states:     map[string]bool{},
+		queue:      make(chan []collector.TaskEvent, 16),
+	}
+	for _, f := range cfg.Frameworks {
+		w.frameworks[f] = true
+	}
+	for _, s := range states {
+		w.states[s] = true
+	}
+	pushErrorCounter.WithLabelValues("webhook")
+	return w, nil
+}
+
+// run sends the queued events. It doesn't return.
+func (w *webhook) run() {
+	for events := range w.queue {
+		if err := w.post(events); err != nil {
+			log.Printf("Error notifying webhook %s: %s", w.url, err)
+			pushErrorCounter.WithLabelValues("webhook").Add(float64(len(events)))
+		}
+	}
+}
+
+// notify queues the events the webhook is interested in.
+func (w *webhook) notify(events []collector.TaskEvent) {
+	var matched []collector.TaskEvent
+	for _, e := range events {
+		if w.matches(e) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	select {
+	case w.queue <- matched:
+	default:
+		log.Printf("Dropping %d task events for webhook %s, which doesn't keep up", len(matched), w.url)
+		pushErrorCounter.WithLabelValues("webhook").Add(float64(len(matched)))
+	}
+}
+
+func (w *webhook) matches(e collector.TaskEvent) bool {
+	if !w.states[e.State] {
+		return false
+	}
+	return len(w.frameworks) == 0 || w.frameworks[e.FrameworkID] || w.frameworks[e.FrameworkName]
+}
+
+func (w *webhook) post(events []collector.TaskEvent) error {
+	body, err := json.Marshal(webhookPayload{Events: events})
+	if err != nil {
+		return err
+	}
+	res, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}